@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"payment/controllers"
+	"payment/core"
 	"payment/middleware"
 	"time"
 
@@ -24,26 +25,86 @@ func New(c controllers.Controller) *App {
 	user := public.Group("/:user_uuid")
 	user.Use(middleware.Auth(c))
 	user.Use(middleware.CheckBlockedUser(c))
+	r.POST("/payouts/:transaction_uuid/callback", middleware.CheckPayoutSecret(c), c.PayoutCallback)
 	admin := r.Group("/admin/:user_uuid")
 	admin.Use(middleware.Auth(c), middleware.CheckAdmin(c))
 	admin.POST("/update-role", c.ChangeRole)
 	admin.POST("users/:target_uuid/block", c.BlockUser)
 	admin.POST("users/:target_uuid/unblock", c.UnblockUser)
 	admin.POST("/accounts/:account_uuid/unblock", c.UnblockAccount)
+	admin.PUT("/accounts/:account_uuid/overdraft", c.SetOverdraftLimit)
+	admin.POST("/accounts/:account_uuid/debit", c.DebitAccount)
 	admin.GET("/accounts/requested", c.GetAccountsRequested)
+	admin.POST("/transactions/:transaction_uuid/refund", c.AdminRefundTransaction)
+	admin.GET("/transactions/pending-approval", c.GetTransactionsPendingApproval)
+	admin.POST("/transactions/:transaction_uuid/approve", c.ApproveTransaction)
+	admin.POST("/transactions/:transaction_uuid/reject", c.RejectTransaction)
+	admin.GET("/limits/:target_uuid", c.GetLimits)
+	admin.PUT("/limits/:target_uuid", c.SetLimit)
+	admin.DELETE("/limits/:target_uuid/:limit_uuid", c.DeleteLimit)
+	admin.GET("/fees", c.GetFeeSchedules)
+	admin.PUT("/fees", c.SetFeeSchedule)
+	admin.DELETE("/fees/:fee_uuid", c.DeleteFeeSchedule)
 	user.POST("/accounts/new", c.NewAccount)
 	user.GET("/accounts", c.GetAccounts)
+	user.GET("/memberships", c.GetMemberships)
+	user.POST("/memberships/:account_uuid/accept", c.AcceptInvite)
+	user.DELETE("/memberships/:account_uuid", c.LeaveAccount)
+	user.GET("/payment-requests", c.GetIncomingPaymentRequests)
+	user.POST("/payment-requests/:request_uuid/accept", middleware.Idempotency(c), c.AcceptPaymentRequest)
+	user.POST("/payment-requests/:request_uuid/decline", c.DeclinePaymentRequest)
 	account := user.Group("/accounts/:account_uuid")
 	account.Use(middleware.CheckAccount(c))
 	account.GET("", c.GetAccount)
-	account.POST("/block", c.BlockAccount)
-	account.POST("/unblock", c.RequestUnblockAccount)
+	account.POST("/block", middleware.CheckPermission(c, core.OWNER), c.BlockAccount)
+	account.POST("/unblock", middleware.CheckPermission(c, core.OWNER), c.RequestUnblockAccount)
+	members := account.Group("/members")
+	members.GET("", c.GetMembers)
+	members.POST("", middleware.CheckPermission(c, core.OWNER), c.InviteMember)
+	members.PUT("/:member_uuid", middleware.CheckPermission(c, core.OWNER), c.SetMemberPermission)
+	members.DELETE("/:member_uuid", middleware.CheckPermission(c, core.OWNER), c.RemoveMember)
 	//the middleware is not used to the previous endpoints, but is working with the new ones
 	account.Use(middleware.CheckBlockedAccount(c))
-	account.POST("/transactions/new", c.NewTransaction)
+	account.POST("/transactions/new", middleware.CheckPermission(c, core.INITIATE), middleware.Idempotency(c), c.NewTransaction)
+	account.POST("/transactions/batch", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.SendBatch)
 	account.GET("/transactions", c.GetTransactions)
-	account.POST("/add-money", c.AddMoney)
-	account.POST("/transactions/:transaction_uuid/send", c.SendTransaction)
+	account.GET("/transactions/:transaction_uuid", c.GetTransaction)
+	account.GET("/ledger", c.GetLedger)
+	account.GET("/interest", c.GetInterest)
+	account.POST("/add-money", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.AddMoney)
+	account.POST("/close", middleware.CheckPermission(c, core.OWNER), middleware.Idempotency(c), c.CloseAccount)
+	account.POST("/withdraw", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.Withdraw)
+	account.POST("/transactions/:transaction_uuid/send", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.SendTransaction)
+	account.POST("/transactions/:transaction_uuid/cancel", middleware.CheckPermission(c, core.INITIATE), c.CancelTransaction)
+	account.POST("/transactions/:transaction_uuid/refund", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.RefundTransaction)
+	account.POST("/transactions/:transaction_uuid/split", middleware.CheckPermission(c, core.INITIATE), c.SplitTransaction)
+	account.GET("/splits", c.GetSplits)
+	account.GET("/splits/:split_uuid", c.GetSplit)
+	holds := account.Group("/holds")
+	holds.POST("", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.AuthorizeHold)
+	holds.GET("", c.GetHolds)
+	holds.GET("/:hold_uuid", c.GetHold)
+	holds.POST("/:hold_uuid/capture", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.CaptureHold)
+	holds.POST("/:hold_uuid/void", middleware.CheckPermission(c, core.INITIATE), c.VoidHold)
+	orders := account.Group("/standing-orders")
+	orders.POST("", middleware.CheckPermission(c, core.SEND), c.NewStandingOrder)
+	orders.GET("", c.GetStandingOrders)
+	orders.GET("/:order_uuid", c.GetStandingOrder)
+	orders.PUT("/:order_uuid", middleware.CheckPermission(c, core.SEND), c.UpdateStandingOrder)
+	orders.DELETE("/:order_uuid", middleware.CheckPermission(c, core.INITIATE), c.CancelStandingOrder)
+	orders.GET("/:order_uuid/runs", c.GetStandingOrderRuns)
+	requests := account.Group("/requests")
+	requests.POST("", middleware.CheckPermission(c, core.INITIATE), c.RequestMoney)
+	requests.GET("", c.GetPaymentRequests)
+	requests.GET("/:request_uuid", c.GetPaymentRequest)
+	requests.POST("/:request_uuid/remind", middleware.CheckPermission(c, core.INITIATE), c.RemindPaymentRequest)
+	requests.POST("/:request_uuid/cancel", middleware.CheckPermission(c, core.INITIATE), c.CancelPaymentRequest)
+	pockets := account.Group("/pockets")
+	pockets.POST("", middleware.CheckPermission(c, core.INITIATE), c.CreatePocket)
+	pockets.GET("", c.GetPockets)
+	pockets.POST("/move", middleware.CheckPermission(c, core.SEND), middleware.Idempotency(c), c.MovePocketMoney)
+	pockets.GET("/:pocket_uuid", c.GetPocket)
+	pockets.DELETE("/:pocket_uuid", middleware.CheckPermission(c, core.SEND), c.DeletePocket)
 	return &App{
 		controller: c,
 		Router:     r,
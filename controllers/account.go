@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"payment/models"
 	"strconv"
@@ -14,6 +16,11 @@ type AddMoneyInput struct {
 	Amount string `json:"amount" binding:"required"`
 }
 
+type NewAccountInput struct {
+	Currency string `json:"currency"`
+	Type     string `json:"type"`
+}
+
 type ChangeRoleInput struct {
 	UserUUID string `json:"user_uuid" binding:"required"`
 	Role     string `json:"role" binding:"required"`
@@ -41,7 +48,12 @@ func (c *Controller) NewAccount(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	account, err := c.System.NewAccount(userUUID)
+	var input NewAccountInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := c.System.NewAccount(userUUID, input.Currency, strings.ToLower(input.Type))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -137,12 +149,12 @@ func (c *Controller) AddMoney(ctx *gin.Context) {
 		return
 	}
 
-	amount, err := strconv.ParseUint(input.Amount, 10, 32)
+	amount, err := models.ParseMoney(input.Amount)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	account, err := c.System.AddMoney(accountUUID, uint(amount))
+	account, err := c.System.AddMoney(accountUUID, amount)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -151,6 +163,31 @@ func (c *Controller) AddMoney(ctx *gin.Context) {
 
 }
 
+func (c *Controller) DebitAccount(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input AddMoneyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount, err := models.ParseMoney(input.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := c.System.DebitAccount(accountUUID, amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "debit account", "account": account})
+}
+
 func (c *Controller) GetAccount(ctx *gin.Context) {
 	accountUUIDstr := ctx.Param("account_uuid")
 	accountUUID, err := uuid.Parse(accountUUIDstr)
@@ -163,10 +200,44 @@ func (c *Controller) GetAccount(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"uuid": account.UUID, "iban": account.IBAN, "balance": account.Balance})
+	pockets, err := c.System.GetPockets(accountUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// the balance is the combined one, the main pocket is what the pockets left
+	mainBalance, err := account.Balance.Sub(account.PocketAmount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	available, err := mainBalance.Sub(account.HeldAmount)
+	if err == nil {
+		available, err = available.Add(account.OverdraftLimit)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"uuid": account.UUID, "iban": account.IBAN, "balance": account.Balance, "main_balance": mainBalance, "pocket_amount": account.PocketAmount, "pockets": pockets, "held_amount": account.HeldAmount, "overdraft_limit": account.OverdraftLimit, "available_balance": available, "currency": account.Currency, "type": account.Type})
 
 }
 
+func (c *Controller) GetInterest(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate, accrual, err := c.System.GetInterest(accountUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"rate_basis_points": rate, "accrued": accrual.Accrued, "overdraft_interest": accrual.Overdraft, "accrued_at": accrual.AccruedAt, "paid_at": accrual.PaidAt})
+}
+
 func (c *Controller) BlockAccount(ctx *gin.Context) {
 	accountUUIDstr := ctx.Param("account_uuid")
 	accountUUID, err := uuid.Parse(accountUUIDstr)
@@ -197,6 +268,35 @@ func (c *Controller) RequestUnblockAccount(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "account is waiting to be unblock"})
 }
 
+type OverdraftInput struct {
+	Limit string `json:"limit" binding:"required"`
+}
+
+func (c *Controller) SetOverdraftLimit(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input OverdraftInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := models.ParseMoney(input.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := c.System.SetOverdraftLimit(accountUUID, limit)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "set overdraft limit", "account": account})
+}
+
 func (c *Controller) UnblockAccount(ctx *gin.Context) {
 	accountUUIDstr := ctx.Param("account_uuid")
 	accountUUID, err := uuid.Parse(accountUUIDstr)
@@ -211,3 +311,36 @@ func (c *Controller) UnblockAccount(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, gin.H{"message": "account is unblocked"})
 }
+
+type CloseInput struct {
+	DestinationUUID string `json:"destination_uuid"`
+	DestinationIBAN string `json:"destination_iban"`
+}
+
+func (c *Controller) CloseAccount(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input CloseInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	destinationUUID := uuid.Nil
+	if input.DestinationUUID != "" {
+		destinationUUID, err = uuid.Parse(input.DestinationUUID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	account, err := c.System.CloseAccount(accountUUID, destinationUUID, input.DestinationIBAN)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "account is closed", "account": account})
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReviewInput struct {
+	Reason string `json:"reason"`
+}
+
+func (c *Controller) GetTransactionsPendingApproval(ctx *gin.Context) {
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + ASC
+	transactions, err := c.System.GetTransactionsPendingApproval(query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
+
+func (c *Controller) ApproveTransaction(ctx *gin.Context) {
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input ReviewInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.ApproveTransaction(transactionUUID, input.Reason)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "approve transaction", "transaction": transaction})
+}
+
+func (c *Controller) RejectTransaction(ctx *gin.Context) {
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input ReviewInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.RejectTransaction(transactionUUID, input.Reason)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "reject transaction", "transaction": transaction})
+}
@@ -18,6 +18,9 @@ type RegisterInput struct {
 
 type Controller struct {
 	System core.PaymentSystem
+	// PayoutSecret authenticates the payout gateway callbacks, they are
+	// rejected when it is empty.
+	PayoutSecret string
 }
 
 func NewHttpController(system core.PaymentSystem) Controller {
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"payment/core"
+	"payment/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	CSV_CONTENT_TYPE = "text/csv"
+	BATCH_FILE       = "file"
+
+	CSVHeaderError = "csv has to start with a header containing amount and destination_uuid or destination_iban"
+)
+
+type BatchTransferInput struct {
+	DestinationUUID string `json:"destination_uuid"`
+	DestinationIBAN string `json:"destination_iban"`
+	Amount          string `json:"amount" binding:"required"`
+}
+
+type BatchInput struct {
+	Mode      string               `json:"mode"`
+	Transfers []BatchTransferInput `json:"transfers" binding:"required"`
+}
+
+// batchInput reads the batch from JSON, a CSV body or a CSV file uploaded as
+// multipart form; for CSV the mode is taken from the query or the form.
+func batchInput(ctx *gin.Context) (BatchInput, error) {
+	switch ctx.ContentType() {
+	case CSV_CONTENT_TYPE:
+		transfers, err := readCSVTransfers(ctx.Request.Body)
+		return BatchInput{Mode: ctx.Query("mode"), Transfers: transfers}, err
+	case gin.MIMEMultipartPOSTForm:
+		file, err := ctx.FormFile(BATCH_FILE)
+		if err != nil {
+			return BatchInput{}, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return BatchInput{}, err
+		}
+		defer f.Close()
+		transfers, err := readCSVTransfers(f)
+		return BatchInput{Mode: ctx.DefaultPostForm("mode", ctx.Query("mode")), Transfers: transfers}, err
+	}
+	var input BatchInput
+	err := ctx.ShouldBindJSON(&input)
+	return input, err
+}
+
+// readCSVTransfers reads rows of destination_uuid, destination_iban and
+// amount columns in the order given by the header.
+func readCSVTransfers(r io.Reader) ([]BatchTransferInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(CSVHeaderError)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasUUID := columns["destination_uuid"]
+	_, hasIBAN := columns["destination_iban"]
+	if _, ok := columns["amount"]; !ok || (!hasUUID && !hasIBAN) {
+		return nil, errors.New(CSVHeaderError)
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	transfers := make([]BatchTransferInput, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, BatchTransferInput{
+			DestinationUUID: field(record, "destination_uuid"),
+			DestinationIBAN: field(record, "destination_iban"),
+			Amount:          field(record, "amount"),
+		})
+	}
+	return transfers, nil
+}
+
+func (c *Controller) SendBatch(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input, err := batchInput(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Mode == "" {
+		input.Mode = core.ATOMIC
+	}
+	items := make([]core.BatchItem, len(input.Transfers))
+	for i, transfer := range input.Transfers {
+		if transfer.DestinationUUID == "" && transfer.DestinationIBAN == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": DestinationError, "index": i})
+			return
+		}
+		items[i].DestinationIBAN = transfer.DestinationIBAN
+		if transfer.DestinationUUID != "" {
+			items[i].DestinationUUID, err = uuid.Parse(transfer.DestinationUUID)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+				return
+			}
+		}
+		items[i].Amount, err = models.ParseMoney(transfer.Amount)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+			return
+		}
+	}
+	results, err := c.System.SendBatch(userUUID, accountUUID, items, input.Mode)
+	var batchErr *core.BatchError
+	if errors.As(err, &batchErr) {
+		var limitErr *core.LimitError
+		if errors.As(err, &limitErr) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": core.ErrLimitExceeded.Error(), "limit": limitErr, "index": batchErr.Index})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": batchErr.Err.Error(), "index": batchErr.Index})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "send batch", "mode": input.Mode, "results": results})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"payment/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FeeTierInput struct {
+	UpTo        string `json:"up_to"`
+	Flat        string `json:"flat"`
+	BasisPoints uint   `json:"basis_points"`
+}
+
+type FeeScheduleInput struct {
+	AccountType string         `json:"account_type"`
+	Currency    string         `json:"currency"`
+	Kind        string         `json:"kind" binding:"required"`
+	Flat        string         `json:"flat"`
+	BasisPoints uint           `json:"basis_points"`
+	Min         string         `json:"min"`
+	Max         string         `json:"max"`
+	Tiers       []FeeTierInput `json:"tiers"`
+}
+
+func (c *Controller) SetFeeSchedule(ctx *gin.Context) {
+	var input FeeScheduleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule := models.FeeSchedule{
+		AccountType: input.AccountType,
+		Currency:    input.Currency,
+		Kind:        input.Kind,
+		BasisPoints: input.BasisPoints,
+		Tiers:       make([]models.FeeTier, len(input.Tiers)),
+	}
+	type moneyField struct {
+		value  string
+		amount *models.Money
+	}
+	fields := []moneyField{{input.Flat, &schedule.Flat}, {input.Min, &schedule.Min}, {input.Max, &schedule.Max}}
+	for i, tier := range input.Tiers {
+		schedule.Tiers[i].BasisPoints = tier.BasisPoints
+		fields = append(fields, moneyField{tier.UpTo, &schedule.Tiers[i].UpTo}, moneyField{tier.Flat, &schedule.Tiers[i].Flat})
+	}
+	var err error
+	for _, field := range fields {
+		*field.amount, err = parseOptionalMoney(field.value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	schedule, err = c.System.SetFeeSchedule(schedule)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "set fee schedule", "fee_schedule": schedule})
+}
+
+func (c *Controller) GetFeeSchedules(ctx *gin.Context) {
+	schedules, err := c.System.GetFeeSchedules()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"fee_schedules": schedules})
+}
+
+func (c *Controller) DeleteFeeSchedule(ctx *gin.Context) {
+	UUIDstr := ctx.Param("fee_uuid")
+	scheduleUUID, err := uuid.Parse(UUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = c.System.DeleteFeeSchedule(scheduleUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "fee schedule is deleted"})
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"net/http"
+	"payment/core"
+	"payment/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const WrongTTLError = "ttl has to be a positive duration like 72h"
+
+type HoldInput struct {
+	DestinationUUID string `json:"destination_uuid"`
+	DestinationIBAN string `json:"destination_iban"`
+	Amount          string `json:"amount" binding:"required"`
+	Description     string `json:"description"`
+	TTL             string `json:"ttl"`
+}
+
+func (c *Controller) AuthorizeHold(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input HoldInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.DestinationUUID == "" && input.DestinationIBAN == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": DestinationError})
+		return
+	}
+	h := core.Hold{
+		SourceUUID:      accountUUID,
+		DestinationIBAN: input.DestinationIBAN,
+		Description:     input.Description,
+	}
+	h.Amount, err = models.ParseMoney(input.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.DestinationUUID != "" {
+		h.DestinationUUID, err = uuid.Parse(input.DestinationUUID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.TTL != "" {
+		h.TTL, err = time.ParseDuration(input.TTL)
+		if err != nil || h.TTL <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": WrongTTLError})
+			return
+		}
+	}
+	hold, err := c.System.AuthorizeHold(h)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "authorize hold", "hold": hold})
+}
+
+func (c *Controller) GetHolds(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + ASC
+	holds, err := c.System.GetHolds(accountUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"holds": holds})
+}
+
+func (c *Controller) GetHold(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holdUUIDstr := ctx.Param("hold_uuid")
+	holdUUID, err := uuid.Parse(holdUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hold, err := c.System.GetHold(accountUUID, holdUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"hold": hold})
+}
+
+func (c *Controller) CaptureHold(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holdUUIDstr := ctx.Param("hold_uuid")
+	holdUUID, err := uuid.Parse(holdUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// an omitted amount captures the rest of the hold
+	amount, err := refundAmount(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.CaptureHold(accountUUID, holdUUID, amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hold, err := c.System.GetHold(accountUUID, holdUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "capture hold", "hold": hold, "transaction": transaction})
+}
+
+func (c *Controller) VoidHold(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holdUUIDstr := ctx.Param("hold_uuid")
+	holdUUID, err := uuid.Parse(holdUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hold, err := c.System.VoidHold(accountUUID, holdUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "void hold", "hold": hold})
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (c *Controller) GetLedger(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	order := ctx.DefaultQuery("order", "asc")
+	order = strings.ToLower(order)
+	if !(order == DESC || order == ASC) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + order
+	entries, err := c.System.GetLedgerEntries(accountUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	balance, err := c.System.LedgerBalance(accountUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	consistent := c.System.CheckLedger(accountUUID) == nil
+	ctx.JSON(http.StatusOK, gin.H{"entries": entries, "ledger_balance": balance, "consistent": consistent})
+}
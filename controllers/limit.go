@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"payment/core"
+	"payment/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type LimitInput struct {
+	AccountUUID string `json:"account_uuid"`
+	Currency    string `json:"currency"`
+	MaxTransfer string `json:"max_transfer"`
+	Daily       string `json:"daily"`
+	Monthly     string `json:"monthly"`
+}
+
+// transferError responds with 422 and the remaining allowance when the
+// transfer exceeds a limit, other errors are bad requests.
+func transferError(ctx *gin.Context, err error) {
+	var limitErr *core.LimitError
+	if errors.As(err, &limitErr) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": core.ErrLimitExceeded.Error(), "limit": limitErr})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// parseOptionalMoney returns zero, which means no limit or no fee, for an empty string.
+func parseOptionalMoney(s string) (models.Money, error) {
+	if s == "" {
+		return models.Money{}, nil
+	}
+	return models.ParseMoney(s)
+}
+
+func (c *Controller) SetLimit(ctx *gin.Context) {
+	UUIDstr := ctx.Param("target_uuid")
+	userUUID, err := uuid.Parse(UUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input LimitInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := models.Limit{
+		UserUUID: userUUID,
+		Currency: input.Currency,
+	}
+	if input.AccountUUID != "" {
+		limit.AccountUUID, err = uuid.Parse(input.AccountUUID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for _, field := range []struct {
+		value  string
+		amount *models.Money
+	}{{input.MaxTransfer, &limit.MaxTransfer}, {input.Daily, &limit.Daily}, {input.Monthly, &limit.Monthly}} {
+		*field.amount, err = parseOptionalMoney(field.value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	limit, err = c.System.SetLimit(limit)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "set limit", "limit": limit})
+}
+
+func (c *Controller) GetLimits(ctx *gin.Context) {
+	UUIDstr := ctx.Param("target_uuid")
+	userUUID, err := uuid.Parse(UUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limits, err := c.System.GetLimits(userUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"limits": limits})
+}
+
+func (c *Controller) DeleteLimit(ctx *gin.Context) {
+	UUIDstr := ctx.Param("target_uuid")
+	userUUID, err := uuid.Parse(UUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limitUUIDstr := ctx.Param("limit_uuid")
+	limitUUID, err := uuid.Parse(limitUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = c.System.DeleteLimit(userUUID, limitUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "limit is deleted"})
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"payment/core"
+	"payment/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const PayerError = "email or iban of the payer is required"
+
+type PaymentRequestInput struct {
+	Email       string `json:"email"`
+	IBAN        string `json:"iban"`
+	Amount      string `json:"amount" binding:"required"`
+	Description string `json:"description"`
+	TTL         string `json:"ttl"`
+}
+
+type AcceptRequestInput struct {
+	AccountUUID string `json:"account_uuid"`
+	PocketUUID  string `json:"pocket_uuid"`
+}
+
+type DeclineRequestInput struct {
+	Reason string `json:"reason"`
+}
+
+func (c *Controller) RequestMoney(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input PaymentRequestInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Email == "" && input.IBAN == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": PayerError})
+		return
+	}
+	r := core.PaymentRequest{
+		RequesterUUID: userUUID,
+		AccountUUID:   accountUUID,
+		PayerEmail:    input.Email,
+		PayerIBAN:     input.IBAN,
+		Description:   input.Description,
+	}
+	r.Amount, err = models.ParseMoney(input.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.TTL != "" {
+		r.TTL, err = time.ParseDuration(input.TTL)
+		if err != nil || r.TTL <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": WrongTTLError})
+			return
+		}
+	}
+	request, err := c.System.RequestMoney(r)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "request money", "request": request})
+}
+
+func (c *Controller) GetPaymentRequests(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + DESC
+	requests, err := c.System.GetPaymentRequests(accountUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+func (c *Controller) GetPaymentRequest(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestUUIDstr := ctx.Param("request_uuid")
+	requestUUID, err := uuid.Parse(requestUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request, err := c.System.GetPaymentRequest(accountUUID, requestUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"request": request})
+}
+
+func (c *Controller) CancelPaymentRequest(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestUUIDstr := ctx.Param("request_uuid")
+	requestUUID, err := uuid.Parse(requestUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request, err := c.System.CancelPaymentRequest(accountUUID, requestUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "cancel payment request", "request": request})
+}
+
+func (c *Controller) RemindPaymentRequest(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestUUIDstr := ctx.Param("request_uuid")
+	requestUUID, err := uuid.Parse(requestUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request, err := c.System.RemindPaymentRequest(accountUUID, requestUUID, time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "remind payment request", "request": request})
+}
+
+func (c *Controller) GetIncomingPaymentRequests(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = UPDATED + " " + DESC
+	requests, err := c.System.GetIncomingPaymentRequests(userUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+func (c *Controller) AcceptPaymentRequest(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestUUIDstr := ctx.Param("request_uuid")
+	requestUUID, err := uuid.Parse(requestUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input AcceptRequestInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sourceUUID, err := parseOptionalUUID(input.AccountUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pocketUUID, err := parseOptionalUUID(input.PocketUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.AcceptPaymentRequest(userUUID, requestUUID, sourceUUID, pocketUUID)
+	if err != nil {
+		transferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "accept payment request", "transaction": transaction})
+}
+
+func (c *Controller) DeclinePaymentRequest(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestUUIDstr := ctx.Param("request_uuid")
+	requestUUID, err := uuid.Parse(requestUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input DeclineRequestInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request, err := c.System.DeclinePaymentRequest(userUUID, requestUUID, input.Reason)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "decline payment request", "request": request})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+	"payment/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	PAYOUT_SUCCEEDED = "succeeded"
+	PAYOUT_FAILED    = "failed"
+)
+
+type WithdrawInput struct {
+	IBAN   string `json:"iban" binding:"required"`
+	Amount string `json:"amount" binding:"required"`
+}
+
+type PayoutCallbackInput struct {
+	Status string `json:"status" binding:"required,oneof=succeeded failed"`
+	Reason string `json:"reason"`
+}
+
+func (c *Controller) Withdraw(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input WithdrawInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount, err := models.ParseMoney(input.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.Withdraw(accountUUID, input.IBAN, amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "withdraw", "transaction": transaction})
+}
+
+// PayoutCallback is called by the payout gateway with the result of the payout.
+func (c *Controller) PayoutCallback(ctx *gin.Context) {
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input PayoutCallbackInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.CompletePayout(transactionUUID, input.Status == PAYOUT_SUCCEEDED, input.Reason)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "payout callback", "transaction": transaction})
+}
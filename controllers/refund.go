@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"payment/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RefundInput struct {
+	Amount string `json:"amount"`
+}
+
+// refundAmount returns zero when the amount is omitted, which means a full refund.
+func refundAmount(ctx *gin.Context) (models.Money, error) {
+	var input RefundInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		return models.Money{}, err
+	}
+	if input.Amount == "" {
+		return models.Money{}, nil
+	}
+	return models.ParseMoney(input.Amount)
+}
+
+func (c *Controller) RefundTransaction(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount, err := refundAmount(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	refund, err := c.System.RefundReceivedTransaction(accountUUID, transactionUUID, amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "refund transaction", "transaction": refund})
+}
+
+func (c *Controller) AdminRefundTransaction(ctx *gin.Context) {
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount, err := refundAmount(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	refund, err := c.System.RefundTransaction(transactionUUID, amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "refund transaction", "transaction": refund})
+}
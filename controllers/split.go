@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+	"payment/core"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SplitShareInput struct {
+	Email  string `json:"email"`
+	IBAN   string `json:"iban"`
+	Weight uint   `json:"weight"`
+	Amount string `json:"amount"`
+}
+
+type SplitInput struct {
+	Mode   string            `json:"mode" binding:"required"`
+	Shares []SplitShareInput `json:"shares" binding:"required,dive"`
+	// OwnWeight is one when it is omitted.
+	OwnWeight   *uint  `json:"own_weight"`
+	Description string `json:"description"`
+	TTL         string `json:"ttl"`
+}
+
+func (c *Controller) SplitTransaction(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input SplitInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s := core.Split{
+		UserUUID:        userUUID,
+		AccountUUID:     accountUUID,
+		TransactionUUID: transactionUUID,
+		Mode:            strings.ToLower(input.Mode),
+		OwnWeight:       1,
+		Description:     input.Description,
+	}
+	if input.OwnWeight != nil {
+		s.OwnWeight = *input.OwnWeight
+	}
+	if input.TTL != "" {
+		s.TTL, err = time.ParseDuration(input.TTL)
+		if err != nil || s.TTL <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": WrongTTLError})
+			return
+		}
+	}
+	for _, share := range input.Shares {
+		if share.Email == "" && share.IBAN == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": PayerError})
+			return
+		}
+		amount, err := parseOptionalMoney(share.Amount)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s.Shares = append(s.Shares, core.SplitShare{
+			PayerEmail: share.Email,
+			PayerIBAN:  share.IBAN,
+			Weight:     share.Weight,
+			Amount:     amount,
+		})
+	}
+	summary, err := c.System.SplitTransaction(s)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "split transaction", "summary": summary})
+}
+
+func (c *Controller) GetSplits(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + DESC
+	splits, err := c.System.GetSplits(accountUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"splits": splits})
+}
+
+func (c *Controller) GetSplit(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	splitUUIDstr := ctx.Param("split_uuid")
+	splitUUID, err := uuid.Parse(splitUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	summary, err := c.System.GetSplit(accountUUID, splitUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"summary": summary})
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"net/http"
+	"payment/core"
+	"payment/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type StandingOrderInput struct {
+	DestinationUUID string `json:"destination_uuid" binding:"required"`
+	Amount          string `json:"amount" binding:"required"`
+	Interval        string `json:"interval" binding:"required"`
+	StartAt         string `json:"start_at"`
+	EndAt           string `json:"end_at"`
+	Count           string `json:"count"`
+}
+
+type UpdateStandingOrderInput struct {
+	DestinationUUID string `json:"destination_uuid"`
+	Amount          string `json:"amount"`
+	Interval        string `json:"interval"`
+	EndAt           string `json:"end_at"`
+	Count           string `json:"count"`
+}
+
+// parseStandingOrder converts the optional fields of the input; empty strings keep zero values.
+func parseStandingOrder(destination, amountStr, endAtStr, countStr string) (core.StandingOrder, error) {
+	var so core.StandingOrder
+	var err error
+	if destination != "" {
+		so.DestinationUUID, err = uuid.Parse(destination)
+		if err != nil {
+			return core.StandingOrder{}, err
+		}
+	}
+	if amountStr != "" {
+		so.Amount, err = models.ParseMoney(amountStr)
+		if err != nil {
+			return core.StandingOrder{}, err
+		}
+	}
+	if endAtStr != "" {
+		endAt, err := time.Parse(time.RFC3339, endAtStr)
+		if err != nil {
+			return core.StandingOrder{}, err
+		}
+		so.EndAt = &endAt
+	}
+	if countStr != "" {
+		count, err := strconv.ParseUint(countStr, 10, 32)
+		if err != nil {
+			return core.StandingOrder{}, err
+		}
+		so.MaxRuns = uint(count)
+	}
+	return so, nil
+}
+
+func (c *Controller) NewStandingOrder(ctx *gin.Context) {
+	userUUIDstr := ctx.Param("user_uuid")
+	userUUID, err := uuid.Parse(userUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input StandingOrderInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	so, err := parseStandingOrder(input.DestinationUUID, input.Amount, input.EndAt, input.Count)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.StartAt != "" {
+		so.StartAt, err = time.Parse(time.RFC3339, input.StartAt)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	so.UserUUID = userUUID
+	so.SourceUUID = accountUUID
+	so.Interval = input.Interval
+	order, err := c.System.NewStandingOrder(so)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "create standing order", "standing_order": order})
+}
+
+func (c *Controller) GetStandingOrders(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + ASC
+	orders, err := c.System.GetStandingOrders(accountUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"standing_orders": orders})
+}
+
+func (c *Controller) GetStandingOrder(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderUUIDstr := ctx.Param("order_uuid")
+	orderUUID, err := uuid.Parse(orderUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	order, err := c.System.GetStandingOrder(accountUUID, orderUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"standing_order": order})
+}
+
+func (c *Controller) UpdateStandingOrder(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderUUIDstr := ctx.Param("order_uuid")
+	orderUUID, err := uuid.Parse(orderUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input UpdateStandingOrderInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	so, err := parseStandingOrder(input.DestinationUUID, input.Amount, input.EndAt, input.Count)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	so.Interval = input.Interval
+	order, err := c.System.UpdateStandingOrder(accountUUID, orderUUID, so)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "update standing order", "standing_order": order})
+}
+
+func (c *Controller) CancelStandingOrder(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderUUIDstr := ctx.Param("order_uuid")
+	orderUUID, err := uuid.Parse(orderUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	order, err := c.System.CancelStandingOrder(accountUUID, orderUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "cancel standing order", "standing_order": order})
+}
+
+func (c *Controller) GetStandingOrderRuns(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderUUIDstr := ctx.Param("order_uuid")
+	orderUUID, err := uuid.Parse(orderUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := query(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": UnknownQueryError})
+		return
+	}
+	query.Sort = CREATED + " " + DESC
+	runs, err := c.System.GetStandingOrderRuns(accountUUID, orderUUID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"runs": runs})
+}
@@ -3,8 +3,9 @@ package controllers
 import (
 	"net/http"
 	"payment/core"
-	"strconv"
+	"payment/models"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -15,9 +16,14 @@ const (
 	UPDATED = "updated_at"
 )
 
+const DestinationError = "destination_uuid or destination_iban is required"
+
 type TransactionInput struct {
-	DestinationUUID string `json:"destination_uuid" binding:"required"`
+	DestinationUUID string `json:"destination_uuid"`
+	DestinationIBAN string `json:"destination_iban"`
+	PocketUUID      string `json:"pocket_uuid"`
 	Amount          string `json:"amount" binding:"required"`
+	ExecuteAt       string `json:"execute_at"`
 }
 
 func (c *Controller) NewTransaction(ctx *gin.Context) {
@@ -38,16 +44,11 @@ func (c *Controller) NewTransaction(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	destinationUUID, err := uuid.Parse(input.DestinationUUID)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if input.DestinationUUID == "" && input.DestinationIBAN == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": DestinationError})
 		return
 	}
-	amount, err := strconv.ParseUint(input.Amount, 10, 32)
+	amount, err := models.ParseMoney(input.Amount)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -55,12 +56,39 @@ func (c *Controller) NewTransaction(ctx *gin.Context) {
 	tr := core.Transaction{
 		UserUUID:        userUUID,
 		SourceUUID:      accountUUID,
-		DestinationUUID: destinationUUID,
-		Amount:          uint(amount),
+		DestinationIBAN: input.DestinationIBAN,
+		Amount:          amount,
+	}
+	if input.DestinationUUID != "" {
+		tr.DestinationUUID, err = uuid.Parse(input.DestinationUUID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.PocketUUID != "" {
+		tr.PocketUUID, err = uuid.Parse(input.PocketUUID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.ExecuteAt != "" {
+		tr.ExecuteAt, err = time.Parse(time.RFC3339, input.ExecuteAt)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// the scheduled transaction is sent without another request
+		err = c.System.CheckAccountPermission(userUUID, accountUUID, core.SEND)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 	}
 	transaction, err := c.System.NewTransaction(tr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		transferError(ctx, err)
 		return
 	}
 
@@ -109,18 +137,74 @@ func (c *Controller) GetTransactions(ctx *gin.Context) {
 }
 
 func (c *Controller) SendTransaction(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	transactionUUIDstr := ctx.Param("transaction_uuid")
 	transactionUUID, err := uuid.Parse(transactionUUIDstr)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	transaction, err := c.System.SendTransaction(transactionUUID)
+	transaction, err := c.System.SendTransaction(accountUUID, transactionUUID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		transferError(ctx, err)
+		return
+	}
+	if transaction.Status == core.PENDING_APPROVAL {
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "transaction is waiting for approval", "transaction": transaction})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "sent transaction", "transaction": transaction})
 
 }
+
+func (c *Controller) CancelTransaction(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction, err := c.System.CancelTransaction(accountUUID, transactionUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "cancelled transaction", "transaction": transaction})
+
+}
+
+func (c *Controller) GetTransaction(ctx *gin.Context) {
+	accountUUIDstr := ctx.Param("account_uuid")
+	accountUUID, err := uuid.Parse(accountUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transactionUUIDstr := ctx.Param("transaction_uuid")
+	transactionUUID, err := uuid.Parse(transactionUUIDstr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	details, err := c.System.GetTransaction(accountUUID, transactionUUID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"transaction": details.Transaction, "history": details.History, "refunds": details.Refunds})
+
+}
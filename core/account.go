@@ -1,8 +1,12 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"payment/models"
+	"payment/repository"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -11,50 +15,165 @@ const (
 	ACTIVE    = "active"
 	BLOCKED   = "blocked"
 	REQUESTED = "requested-unblock"
+
+	PERSONAL = "personal"
+	BUSINESS = "business"
+	SAVINGS  = "savings"
 )
 
-var ErrUnblock = errors.New("account isn't blocked")
+var (
+	ErrUnblock            = errors.New("account isn't blocked")
+	ErrAccountBlocked     = errors.New("account is blocked")
+	ErrUnknownAccountType = errors.New("unknown account type")
+)
 
-func (p *PaymentSystem) NewAccount(userUUID uuid.UUID) (models.Account, error) {
+func validAccountType(accountType string) bool {
+	return accountType == PERSONAL || accountType == BUSINESS || accountType == SAVINGS
+}
+
+func (p *PaymentSystem) NewAccount(userUUID uuid.UUID, currency, accountType string) (models.Account, error) {
 	user, err := p.Repo.GetUserByUUID(userUUID)
 	if err != nil {
 		return models.Account{}, err
 	}
+	if accountType == "" {
+		accountType = PERSONAL
+	}
+	if !validAccountType(accountType) {
+		return models.Account{}, ErrUnknownAccountType
+	}
+	if currency == "" {
+		currency = DEFAULT_CURRENCY
+	}
+	currency = strings.ToUpper(currency)
+	if !p.validCurrency(currency) {
+		return models.Account{}, ErrUnknownCurrency
+	}
 	account := models.Account{}
 	account.UserUUID = user.UUID
-	account.IBAN, err = randToken(29)
+	account.Currency = currency
+	account.Type = accountType
+	account.Balance = models.NewMoney(0, models.CurrencyExponent(currency))
+	account.HeldAmount = account.Balance
+	account.PocketAmount = account.Balance
+	account.OverdraftLimit = account.Balance
 	account.Status = ACTIVE
+	account.UUID, err = uuid.NewRandom()
 	if err != nil {
 		return models.Account{}, err
 	}
-	account.UUID, err = uuid.NewRandom()
+	// a random account number can collide with an existing one, the unique
+	// index rejects it and another IBAN is tried
+	for attempt := 0; attempt < MAX_IBAN_ATTEMPTS; attempt++ {
+		account.IBAN, err = p.IBANs.Generate()
+		if err != nil {
+			return models.Account{}, err
+		}
+		err = p.Repo.CreateAccount(&account)
+		if !errors.Is(err, repository.ErrorDuplicateIBAN) {
+			break
+		}
+	}
 	if err != nil {
 		return models.Account{}, err
 	}
-	err = p.Repo.CreateAccount(&account)
+	return account, nil
+}
+
+// BackfillIBANs gives an IBAN to the accounts whose stored IBAN isn't valid,
+// like the random tokens of the accounts opened before the IBANs, and returns
+// how many of them got one.
+func (p *PaymentSystem) BackfillIBANs() (int, error) {
+	accounts, err := p.Repo.GetAccounts()
 	if err != nil {
-		return models.Account{}, err
+		return 0, err
 	}
-	return account, err
+	filled := 0
+	for _, account := range accounts {
+		if _, err := ValidateIBAN(account.IBAN); err == nil {
+			continue
+		}
+		for attempt := 0; attempt < MAX_IBAN_ATTEMPTS; attempt++ {
+			var iban string
+			iban, err = p.IBANs.Generate()
+			if err != nil {
+				return filled, err
+			}
+			err = p.Repo.UpdateAccountIBAN(account.UUID, iban)
+			if !errors.Is(err, repository.ErrorDuplicateIBAN) {
+				break
+			}
+		}
+		if err != nil {
+			return filled, err
+		}
+		filled++
+	}
+	return filled, nil
 }
 
+// normalizeIBAN drops the spaces IBANs are usually printed with.
+func normalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(iban), " ", ""))
+}
+
+// CheckAccountExists returns an error unless the user is the holder or an
+// active member of the account.
 func (p *PaymentSystem) CheckAccountExists(userUUID, accountUUID uuid.UUID) error {
-	account, err := p.Repo.GetAccountByUUID(accountUUID)
-	if err != nil {
-		return err
+	return p.CheckAccountPermission(userUUID, accountUUID, VIEW)
+}
+
+// lockAccounts locks the rows of the accounts until the end of the repository
+// transaction. The locks are taken in the order of the UUIDs, so transfers
+// between the same accounts in opposite directions can't deadlock; system
+// accounts have no rows to lock.
+func lockAccounts(repo repository.Repository, accountUUIDs ...uuid.UUID) error {
+	sorted := make([]uuid.UUID, 0, len(accountUUIDs))
+	for _, accountUUID := range accountUUIDs {
+		if !isSystemAccount(accountUUID) {
+			sorted = append(sorted, accountUUID)
+		}
 	}
-	if account.UserUUID != userUUID {
-		return ErrUnknownAccount
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	for i, accountUUID := range sorted {
+		if i > 0 && accountUUID == sorted[i-1] {
+			continue
+		}
+		if _, err := repo.GetAccountForUpdate(accountUUID); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *PaymentSystem) checkAmount(accountUUID uuid.UUID, amount uint) error {
-	account, err := p.Repo.GetAccountByUUID(accountUUID)
+// available returns the balance of the main pocket of the account without the
+// amount reserved by holds and with the overdraft limit.
+func available(account *models.Account) (models.Money, error) {
+	balance, err := account.Balance.Sub(account.HeldAmount)
+	if err != nil {
+		return models.Money{}, err
+	}
+	balance, err = balance.Sub(account.PocketAmount)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return balance.Add(account.OverdraftLimit)
+}
+
+// checkAmount returns ErrInsufficientFunds when the available balance of the
+// account is less than the amount.
+func checkAmount(repo repository.Repository, accountUUID uuid.UUID, amount models.Money) error {
+	account, err := repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return err
+	}
+	balance, err := available(account)
 	if err != nil {
 		return err
 	}
-	if amount <= account.Balance {
+	if amount.Cmp(balance) <= 0 {
 		return nil
 	}
 	return ErrInsufficientFunds
@@ -64,19 +183,67 @@ func (p *PaymentSystem) GetAccounts(userUUID uuid.UUID, query models.QueryParams
 	return p.Repo.GetAccountsForUser(userUUID, query)
 }
 
-func (p *PaymentSystem) AddMoney(accountUUID uuid.UUID, amount uint) (models.Account, error) {
-	p.Repo.IncBalance(accountUUID, amount)
+// AddMoney credits the account with the amount, which has to be positive.
+func (p *PaymentSystem) AddMoney(accountUUID uuid.UUID, amount models.Money) (models.Account, error) {
+	if !amount.IsPositive() {
+		return models.Account{}, ErrWrongAmount
+	}
+	return p.adjustBalance(accountUUID, amount, DEPOSIT)
+}
+
+// DebitAccount takes the amount, which has to be positive, from the account by
+// an adjustment without a fee, limits or approval, so it is only for admins.
+func (p *PaymentSystem) DebitAccount(accountUUID uuid.UUID, amount models.Money) (models.Account, error) {
+	if !amount.IsPositive() {
+		return models.Account{}, ErrWrongAmount
+	}
+	return p.adjustBalance(accountUUID, amount, ADJUSTMENT)
+}
+
+// adjustBalance moves the amount between the account and the external world,
+// a DEPOSIT credits the account and an ADJUSTMENT debits it.
+func (p *PaymentSystem) adjustBalance(accountUUID uuid.UUID, amount models.Money, kind string) (models.Account, error) {
 	account, err := p.Repo.GetAccountByUUID(accountUUID)
 	if err != nil {
 		return models.Account{}, err
 	}
+	amount, err = amount.Rescale(models.CurrencyExponent(account.Currency))
+	if err != nil {
+		return models.Account{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			journalUUID, err := uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			if kind == ADJUSTMENT {
+				err = checkAmount(repo, accountUUID, amount)
+				if err != nil {
+					return err
+				}
+				return move(repo, journalUUID, uuid.Nil, ADJUSTMENT, accountUUID, EXTERNAL_ACCOUNT, amount)
+			}
+			return move(repo, journalUUID, uuid.Nil, DEPOSIT, EXTERNAL_ACCOUNT, accountUUID, amount)
+		})
+	if err != nil {
+		return models.Account{}, err
+	}
+	account, err = p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return models.Account{}, err
+	}
 	return *account, nil
 }
 
-func (p *PaymentSystem) ShowBalance(accountUUID uuid.UUID) (uint, error) {
+func (p *PaymentSystem) ShowBalance(accountUUID uuid.UUID) (models.Money, error) {
 	account, err := p.Repo.GetAccountByUUID(accountUUID)
 	if err != nil {
-		return 0, err
+		return models.Money{}, err
 	}
 	return account.Balance, nil
 
@@ -88,10 +255,16 @@ func (p *PaymentSystem) GetAccount(accountUUID uuid.UUID) (models.Account, error
 }
 
 func (p *PaymentSystem) BlockAccount(accountUUID uuid.UUID) error {
+	if err := checkOpen(p.Repo, accountUUID); err != nil {
+		return err
+	}
 	return p.Repo.UpdateStatusAccount(accountUUID, BLOCKED)
 }
 
 func (p *PaymentSystem) UnblockAccount(accountUUID uuid.UUID) error {
+	if err := checkOpen(p.Repo, accountUUID); err != nil {
+		return err
+	}
 	return p.Repo.UpdateStatusAccount(accountUUID, ACTIVE)
 }
 
@@ -116,6 +289,14 @@ func (p *PaymentSystem) IsBlockedAccount(accountUUID uuid.UUID) (bool, error) {
 	}
 	return false, nil
 }
+func (p *PaymentSystem) IsClosedAccount(accountUUID uuid.UUID) (bool, error) {
+	account, err := p.GetAccount(accountUUID)
+	if err != nil {
+		return false, err
+	}
+	return account.Status == CLOSED, nil
+}
+
 func (p *PaymentSystem) IsActiveAccount(accountUUID uuid.UUID) (bool, error) {
 	account, err := p.GetAccount(accountUUID)
 	if err != nil {
@@ -130,3 +311,19 @@ func (p *PaymentSystem) IsActiveAccount(accountUUID uuid.UUID) (bool, error) {
 func (p *PaymentSystem) GetAccountsRequested(query models.QueryParams) ([]models.Account, error) {
 	return p.Repo.GetAccountsByStatus(REQUESTED, query)
 }
+
+// checkActive returns ErrAccountClosed or ErrAccountBlocked unless the account
+// is active, so money can't leave it by the routes without CheckBlockedAccount.
+func checkActive(repo repository.Repository, accountUUID uuid.UUID) error {
+	account, err := repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return err
+	}
+	switch account.Status {
+	case ACTIVE:
+		return nil
+	case CLOSED:
+		return ErrAccountClosed
+	}
+	return ErrAccountBlocked
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PENDING_APPROVAL = "pending-approval"
+	REJECTED         = "rejected"
+)
+
+var (
+	ErrTransactionNotPending = errors.New("transaction is not waiting for approval")
+	ErrRejectReason          = errors.New("reason of the rejection is required")
+)
+
+// needsApproval reports whether the transaction is above the approval threshold.
+func (p *PaymentSystem) needsApproval(transaction *models.Transaction) (bool, error) {
+	if !p.ApprovalThreshold.IsPositive() {
+		return false, nil
+	}
+	amount, err := p.convert(transaction.Amount, transaction.Currency, DEFAULT_CURRENCY)
+	if err != nil {
+		return false, err
+	}
+	return amount.Cmp(p.ApprovalThreshold) > 0, nil
+}
+
+func (p *PaymentSystem) GetTransactionsPendingApproval(query models.QueryParams) ([]models.Transaction, error) {
+	return p.Repo.GetTransactionsForStatus(PENDING_APPROVAL, query)
+}
+
+// ApproveTransaction moves the money of the transaction waiting for approval,
+// an approved payout is submitted to the gateway.
+func (p *PaymentSystem) ApproveTransaction(transactionUUID uuid.UUID, reason string) (models.Transaction, error) {
+	if reason == "" {
+		reason = "approved"
+	}
+	transaction, err := p.review(transactionUUID, func(repo repository.Repository, transaction *models.Transaction) error {
+		if isPayout(transaction) && p.Payouts == nil {
+			return ErrNoPayoutGateway
+		}
+		total, err := debited(transaction)
+		if err != nil {
+			return err
+		}
+		err = checkFunds(repo, transaction, total)
+		if err != nil {
+			return err
+		}
+		// the limits may have been used up while the transaction waited
+		err = p.checkLimits(repo, transaction, time.Now())
+		if err != nil {
+			return err
+		}
+		if isPayout(transaction) {
+			return startPayout(repo, transaction, reason)
+		}
+		return execute(repo, transaction, TRANSFER, reason)
+	})
+	if err != nil || !isPayout(&transaction) {
+		return transaction, err
+	}
+	return p.submitPayout(transaction)
+}
+
+// RejectTransaction rejects the transaction waiting for approval, the money isn't moved.
+func (p *PaymentSystem) RejectTransaction(transactionUUID uuid.UUID, reason string) (models.Transaction, error) {
+	if reason == "" {
+		return models.Transaction{}, ErrRejectReason
+	}
+	return p.review(transactionUUID, func(repo repository.Repository, transaction *models.Transaction) error {
+		return updateStatus(repo, transaction, REJECTED, reason)
+	})
+}
+
+func (p *PaymentSystem) review(transactionUUID uuid.UUID, decide func(repo repository.Repository, transaction *models.Transaction) error) (models.Transaction, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			transaction, err := repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			err = lockAccounts(repo, transaction.SourceUUID, transaction.DestinationUUID)
+			if err != nil {
+				return err
+			}
+			transaction, err = repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			if transaction.Status != PENDING_APPROVAL {
+				return ErrTransactionNotPending
+			}
+			err = decide(repo, transaction)
+			if err != nil {
+				return err
+			}
+			return settlePaymentRequest(repo, transaction)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tr, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *tr, nil
+}
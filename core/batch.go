@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ATOMIC  = "atomic"
+	PARTIAL = "partial"
+
+	MAX_BATCH_SIZE = 1000
+)
+
+var (
+	ErrBatchMode     = errors.New("batch mode has to be atomic or partial")
+	ErrBatchSize     = fmt.Errorf("batch has to contain from 1 to %d transfers", MAX_BATCH_SIZE)
+	ErrBatchApproval = errors.New("atomic batch can't contain transfers which need approval")
+)
+
+// BatchItem is one transfer of the batch, the destination is set by the UUID
+// or the IBAN.
+type BatchItem struct {
+	DestinationUUID uuid.UUID
+	DestinationIBAN string
+	Amount          models.Money
+}
+
+type BatchResult struct {
+	Index       int                 `json:"index"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// BatchError is returned when the atomic batch fails because of one of its transfers.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("transfer %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// SendBatch sends the transfers from the source account. The total of the
+// batch with fees has to be covered by the available balance. In the atomic mode either all
+// transfers are sent or none, so the batch is rejected when one of them needs
+// approval; in the partial mode every transfer is sent on its own and gets its result.
+func (p *PaymentSystem) SendBatch(userUUID, sourceUUID uuid.UUID, items []BatchItem, mode string) ([]BatchResult, error) {
+	if mode != ATOMIC && mode != PARTIAL {
+		return nil, ErrBatchMode
+	}
+	if len(items) == 0 || len(items) > MAX_BATCH_SIZE {
+		return nil, ErrBatchSize
+	}
+	source, err := p.Repo.GetAccountByUUID(sourceUUID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(items))
+	transactions := make([]*models.Transaction, len(items))
+	total := models.NewMoney(0, models.CurrencyExponent(source.Currency))
+	for i, item := range items {
+		results[i].Index = i
+		transaction, err := p.prepare(Transaction{
+			UserUUID:        userUUID,
+			SourceUUID:      sourceUUID,
+			DestinationUUID: item.DestinationUUID,
+			DestinationIBAN: item.DestinationIBAN,
+			Amount:          item.Amount,
+		})
+		if err == nil && !transaction.Amount.IsPositive() {
+			err = ErrWrongAmount
+		}
+		if err == nil && mode == ATOMIC {
+			var approval bool
+			approval, err = p.needsApproval(&transaction)
+			if err == nil && approval {
+				err = ErrBatchApproval
+			}
+		}
+		if err == nil {
+			var debit models.Money
+			debit, err = debited(&transaction)
+			if err == nil {
+				total, err = total.Add(debit)
+			}
+		}
+		if err != nil {
+			if mode == ATOMIC {
+				return nil, &BatchError{Index: i, Err: err}
+			}
+			results[i].Error = err.Error()
+			continue
+		}
+		transactions[i] = &transaction
+	}
+	balance, err := available(source)
+	if err != nil {
+		return nil, err
+	}
+	if total.Cmp(balance) > 0 {
+		return nil, ErrInsufficientFunds
+	}
+	if mode == ATOMIC {
+		err = p.Repo.Transaction(
+			func(repo repository.Repository) error {
+				// all accounts of the batch are locked at once in one order
+				accountUUIDs := []uuid.UUID{sourceUUID}
+				for _, transaction := range transactions {
+					accountUUIDs = append(accountUUIDs, transaction.DestinationUUID)
+				}
+				err := lockAccounts(repo, accountUUIDs...)
+				if err != nil {
+					return err
+				}
+				for i, prepared := range transactions {
+					// a copy, so a retry of the repository transaction creates
+					// the prepared transaction again
+					transaction := *prepared
+					err := createTransaction(repo, transaction)
+					if err != nil {
+						return &BatchError{Index: i, Err: err}
+					}
+					approval, err := p.admit(repo, &transaction)
+					if err == nil && approval {
+						err = ErrBatchApproval
+					}
+					if err == nil {
+						err = execute(repo, &transaction, TRANSFER, "")
+					}
+					if err != nil {
+						return &BatchError{Index: i, Err: err}
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for i, transaction := range transactions {
+			if transaction == nil {
+				continue
+			}
+			err := p.Repo.Transaction(
+				func(repo repository.Repository) error {
+					return createTransaction(repo, *transaction)
+				})
+			if err == nil {
+				_, err = p.send(transaction.UUID, PREPARED)
+				if err != nil {
+					if failErr := p.failTransaction(transaction.UUID, PREPARED, err.Error()); failErr != nil {
+						log.Printf("can't fail transaction %v of batch, err %v", transaction.UUID, failErr.Error())
+						err = fmt.Errorf("%w, can't fail the transaction: %v", err, failErr)
+					}
+				}
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+	}
+	for i, transaction := range transactions {
+		if transaction == nil {
+			continue
+		}
+		stored, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+		if err != nil {
+			continue
+		}
+		results[i].Transaction = stored
+	}
+	return results, nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CLOSED = "closed"
+
+var (
+	ErrAccountClosed       = errors.New("account is closed")
+	ErrPendingTransactions = errors.New("account has pending transactions or holds")
+	ErrNegativeBalance     = errors.New("balance below zero has to be repaid before closing")
+	ErrSweepDestination    = errors.New("destination is required to sweep the balance")
+	ErrSweepPending        = errors.New("sweep of the balance waits for approval, close the account again when it is approved")
+)
+
+// pendingStatuses are the statuses of the outgoing transactions which may
+// still move the money of the account.
+var pendingStatuses = []string{PREPARED, SCHEDULED, PENDING_APPROVAL, PROCESSING}
+
+// checkOpen returns ErrAccountClosed when one of the accounts is closed.
+func checkOpen(repo repository.Repository, accountUUIDs ...uuid.UUID) error {
+	for _, accountUUID := range accountUUIDs {
+		if isSystemAccount(accountUUID) {
+			continue
+		}
+		account, err := repo.GetAccountByUUID(accountUUID)
+		if err != nil {
+			return err
+		}
+		if account.Status == CLOSED {
+			return ErrAccountClosed
+		}
+	}
+	return nil
+}
+
+// CloseAccount closes the account for good. The interest is settled first and
+// the remaining balance is swept by a transaction to the destination, which is
+// only needed when the balance isn't zero; a balance below zero has to be
+// repaid before. The sweep is free of fees but passes the limits and the
+// approval like any transfer; a sweep waiting for approval leaves the account
+// open with ErrSweepPending. The account keeps its transactions and ledger,
+// its standing orders are cancelled. Incoming transactions don't block the
+// closure, they fail once the account is closed.
+func (p *PaymentSystem) CloseAccount(accountUUID, destinationUUID uuid.UUID, destinationIBAN string) (models.Account, error) {
+	sweep := models.Transaction{
+		Status:     PREPARED,
+		SourceUUID: accountUUID,
+	}
+	if destinationUUID != uuid.Nil || destinationIBAN != "" {
+		var err error
+		sweep.DestinationUUID, err = p.resolveDestination(destinationUUID, destinationIBAN)
+		if err != nil {
+			return models.Account{}, err
+		}
+		if sweep.DestinationUUID == accountUUID {
+			return models.Account{}, ErrWrongDestination
+		}
+	}
+	locked := []uuid.UUID{accountUUID}
+	if sweep.DestinationUUID != uuid.Nil {
+		locked = append(locked, sweep.DestinationUUID)
+	}
+	// the interest is settled on its own, so an overdraft charge stays on the
+	// balance when the closure fails because of it
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			err = checkOpen(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			return p.settleInterest(repo, accountUUID, time.Now())
+		})
+	if err != nil {
+		return models.Account{}, err
+	}
+	sweepPending := false
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			sweepPending = false
+			err := lockAccounts(repo, locked...)
+			if err != nil {
+				return err
+			}
+			err = checkOpen(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			pending, err := repo.CountTransactionsFromAccount(accountUUID, pendingStatuses)
+			if err != nil {
+				return err
+			}
+			account, err := repo.GetAccountByUUID(accountUUID)
+			if err != nil {
+				return err
+			}
+			if pending > 0 || !account.HeldAmount.IsZero() {
+				return ErrPendingTransactions
+			}
+			if account.Balance.IsNegative() {
+				return ErrNegativeBalance
+			}
+			// the pockets are part of the balance, it is swept as a whole
+			pockets, err := repo.GetPocketsForAccount(accountUUID)
+			if err != nil {
+				return err
+			}
+			for _, pocket := range pockets {
+				err = deletePocket(repo, accountUUID, pocket.UUID)
+				if err != nil {
+					return err
+				}
+			}
+			if account.Balance.IsPositive() {
+				if sweep.DestinationUUID == uuid.Nil {
+					return ErrSweepDestination
+				}
+				err = checkOpen(repo, sweep.DestinationUUID)
+				if err != nil {
+					return err
+				}
+				// the local copy keeps the sweep unchanged for the retries of
+				// the repository transaction
+				transaction := sweep
+				transaction.Amount = account.Balance
+				err = p.quote(&transaction)
+				if err != nil {
+					return err
+				}
+				transaction.UUID, err = uuid.NewRandom()
+				if err != nil {
+					return err
+				}
+				err = createTransaction(repo, transaction)
+				if err != nil {
+					return err
+				}
+				approval, err := p.admit(repo, &transaction)
+				if err != nil {
+					return err
+				}
+				if approval {
+					sweepPending = true
+					return updateStatus(repo, &transaction, PENDING_APPROVAL, "account closure")
+				}
+				err = execute(repo, &transaction, TRANSFER, "account closure")
+				if err != nil {
+					return err
+				}
+			}
+			err = repo.CancelStandingOrdersForAccount(accountUUID)
+			if err != nil {
+				return err
+			}
+			return repo.UpdateStatusAccount(accountUUID, CLOSED)
+		})
+	if err != nil {
+		return models.Account{}, err
+	}
+	if sweepPending {
+		return models.Account{}, ErrSweepPending
+	}
+	return p.GetAccount(accountUUID)
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"payment/models"
+	"payment/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FLAT_FEE    = "flat"
+	PERCENT_FEE = "percent"
+	TIERED_FEE  = "tiered"
+
+	FEE = "fee"
+
+	MAX_BASIS_POINTS = 10000
+)
+
+var (
+	ErrFeeKind  = errors.New("fee kind has to be flat, percent or tiered")
+	ErrWrongFee = errors.New("fee amounts can't be negative and basis points can't exceed 10000")
+	ErrFeeTiers = errors.New("fee tiers have to be sorted by up_to and the last one unbounded")
+)
+
+// revenueAccount is the system account which collects the fees in the currency.
+func revenueAccount(currency string) uuid.UUID {
+	return systemAccount("revenue:" + currency)
+}
+
+// SetFeeSchedule creates or replaces the fee schedule of the account type in the currency.
+func (p *PaymentSystem) SetFeeSchedule(schedule models.FeeSchedule) (models.FeeSchedule, error) {
+	schedule.AccountType = strings.ToLower(schedule.AccountType)
+	if schedule.AccountType != "" && !validAccountType(schedule.AccountType) {
+		return models.FeeSchedule{}, ErrUnknownAccountType
+	}
+	if schedule.Currency == "" {
+		schedule.Currency = DEFAULT_CURRENCY
+	}
+	schedule.Currency = strings.ToUpper(schedule.Currency)
+	if !p.validCurrency(schedule.Currency) {
+		return models.FeeSchedule{}, ErrUnknownCurrency
+	}
+	err := validateFeeSchedule(&schedule)
+	if err != nil {
+		return models.FeeSchedule{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			stored, err := repo.GetFeeSchedule(schedule.AccountType, schedule.Currency)
+			if err == nil {
+				schedule.UUID = stored.UUID
+				return repo.UpdateFeeSchedule(schedule)
+			}
+			if !errors.Is(err, repository.ErrorUnknownFeeSchedule) {
+				return err
+			}
+			schedule.UUID, err = uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			return repo.CreateFeeSchedule(schedule)
+		})
+	if err != nil {
+		return models.FeeSchedule{}, err
+	}
+	stored, err := p.Repo.GetFeeSchedule(schedule.AccountType, schedule.Currency)
+	if err != nil {
+		return models.FeeSchedule{}, err
+	}
+	return *stored, nil
+}
+
+// validateFeeSchedule checks the schedule and rescales its amounts to the currency.
+func validateFeeSchedule(schedule *models.FeeSchedule) error {
+	exponent := models.CurrencyExponent(schedule.Currency)
+	amounts := []*models.Money{&schedule.Flat, &schedule.Min, &schedule.Max}
+	points := []uint{schedule.BasisPoints}
+	switch schedule.Kind {
+	case FLAT_FEE, PERCENT_FEE:
+		schedule.Tiers = nil
+	case TIERED_FEE:
+		if len(schedule.Tiers) == 0 {
+			return ErrFeeTiers
+		}
+		for i := range schedule.Tiers {
+			tier := &schedule.Tiers[i]
+			amounts = append(amounts, &tier.UpTo, &tier.Flat)
+			points = append(points, tier.BasisPoints)
+		}
+	default:
+		return ErrFeeKind
+	}
+	for _, amount := range amounts {
+		if amount.IsNegative() {
+			return ErrWrongFee
+		}
+		rescaled, err := amount.Rescale(exponent)
+		if err != nil {
+			return err
+		}
+		*amount = rescaled
+	}
+	for _, bp := range points {
+		if bp > MAX_BASIS_POINTS {
+			return ErrWrongFee
+		}
+	}
+	if !schedule.Max.IsZero() && schedule.Max.Cmp(schedule.Min) < 0 {
+		return ErrWrongFee
+	}
+	for i, tier := range schedule.Tiers {
+		last := i == len(schedule.Tiers)-1
+		if last != tier.UpTo.IsZero() {
+			return ErrFeeTiers
+		}
+		if i > 0 && !last && tier.UpTo.Cmp(schedule.Tiers[i-1].UpTo) <= 0 {
+			return ErrFeeTiers
+		}
+	}
+	return nil
+}
+
+func (p *PaymentSystem) GetFeeSchedules() ([]models.FeeSchedule, error) {
+	return p.Repo.GetFeeSchedules()
+}
+
+func (p *PaymentSystem) DeleteFeeSchedule(scheduleUUID uuid.UUID) error {
+	return p.Repo.DeleteFeeSchedule(scheduleUUID)
+}
+
+// fee returns the fee of the transaction by the schedule of the source account
+// type, or by the schedule for all types; without a schedule there is no fee.
+func fee(repo repository.Repository, transaction *models.Transaction) (models.Money, error) {
+	source, err := repo.GetAccountByUUID(transaction.SourceUUID)
+	if err != nil {
+		return models.Money{}, err
+	}
+	zero := models.NewMoney(0, models.CurrencyExponent(transaction.Currency))
+	for _, accountType := range []string{source.Type, ""} {
+		schedule, err := repo.GetFeeSchedule(accountType, transaction.Currency)
+		if errors.Is(err, repository.ErrorUnknownFeeSchedule) {
+			continue
+		}
+		if err != nil {
+			return models.Money{}, err
+		}
+		return calculateFee(schedule, transaction.Amount)
+	}
+	return zero, nil
+}
+
+func calculateFee(schedule *models.FeeSchedule, amount models.Money) (models.Money, error) {
+	exponent := models.CurrencyExponent(schedule.Currency)
+	flat, points := schedule.Flat, uint(0)
+	switch schedule.Kind {
+	case PERCENT_FEE:
+		flat, points = models.NewMoney(0, exponent), schedule.BasisPoints
+	case TIERED_FEE:
+		for _, tier := range schedule.Tiers {
+			if tier.UpTo.IsZero() || amount.Cmp(tier.UpTo) <= 0 {
+				flat, points = tier.Flat, tier.BasisPoints
+				break
+			}
+		}
+	}
+	percent, err := scale(amount, big.NewRat(int64(points), MAX_BASIS_POINTS), exponent)
+	if err != nil {
+		return models.Money{}, err
+	}
+	fee, err := flat.Add(percent)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if fee.Cmp(schedule.Min) < 0 {
+		fee = schedule.Min
+	}
+	if !schedule.Max.IsZero() && fee.Cmp(schedule.Max) > 0 {
+		fee = schedule.Max
+	}
+	return fee.Rescale(exponent)
+}
+
+// debited returns the amount with the fee which the source account pays.
+func debited(transaction *models.Transaction) (models.Money, error) {
+	return transaction.Amount.Add(transaction.Fee)
+}
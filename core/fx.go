@@ -0,0 +1,165 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"payment/models"
+	"payment/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const DEFAULT_CURRENCY = "UAH"
+
+const EXCHANGE = "exchange"
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// RateProvider returns how many units of the currency to are paid for one
+// unit of the currency from.
+type RateProvider interface {
+	Rate(from, to string) (float64, error)
+}
+
+// StaticRates keeps the rates of the currencies against the base currency.
+type StaticRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func NewStaticRates(base string, rates map[string]float64) StaticRates {
+	all := map[string]float64{base: 1}
+	for currency, rate := range rates {
+		all[strings.ToUpper(currency)] = rate
+	}
+	return StaticRates{Base: base, Rates: all}
+}
+
+// LoadRates reads the rates from a JSON file like
+// {"base": "UAH", "rates": {"USD": 0.027, "EUR": 0.025}}.
+func LoadRates(path string) (StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return StaticRates{}, err
+	}
+	var rates StaticRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return StaticRates{}, err
+	}
+	return NewStaticRates(strings.ToUpper(rates.Base), rates.Rates), nil
+}
+
+func (s StaticRates) Rate(from, to string) (float64, error) {
+	fromRate, ok := s.Rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, ErrUnknownCurrency
+	}
+	toRate, ok := s.Rates[to]
+	if !ok || toRate <= 0 {
+		return 0, ErrUnknownCurrency
+	}
+	return toRate / fromRate, nil
+}
+
+// validCurrency reports whether the rate provider can convert the currency.
+func (p *PaymentSystem) validCurrency(currency string) bool {
+	_, err := p.Rates.Rate(DEFAULT_CURRENCY, currency)
+	return err == nil
+}
+
+// quote sets IBANs, currencies, applied rate and the destination amount of the transaction.
+func (p *PaymentSystem) quote(transaction *models.Transaction) error {
+	source, err := p.Repo.GetAccountByUUID(transaction.SourceUUID)
+	if err != nil {
+		return err
+	}
+	destination, err := p.Repo.GetAccountByUUID(transaction.DestinationUUID)
+	if err != nil {
+		return err
+	}
+	transaction.SourceIBAN = source.IBAN
+	transaction.DestinationIBAN = destination.IBAN
+	transaction.Currency = source.Currency
+	transaction.DestinationCurrency = destination.Currency
+	transaction.Amount, err = transaction.Amount.Rescale(models.CurrencyExponent(source.Currency))
+	if err != nil {
+		return err
+	}
+	transaction.Rate = 1
+	transaction.DestinationAmount = transaction.Amount
+	if source.Currency == destination.Currency {
+		return nil
+	}
+	rate, err := p.Rates.Rate(source.Currency, destination.Currency)
+	if err != nil {
+		return err
+	}
+	transaction.Rate = rate
+	transaction.DestinationAmount, err = scale(transaction.Amount, new(big.Rat).SetFloat64(rate), models.CurrencyExponent(destination.Currency))
+	return err
+}
+
+// scale multiplies the amount by the factor and rounds the result to the
+// given number of decimal places, halves are rounded away from zero.
+func scale(amount models.Money, factor *big.Rat, exponent uint8) (models.Money, error) {
+	value := new(big.Rat).SetFrac(big.NewInt(amount.Minor), pow10(amount.Exponent))
+	value.Mul(value, factor)
+	value.Mul(value, new(big.Rat).SetInt(pow10(exponent)))
+	minor, ok := new(big.Int).SetString(value.FloatString(0), 10)
+	if !ok || !minor.IsInt64() {
+		return models.Money{}, models.ErrMoneyOverflow
+	}
+	return models.NewMoney(minor.Int64(), exponent), nil
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// received returns the amount credited to the destination of the transaction.
+func received(transaction *models.Transaction) models.Money {
+	if transaction.DestinationCurrency == "" {
+		return transaction.Amount
+	}
+	return transaction.DestinationAmount
+}
+
+func exchangeAccount(currency string) uuid.UUID {
+	return systemAccount("fx:" + currency)
+}
+
+// transfer moves the money of the transaction. Transfers between different
+// currencies go through the exchange accounts of both currencies, so the
+// ledger stays balanced in every currency. Closed accounts can't take part
+// in transfers.
+func transfer(repo repository.Repository, journalUUID uuid.UUID, transaction *models.Transaction, kind string) error {
+	if err := checkOpen(repo, transaction.SourceUUID, transaction.DestinationUUID); err != nil {
+		return err
+	}
+	if transaction.Currency == transaction.DestinationCurrency {
+		return move(repo, journalUUID, transaction.UUID, kind, transaction.SourceUUID, transaction.DestinationUUID, transaction.Amount)
+	}
+	err := move(repo, journalUUID, transaction.UUID, EXCHANGE, transaction.SourceUUID, exchangeAccount(transaction.Currency), transaction.Amount)
+	if err != nil {
+		return err
+	}
+	return move(repo, journalUUID, transaction.UUID, kind, exchangeAccount(transaction.DestinationCurrency), transaction.DestinationUUID, received(transaction))
+}
+
+// convert returns the amount in the currency to at the current rate.
+func (p *PaymentSystem) convert(amount models.Money, from, to string) (models.Money, error) {
+	if from == "" {
+		from = DEFAULT_CURRENCY
+	}
+	if from == to {
+		return amount, nil
+	}
+	rate, err := p.Rates.Rate(from, to)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return scale(amount, new(big.Rat).SetFloat64(rate), models.CurrencyExponent(to))
+}
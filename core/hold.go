@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AUTHORIZED = "authorized"
+	CAPTURED   = "captured"
+	VOIDED     = "voided"
+
+	CAPTURE = "capture"
+
+	DEFAULT_HOLD_TTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrHoldNotAuthorized = errors.New("hold is not authorized")
+	ErrCaptureAmount     = errors.New("capture amount exceeds the held amount")
+)
+
+type Hold struct {
+	SourceUUID      uuid.UUID
+	DestinationUUID uuid.UUID
+	// DestinationIBAN is resolved to DestinationUUID when it is set.
+	DestinationIBAN string
+	Amount          models.Money
+	Description     string
+	// TTL is DEFAULT_HOLD_TTL when it is zero.
+	TTL time.Duration
+}
+
+// AuthorizeHold reserves the amount of the available balance of the source
+// account for a later capture to the destination.
+func (p *PaymentSystem) AuthorizeHold(h Hold) (models.Hold, error) {
+	destinationUUID, err := p.resolveDestination(h.DestinationUUID, h.DestinationIBAN)
+	if err != nil {
+		return models.Hold{}, err
+	}
+	if h.SourceUUID == destinationUUID {
+		return models.Hold{}, ErrWrongDestination
+	}
+	if err := checkOpen(p.Repo, h.SourceUUID, destinationUUID); err != nil {
+		return models.Hold{}, err
+	}
+	source, err := p.Repo.GetAccountByUUID(h.SourceUUID)
+	if err != nil {
+		return models.Hold{}, err
+	}
+	amount, err := h.Amount.Rescale(models.CurrencyExponent(source.Currency))
+	if err != nil {
+		return models.Hold{}, err
+	}
+	if !amount.IsPositive() {
+		return models.Hold{}, ErrWrongAmount
+	}
+	if h.TTL == 0 {
+		h.TTL = DEFAULT_HOLD_TTL
+	}
+	hold := models.Hold{
+		AccountUUID:     source.UUID,
+		DestinationUUID: destinationUUID,
+		Amount:          amount,
+		Captured:        models.NewMoney(0, amount.Exponent),
+		Currency:        source.Currency,
+		Description:     h.Description,
+		Status:          AUTHORIZED,
+		ExpiresAt:       time.Now().Add(h.TTL),
+	}
+	hold.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.Hold{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, hold.AccountUUID)
+			if err != nil {
+				return err
+			}
+			err = checkAmount(repo, hold.AccountUUID, hold.Amount)
+			if err != nil {
+				return err
+			}
+			err = repo.IncHeld(hold.AccountUUID, hold.Amount)
+			if err != nil {
+				return err
+			}
+			return repo.CreateHold(hold)
+		})
+	if err != nil {
+		return models.Hold{}, err
+	}
+	return p.GetHold(hold.AccountUUID, hold.UUID)
+}
+
+func (p *PaymentSystem) GetHolds(accountUUID uuid.UUID, query models.QueryParams) ([]models.Hold, error) {
+	return p.Repo.GetHoldsForAccount(accountUUID, query)
+}
+
+func (p *PaymentSystem) GetHold(accountUUID, holdUUID uuid.UUID) (models.Hold, error) {
+	hold, err := p.Repo.GetHoldByUUID(holdUUID)
+	if err != nil {
+		return models.Hold{}, err
+	}
+	if hold.AccountUUID != accountUUID {
+		return models.Hold{}, ErrPermissionDenied
+	}
+	return *hold, nil
+}
+
+// authorizedHold locks the accounts of the hold and returns it when it can
+// still be captured or voided.
+func authorizedHold(repo repository.Repository, accountUUID, holdUUID uuid.UUID) (*models.Hold, error) {
+	hold, err := repo.GetHoldByUUID(holdUUID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.AccountUUID != accountUUID {
+		return nil, ErrPermissionDenied
+	}
+	err = lockAccounts(repo, hold.AccountUUID, hold.DestinationUUID)
+	if err != nil {
+		return nil, err
+	}
+	hold, err = repo.GetHoldByUUID(holdUUID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != AUTHORIZED || !hold.ExpiresAt.After(time.Now()) {
+		return nil, ErrHoldNotAuthorized
+	}
+	return hold, nil
+}
+
+// remaining returns the held amount which is not captured yet.
+func remaining(hold *models.Hold) (models.Money, error) {
+	return hold.Amount.Sub(hold.Captured)
+}
+
+// CaptureHold moves the amount of the hold to its destination by a transaction
+// which is sent like any other one, with its fee, limits and approval; zero
+// amount captures everything that is left. A partial capture keeps the rest of
+// the hold authorized until it is captured or voided. A capture waiting for
+// approval no longer holds its amount, a rejected capture leaves it available.
+func (p *PaymentSystem) CaptureHold(accountUUID, holdUUID uuid.UUID, amount models.Money) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			hold, err := authorizedHold(repo, accountUUID, holdUUID)
+			if err != nil {
+				return err
+			}
+			left, err := remaining(hold)
+			if err != nil {
+				return err
+			}
+			// the parameter stays unchanged for the retries of the repository transaction
+			captured := amount
+			if captured.IsZero() {
+				captured = left
+			}
+			captured, err = captured.Rescale(models.CurrencyExponent(hold.Currency))
+			if err != nil {
+				return err
+			}
+			if !captured.IsPositive() || captured.Cmp(left) > 0 {
+				return ErrCaptureAmount
+			}
+			err = repo.DecHeld(hold.AccountUUID, captured)
+			if err != nil {
+				return err
+			}
+			transaction = models.Transaction{
+				Status:          PREPARED,
+				SourceUUID:      hold.AccountUUID,
+				DestinationUUID: hold.DestinationUUID,
+				Amount:          captured,
+			}
+			err = p.quote(&transaction)
+			if err != nil {
+				return err
+			}
+			transaction.Fee, err = fee(repo, &transaction)
+			if err != nil {
+				return err
+			}
+			transaction.UUID, err = uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			err = createTransaction(repo, transaction)
+			if err != nil {
+				return err
+			}
+			// the released amount is available again, so the capture passes
+			// the checks of a send unless the fee or the limits don't allow it
+			approval, err := p.admit(repo, &transaction)
+			if err != nil {
+				return err
+			}
+			if approval {
+				err = updateStatus(repo, &transaction, PENDING_APPROVAL, "")
+			} else {
+				err = execute(repo, &transaction, CAPTURE, "")
+			}
+			if err != nil {
+				return err
+			}
+			hold.Captured, err = hold.Captured.Add(captured)
+			if err != nil {
+				return err
+			}
+			if hold.Captured.Cmp(hold.Amount) == 0 {
+				hold.Status = CAPTURED
+			}
+			return repo.UpdateHold(*hold)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tr, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *tr, nil
+}
+
+// VoidHold releases the amount of the hold which is not captured.
+func (p *PaymentSystem) VoidHold(accountUUID, holdUUID uuid.UUID) (models.Hold, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			hold, err := authorizedHold(repo, accountUUID, holdUUID)
+			if err != nil {
+				return err
+			}
+			return release(repo, hold, VOIDED)
+		})
+	if err != nil {
+		return models.Hold{}, err
+	}
+	return p.GetHold(accountUUID, holdUUID)
+}
+
+// release returns the remaining amount of the hold to the available balance
+// and closes the hold with the status.
+func release(repo repository.Repository, hold *models.Hold, status string) error {
+	left, err := remaining(hold)
+	if err != nil {
+		return err
+	}
+	if left.IsPositive() {
+		err = repo.DecHeld(hold.AccountUUID, left)
+		if err != nil {
+			return err
+		}
+	}
+	hold.Status = status
+	return repo.UpdateHold(*hold)
+}
+
+// ExpireHolds releases the authorized holds which expired by now and returns
+// how many of them were expired.
+func (p *PaymentSystem) ExpireHolds(now time.Time) (int, error) {
+	holds, err := p.Repo.GetExpiredHolds(now)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, h := range holds {
+		err := p.Repo.Transaction(
+			func(repo repository.Repository) error {
+				err := lockAccounts(repo, h.AccountUUID)
+				if err != nil {
+					return err
+				}
+				hold, err := repo.GetHoldByUUID(h.UUID)
+				if err != nil {
+					return err
+				}
+				if hold.Status != AUTHORIZED {
+					return ErrHoldNotAuthorized
+				}
+				return release(repo, hold, EXPIRED)
+			})
+		if err != nil {
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	DEFAULT_IBAN_COUNTRY = "UA"
+	DEFAULT_BANK_CODE    = "300001"
+
+	MIN_IBAN_LENGTH   = 15
+	MAX_IBAN_LENGTH   = 34
+	MAX_IBAN_ATTEMPTS = 5
+)
+
+var (
+	ErrInvalidIBAN  = errors.New("invalid iban")
+	ErrIBANCountry  = errors.New("unsupported iban country")
+	ErrIBANBankCode = errors.New("wrong bank code for the iban country")
+)
+
+// ibanLengths keeps the IBAN length of the countries accounts can be opened in,
+// IBANs of other countries are only checked for the general format.
+var ibanLengths = map[string]int{
+	"UA": 29,
+	"PL": 28,
+	"DE": 22,
+	"GB": 22,
+	"FR": 27,
+	"LT": 20,
+	"CZ": 24,
+}
+
+// IBANGenerator makes ISO 13616 IBANs of the country, the BBAN is the bank
+// code followed by a random account number.
+type IBANGenerator struct {
+	Country  string
+	BankCode string
+}
+
+// NewIBANGenerator checks the country and the bank code, which is required.
+func NewIBANGenerator(country, bankCode string) (IBANGenerator, error) {
+	country = strings.ToUpper(country)
+	length, ok := ibanLengths[country]
+	if !ok {
+		return IBANGenerator{}, ErrIBANCountry
+	}
+	bankCode = strings.ToUpper(bankCode)
+	if bankCode == "" || !isAlphanumeric(bankCode) || len(bankCode) >= length-4 {
+		return IBANGenerator{}, ErrIBANBankCode
+	}
+	return IBANGenerator{Country: country, BankCode: bankCode}, nil
+}
+
+// Generate returns a new IBAN with a random account number.
+func (g IBANGenerator) Generate() (string, error) {
+	n := ibanLengths[g.Country] - 4 - len(g.BankCode)
+	account := make([]byte, n)
+	for i := range account {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		account[i] = byte('0' + digit.Int64())
+	}
+	bban := g.BankCode + string(account)
+	return g.Country + checkDigits(g.Country, bban) + bban, nil
+}
+
+// checkDigits computes the two check digits of the IBAN with the country and BBAN.
+func checkDigits(country, bban string) string {
+	check := 98 - mod97(bban+country+"00")
+	return string([]byte{byte('0' + check/10), byte('0' + check%10)})
+}
+
+// mod97 returns the remainder of the number made of the characters, where
+// letters are replaced by 10 to 35, divided by 97.
+func mod97(s string) int {
+	remainder := 0
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+	return remainder
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateIBAN normalizes the IBAN and checks its format, length for the known
+// countries and check digits.
+func ValidateIBAN(iban string) (string, error) {
+	iban = normalizeIBAN(iban)
+	if len(iban) < MIN_IBAN_LENGTH || len(iban) > MAX_IBAN_LENGTH || !isAlphanumeric(iban) {
+		return "", ErrInvalidIBAN
+	}
+	country, check := iban[:2], iban[2:4]
+	if !isLetters(country) || !isDigits(check) {
+		return "", ErrInvalidIBAN
+	}
+	if length, ok := ibanLengths[country]; ok && len(iban) != length {
+		return "", ErrInvalidIBAN
+	}
+	if mod97(iban[4:]+iban[:4]) != 1 {
+		return "", ErrInvalidIBAN
+	}
+	return iban, nil
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MAX_IDEMPOTENCY_KEY = 255
+	// IDEMPOTENCY_TIMEOUT is how long a key stays reserved by a request which
+	// hasn't finished, e.g. because the process crashed, before a retry takes it over.
+	IDEMPOTENCY_TIMEOUT = time.Minute
+)
+
+var (
+	ErrIdempotencyConflict   = errors.New("idempotency key is already used with another request")
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+	ErrIdempotencyKey        = errors.New("invalid idempotency key")
+)
+
+// BeginIdempotentRequest reserves the key for the user. It returns the stored
+// response when the same request has already been completed and nil when the
+// caller has to process the request and then call FinishIdempotentRequest.
+func (p *PaymentSystem) BeginIdempotentRequest(userUUID uuid.UUID, key, requestHash string) (*models.IdempotencyKey, error) {
+	if key == "" || len(key) > MAX_IDEMPOTENCY_KEY {
+		return nil, ErrIdempotencyKey
+	}
+	idempotencyKey := models.IdempotencyKey{
+		Key:         key,
+		UserUUID:    userUUID,
+		RequestHash: requestHash,
+	}
+	err := p.Repo.CreateIdempotencyKey(&idempotencyKey)
+	if err == nil {
+		return nil, nil
+	}
+	stored, getErr := p.Repo.GetIdempotencyKey(userUUID, key)
+	if getErr != nil {
+		return nil, err
+	}
+	if stored.RequestHash != requestHash {
+		return nil, ErrIdempotencyConflict
+	}
+	if stored.ResponseCode != 0 {
+		return stored, nil
+	}
+	expired := time.Now().Add(-IDEMPOTENCY_TIMEOUT)
+	if stored.CreatedAt.After(expired) {
+		return nil, ErrIdempotencyInProgress
+	}
+	// the abandoned reservation is deleted only while it is still expired, so
+	// of the concurrent retries only one creates the key again
+	err = p.Repo.DeleteIdempotencyKey(userUUID, key, expired)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Repo.CreateIdempotencyKey(&idempotencyKey); err != nil {
+		return nil, ErrIdempotencyInProgress
+	}
+	return nil, nil
+}
+
+func (p *PaymentSystem) FinishIdempotentRequest(userUUID uuid.UUID, key string, code int, body []byte) error {
+	return p.Repo.UpdateIdempotencyKey(userUUID, key, code, body)
+}
+
+// ReleaseIdempotentRequest deletes the reservation of the key by the request
+// which didn't finish, so the request can be retried with the same key.
+func (p *PaymentSystem) ReleaseIdempotentRequest(userUUID uuid.UUID, key string) error {
+	return p.Repo.DeleteIdempotencyKey(userUUID, key, time.Now())
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"payment/models"
+	"payment/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	INTEREST = "interest"
+
+	// INTEREST_EXPONENT is the precision of the accrued interest, so the
+	// daily fractions of the minor unit aren't lost.
+	INTEREST_EXPONENT = 8
+	DAYS_IN_YEAR      = 365
+)
+
+var ErrInterestRates = errors.New("interest rates have to be account_type=basis_points pairs separated by commas")
+
+// interestAccount is the system account which pays the interest in the currency.
+func interestAccount(currency string) uuid.UUID {
+	return systemAccount("interest:" + currency)
+}
+
+// ParseInterestRates parses annual rates in basis points per account type
+// written as "savings=350,personal=0".
+func ParseInterestRates(s string) (map[string]uint, error) {
+	rates := make(map[string]uint)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		accountType, value, ok := strings.Cut(pair, "=")
+		accountType = strings.ToLower(strings.TrimSpace(accountType))
+		if !ok || !validAccountType(accountType) {
+			return nil, ErrInterestRates
+		}
+		bp, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil || bp > MAX_BASIS_POINTS {
+			return nil, ErrInterestRates
+		}
+		rates[accountType] = uint(bp)
+	}
+	return rates, nil
+}
+
+// GetInterest returns the annual rate of the account in basis points and the
+// interest accrued since the last payout.
+func (p *PaymentSystem) GetInterest(accountUUID uuid.UUID) (uint, models.InterestAccrual, error) {
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return 0, models.InterestAccrual{}, err
+	}
+	rate := p.InterestRates[account.Type]
+	accrual, err := p.Repo.GetInterestAccrual(accountUUID)
+	if errors.Is(err, repository.ErrorUnknownInterestAccrual) {
+		return rate, models.InterestAccrual{AccountUUID: accountUUID, Accrued: models.NewMoney(0, INTEREST_EXPONENT)}, nil
+	}
+	if err != nil {
+		return 0, models.InterestAccrual{}, err
+	}
+	return rate, *accrual, nil
+}
+
+// AccrueInterest adds the daily interest for the days passed since the last
+// accrual to the accounts of the types with an interest rate and the overdraft
+// interest to the accounts below zero. Once a month the whole minor units
+// accrued are paid out and the overdraft interest and fee are charged; the
+// fractions are kept for the next month. It returns the number of accounts
+// paid or charged.
+func (p *PaymentSystem) AccrueInterest(now time.Time) (int, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	settled := 0
+	for _, accountType := range p.accrualTypes() {
+		accounts, err := p.Repo.GetAccountsByType(accountType)
+		if err != nil {
+			return settled, err
+		}
+		for _, account := range accounts {
+			var ok bool
+			err := p.Repo.Transaction(
+				func(repo repository.Repository) error {
+					var err error
+					ok, err = p.accrueInterest(repo, account.UUID, today)
+					return err
+				})
+			if err != nil {
+				continue
+			}
+			if ok {
+				settled++
+			}
+		}
+	}
+	return settled, nil
+}
+
+// accrualTypes returns the account types which accrue interest, all types do
+// when the overdraft is charged.
+func (p *PaymentSystem) accrualTypes() []string {
+	all := []string{PERSONAL, BUSINESS, SAVINGS}
+	if p.OverdraftRate > 0 || p.OverdraftFee.IsPositive() {
+		return all
+	}
+	types := make([]string, 0)
+	for _, accountType := range all {
+		if p.InterestRates[accountType] > 0 {
+			types = append(types, accountType)
+		}
+	}
+	return types
+}
+
+// accrueInterest accrues the interest of the account up to the day and
+// settles it when the month has changed since the last payout.
+func (p *PaymentSystem) accrueInterest(repo repository.Repository, accountUUID uuid.UUID, today time.Time) (bool, error) {
+	account, err := repo.GetAccountForUpdate(accountUUID)
+	if err != nil {
+		return false, err
+	}
+	if account.Status == CLOSED {
+		return false, nil
+	}
+	accrual, err := repo.GetInterestAccrual(accountUUID)
+	if errors.Is(err, repository.ErrorUnknownInterestAccrual) {
+		// the interest starts to accrue from the first day the job sees the account
+		return false, repo.SaveInterestAccrual(models.InterestAccrual{
+			AccountUUID: accountUUID,
+			Accrued:     models.NewMoney(0, INTEREST_EXPONENT),
+			Overdraft:   models.NewMoney(0, INTEREST_EXPONENT),
+			AccruedAt:   today,
+			PaidAt:      today,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	days := int64(today.Sub(accrual.AccruedAt) / (24 * time.Hour))
+	if days <= 0 {
+		return false, nil
+	}
+	rate := p.InterestRates[account.Type]
+	switch {
+	case account.Balance.IsPositive() && rate > 0:
+		accrual.Accrued, err = dailyInterest(accrual.Accrued, account.Balance, rate, days)
+	case account.Balance.IsNegative():
+		accrual.OverdrawnDays += uint(days)
+		accrual.Overdraft, err = dailyInterest(accrual.Overdraft, account.Balance.Neg(), p.OverdraftRate, days)
+	}
+	if err != nil {
+		return false, err
+	}
+	accrual.AccruedAt = today
+	settled := false
+	paidAt := accrual.PaidAt.UTC()
+	if today.Year() != paidAt.Year() || today.Month() != paidAt.Month() {
+		paid, err := payInterest(repo, account, accrual)
+		if err != nil {
+			return false, err
+		}
+		charged, err := p.chargeOverdraft(repo, account, accrual)
+		if err != nil {
+			return false, err
+		}
+		settled = paid || charged
+		accrual.PaidAt = today
+		accrual.OverdrawnDays = 0
+	}
+	return settled, repo.SaveInterestAccrual(*accrual)
+}
+
+// settleInterest accrues the interest of the account up to now and settles
+// it without waiting for the end of the month.
+func (p *PaymentSystem) settleInterest(repo repository.Repository, accountUUID uuid.UUID, now time.Time) error {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if _, err := p.accrueInterest(repo, accountUUID, today); err != nil {
+		return err
+	}
+	account, err := repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return err
+	}
+	accrual, err := repo.GetInterestAccrual(accountUUID)
+	if err != nil {
+		return err
+	}
+	if _, err := payInterest(repo, account, accrual); err != nil {
+		return err
+	}
+	if _, err := p.chargeOverdraft(repo, account, accrual); err != nil {
+		return err
+	}
+	accrual.PaidAt = today
+	accrual.OverdrawnDays = 0
+	return repo.SaveInterestAccrual(*accrual)
+}
+
+// dailyInterest adds the interest of the balance at the annual rate for the
+// days to the accrued amount.
+func dailyInterest(accrued, balance models.Money, rate uint, days int64) (models.Money, error) {
+	if rate == 0 {
+		return accrued, nil
+	}
+	interest, err := scale(balance, big.NewRat(int64(rate)*days, MAX_BASIS_POINTS*DAYS_IN_YEAR), INTEREST_EXPONENT)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return accrued.Add(interest)
+}
+
+// wholeUnits splits the accrued amount into the whole minor units of the
+// currency and the fraction left.
+func wholeUnits(accrued models.Money, currency string) (models.Money, models.Money, error) {
+	accrued, err := accrued.Rescale(INTEREST_EXPONENT)
+	if err != nil {
+		return models.Money{}, models.Money{}, err
+	}
+	exponent := models.CurrencyExponent(currency)
+	minor := new(big.Int).Quo(big.NewInt(accrued.Minor), pow10(INTEREST_EXPONENT-exponent))
+	whole := models.NewMoney(minor.Int64(), exponent)
+	rest, err := accrued.Sub(whole)
+	if err != nil {
+		return models.Money{}, models.Money{}, err
+	}
+	return whole, rest, nil
+}
+
+// payInterest credits the account with the whole minor units of the accrued
+// interest from the interest account.
+func payInterest(repo repository.Repository, account *models.Account, accrual *models.InterestAccrual) (bool, error) {
+	amount, rest, err := wholeUnits(accrual.Accrued, account.Currency)
+	if err != nil {
+		return false, err
+	}
+	if !amount.IsPositive() {
+		return false, nil
+	}
+	accrual.Accrued = rest
+	return true, systemTransaction(repo, interestAccount(account.Currency), account, amount, INTEREST)
+}
+
+// systemTransaction books a sent transaction between the account and the
+// system account, a positive amount credits the account and a negative one
+// debits it. The balance isn't checked, so a charge may take the account
+// below zero.
+func systemTransaction(repo repository.Repository, systemUUID uuid.UUID, account *models.Account, amount models.Money, kind string) error {
+	transaction := models.Transaction{
+		Status:              SENT,
+		SourceUUID:          systemUUID,
+		DestinationUUID:     account.UUID,
+		DestinationIBAN:     account.IBAN,
+		Currency:            account.Currency,
+		DestinationCurrency: account.Currency,
+		Rate:                1,
+	}
+	if amount.IsNegative() {
+		amount = amount.Neg()
+		transaction.SourceUUID, transaction.DestinationUUID = account.UUID, systemUUID
+		transaction.SourceIBAN, transaction.DestinationIBAN = account.IBAN, ""
+	}
+	transaction.Amount = amount
+	transaction.DestinationAmount = amount
+	var err error
+	transaction.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	err = createTransaction(repo, transaction)
+	if err != nil {
+		return err
+	}
+	journalUUID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	return move(repo, journalUUID, transaction.UUID, kind, transaction.SourceUUID, transaction.DestinationUUID, amount)
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DEBIT  = "debit"
+	CREDIT = "credit"
+
+	TRANSFER   = "transfer"
+	DEPOSIT    = "deposit"
+	ADJUSTMENT = "adjustment"
+	OPENING    = "opening"
+)
+
+var ErrLedgerMismatch = errors.New("account balance does not match ledger")
+
+var (
+	systemAccountsMu sync.RWMutex
+	systemAccounts   = make(map[uuid.UUID]string)
+
+	// EXTERNAL_ACCOUNT is the counterpart of the money which comes into the system.
+	EXTERNAL_ACCOUNT = systemAccount("external")
+)
+
+// systemAccount returns the ledger-only account with the given name. System
+// accounts have entries in the ledger but no row with a balance.
+func systemAccount(name string) uuid.UUID {
+	accountUUID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("payment:"+name))
+	systemAccountsMu.Lock()
+	systemAccounts[accountUUID] = name
+	systemAccountsMu.Unlock()
+	return accountUUID
+}
+
+func isSystemAccount(accountUUID uuid.UUID) bool {
+	systemAccountsMu.RLock()
+	defer systemAccountsMu.RUnlock()
+	_, ok := systemAccounts[accountUUID]
+	return ok
+}
+
+// move debits the source and credits the destination with the amount and
+// writes a balanced pair of ledger entries. It must be called inside
+// Repo.Transaction.
+func move(repo repository.Repository, journalUUID, transactionUUID uuid.UUID, kind string, sourceUUID, destinationUUID uuid.UUID, amount models.Money) error {
+	if !isSystemAccount(sourceUUID) {
+		if err := repo.DecBalance(sourceUUID, amount); err != nil {
+			return err
+		}
+	}
+	if !isSystemAccount(destinationUUID) {
+		if err := repo.IncBalance(destinationUUID, amount); err != nil {
+			return err
+		}
+	}
+	entries, err := ledgerPair(journalUUID, transactionUUID, kind, sourceUUID, destinationUUID, amount)
+	if err != nil {
+		return err
+	}
+	return repo.CreateLedgerEntries(entries)
+}
+
+// ledgerPair returns the debit and credit entries of the amount.
+func ledgerPair(journalUUID, transactionUUID uuid.UUID, kind string, sourceUUID, destinationUUID uuid.UUID, amount models.Money) ([]models.LedgerEntry, error) {
+	debitUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	creditUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return []models.LedgerEntry{
+		{
+			UUID:            debitUUID,
+			JournalUUID:     journalUUID,
+			TransactionUUID: transactionUUID,
+			AccountUUID:     sourceUUID,
+			Kind:            kind,
+			Direction:       DEBIT,
+			Amount:          amount,
+		},
+		{
+			UUID:            creditUUID,
+			JournalUUID:     journalUUID,
+			TransactionUUID: transactionUUID,
+			AccountUUID:     destinationUUID,
+			Kind:            kind,
+			Direction:       CREDIT,
+			Amount:          amount,
+		},
+	}, nil
+}
+
+// OpenLedger writes an opening entry against the external account for every
+// account whose balance doesn't match its ledger, like the accounts funded
+// before the ledger, so CheckLedger reports only real mismatches. It is a
+// migration: once the ledger has opening entries nothing is written.
+func (p *PaymentSystem) OpenLedger() (int, error) {
+	opened := 0
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			opened = 0
+			count, err := repo.CountLedgerEntries(OPENING)
+			if err != nil || count > 0 {
+				return err
+			}
+			accounts, err := repo.GetAccounts()
+			if err != nil {
+				return err
+			}
+			accountUUIDs := make([]uuid.UUID, len(accounts))
+			for i, account := range accounts {
+				accountUUIDs[i] = account.UUID
+			}
+			err = lockAccounts(repo, accountUUIDs...)
+			if err != nil {
+				return err
+			}
+			for _, accountUUID := range accountUUIDs {
+				account, err := repo.GetAccountByUUID(accountUUID)
+				if err != nil {
+					return err
+				}
+				credit, debit, err := repo.GetLedgerTotals(accountUUID)
+				if err != nil {
+					return err
+				}
+				ledger, err := credit.Sub(debit)
+				if err != nil {
+					return err
+				}
+				diff, err := account.Balance.Sub(ledger)
+				if err != nil {
+					return err
+				}
+				if diff.IsZero() {
+					continue
+				}
+				sourceUUID, destinationUUID := EXTERNAL_ACCOUNT, accountUUID
+				if diff.IsNegative() {
+					sourceUUID, destinationUUID, diff = accountUUID, EXTERNAL_ACCOUNT, diff.Neg()
+				}
+				journalUUID, err := uuid.NewRandom()
+				if err != nil {
+					return err
+				}
+				entries, err := ledgerPair(journalUUID, uuid.Nil, OPENING, sourceUUID, destinationUUID, diff)
+				if err != nil {
+					return err
+				}
+				err = repo.CreateLedgerEntries(entries)
+				if err != nil {
+					return err
+				}
+				opened++
+			}
+			return nil
+		})
+	return opened, err
+}
+
+func (p *PaymentSystem) GetLedgerEntries(accountUUID uuid.UUID, query models.QueryParams) ([]models.LedgerEntry, error) {
+	return p.Repo.GetLedgerEntries(accountUUID, query)
+}
+
+// LedgerBalance returns the balance of the account derived from its ledger entries.
+func (p *PaymentSystem) LedgerBalance(accountUUID uuid.UUID) (models.Money, error) {
+	credit, debit, err := p.Repo.GetLedgerTotals(accountUUID)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return credit.Sub(debit)
+}
+
+// CheckLedger compares the stored balance of the account with its ledger.
+func (p *PaymentSystem) CheckLedger(accountUUID uuid.UUID) error {
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return err
+	}
+	balance, err := p.LedgerBalance(accountUUID)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(account.Balance) != 0 {
+		return ErrLedgerMismatch
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"payment/models"
+	"payment/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MAX_TRANSFER_LIMIT = "max_transfer"
+	DAILY_LIMIT        = "daily"
+	MONTHLY_LIMIT      = "monthly"
+
+	ACCOUNT_LIMIT = "account"
+	USER_LIMIT    = "user"
+)
+
+var (
+	ErrLimitExceeded = errors.New("transfer limit exceeded")
+	ErrWrongLimit    = errors.New("limit can't be negative")
+)
+
+// LimitError is returned when the transfer exceeds a limit, Remaining is the
+// amount which still can be sent within the limit.
+type LimitError struct {
+	Limit     string       `json:"limit"`
+	Scope     string       `json:"scope"`
+	Remaining models.Money `json:"remaining"`
+	Currency  string       `json:"currency"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %s limit, remaining %s %s", ErrLimitExceeded, e.Scope, e.Limit, e.Remaining, e.Currency)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// SetLimit creates or replaces the limit of the user, or of the user's account
+// when AccountUUID is set. Account limits are kept in the account currency.
+func (p *PaymentSystem) SetLimit(limit models.Limit) (models.Limit, error) {
+	user, err := p.Repo.GetUserByUUID(limit.UserUUID)
+	if err != nil {
+		return models.Limit{}, err
+	}
+	if limit.AccountUUID != uuid.Nil {
+		// the limits of joint accounts apply to the holder
+		err = p.CheckAccountPermission(user.UUID, limit.AccountUUID, OWNER)
+		if err != nil {
+			return models.Limit{}, err
+		}
+		account, err := p.Repo.GetAccountByUUID(limit.AccountUUID)
+		if err != nil {
+			return models.Limit{}, err
+		}
+		limit.Currency = account.Currency
+	}
+	if limit.Currency == "" {
+		limit.Currency = DEFAULT_CURRENCY
+	}
+	limit.Currency = strings.ToUpper(limit.Currency)
+	if !p.validCurrency(limit.Currency) {
+		return models.Limit{}, ErrUnknownCurrency
+	}
+	for _, amount := range []*models.Money{&limit.MaxTransfer, &limit.Daily, &limit.Monthly} {
+		if amount.IsNegative() {
+			return models.Limit{}, ErrWrongLimit
+		}
+		*amount, err = amount.Rescale(models.CurrencyExponent(limit.Currency))
+		if err != nil {
+			return models.Limit{}, err
+		}
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			stored, err := repo.GetLimit(limit.UserUUID, limit.AccountUUID)
+			if err == nil {
+				limit.UUID = stored.UUID
+				return repo.UpdateLimit(limit)
+			}
+			if !errors.Is(err, repository.ErrorUnknownLimit) {
+				return err
+			}
+			limit.UUID, err = uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			return repo.CreateLimit(limit)
+		})
+	if err != nil {
+		return models.Limit{}, err
+	}
+	stored, err := p.Repo.GetLimit(limit.UserUUID, limit.AccountUUID)
+	if err != nil {
+		return models.Limit{}, err
+	}
+	return *stored, nil
+}
+
+func (p *PaymentSystem) GetLimits(userUUID uuid.UUID) ([]models.Limit, error) {
+	return p.Repo.GetLimits(userUUID)
+}
+
+func (p *PaymentSystem) DeleteLimit(userUUID, limitUUID uuid.UUID) error {
+	limits, err := p.Repo.GetLimits(userUUID)
+	if err != nil {
+		return err
+	}
+	for _, limit := range limits {
+		if limit.UUID == limitUUID {
+			return p.Repo.DeleteLimit(limitUUID)
+		}
+	}
+	return repository.ErrorUnknownLimit
+}
+
+// checkLimits returns a *LimitError when sending the transaction at the given
+// time exceeds a limit of the source account or of its owner.
+func (p *PaymentSystem) checkLimits(repo repository.Repository, transaction *models.Transaction, now time.Time) error {
+	source, err := repo.GetAccountByUUID(transaction.SourceUUID)
+	if err != nil {
+		return err
+	}
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, scope := range []string{ACCOUNT_LIMIT, USER_LIMIT} {
+		accountUUID := uuid.Nil
+		if scope == ACCOUNT_LIMIT {
+			accountUUID = source.UUID
+		}
+		limit, err := repo.GetLimit(source.UserUUID, accountUUID)
+		if errors.Is(err, repository.ErrorUnknownLimit) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		amount, err := p.convert(transaction.Amount, transaction.Currency, limit.Currency)
+		if err != nil {
+			return err
+		}
+		if !limit.MaxTransfer.IsZero() && amount.Cmp(limit.MaxTransfer) > 0 {
+			return &LimitError{Limit: MAX_TRANSFER_LIMIT, Scope: scope, Remaining: limit.MaxTransfer, Currency: limit.Currency}
+		}
+		for _, period := range []struct {
+			name  string
+			max   models.Money
+			since time.Time
+		}{{DAILY_LIMIT, limit.Daily, day}, {MONTHLY_LIMIT, limit.Monthly, month}} {
+			if period.max.IsZero() {
+				continue
+			}
+			sent, err := p.sentSince(repo, source.UserUUID, accountUUID, limit.Currency, period.since, transaction.UUID)
+			if err != nil {
+				return err
+			}
+			remaining, err := period.max.Sub(sent)
+			if err != nil {
+				return err
+			}
+			if amount.Cmp(remaining) > 0 {
+				if remaining.IsNegative() {
+					remaining = models.NewMoney(0, remaining.Exponent)
+				}
+				return &LimitError{Limit: period.name, Scope: scope, Remaining: remaining, Currency: limit.Currency}
+			}
+		}
+	}
+	return nil
+}
+
+// sentSince sums in the currency the transfers sent since the given time from
+// the account, or from all accounts of the user when accountUUID is uuid.Nil,
+// the ones waiting for approval, which may be sent any time, and the payouts
+// waiting for the gateway. Refunds and the checked transaction don't count.
+func (p *PaymentSystem) sentSince(repo repository.Repository, userUUID, accountUUID uuid.UUID, currency string, since time.Time, checkedUUID uuid.UUID) (models.Money, error) {
+	transactions, err := repo.GetSentTransactionsForUser(userUUID, since)
+	if err != nil {
+		return models.Money{}, err
+	}
+	for _, status := range []string{PENDING_APPROVAL, PROCESSING} {
+		pending, err := repo.GetTransactionsForUserInStatus(userUUID, status)
+		if err != nil {
+			return models.Money{}, err
+		}
+		transactions = append(transactions, pending...)
+	}
+	total := models.NewMoney(0, models.CurrencyExponent(currency))
+	for _, tr := range transactions {
+		if tr.UUID == checkedUUID || tr.OriginalUUID != uuid.Nil || (accountUUID != uuid.Nil && tr.SourceUUID != accountUUID) {
+			continue
+		}
+		amount, err := p.convert(tr.Amount, tr.Currency, currency)
+		if err != nil {
+			return models.Money{}, err
+		}
+		total, err = total.Add(amount)
+		if err != nil {
+			return models.Money{}, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	VIEW     = "view"
+	INITIATE = "initiate"
+	SEND     = "send"
+	// OWNER is the permission of the account holder, only the holder
+	// manages the members and blocks the account.
+	OWNER = "owner"
+
+	INVITED = "invited"
+)
+
+var (
+	ErrWrongPermission = errors.New("permission has to be view, initiate or send")
+	ErrAlreadyMember   = errors.New("user is already a member of the account")
+	ErrNotInvited      = errors.New("user isn't invited to the account")
+)
+
+// permissionRanks orders the permissions, every permission includes the
+// lower ones.
+var permissionRanks = map[string]int{
+	VIEW:     1,
+	INITIATE: 2,
+	SEND:     3,
+	OWNER:    4,
+}
+
+func validPermission(permission string) bool {
+	return permission == VIEW || permission == INITIATE || permission == SEND
+}
+
+// accountPermission returns the permission the user has on the account, the
+// holder is the owner and the members have theirs once they accepted the
+// invite.
+func accountPermission(repo repository.Repository, userUUID, accountUUID uuid.UUID) (string, error) {
+	account, err := repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return "", err
+	}
+	if account.UserUUID == userUUID {
+		return OWNER, nil
+	}
+	member, err := repo.GetMember(accountUUID, userUUID)
+	if errors.Is(err, repository.ErrorUnknownMember) {
+		return "", ErrUnknownAccount
+	}
+	if err != nil {
+		return "", err
+	}
+	if member.Status != ACTIVE {
+		return "", ErrUnknownAccount
+	}
+	return member.Permission, nil
+}
+
+// CheckAccountPermission returns ErrUnknownAccount when the user has no access
+// to the account and ErrPermissionDenied when the access is lower than the
+// permission.
+func (p *PaymentSystem) CheckAccountPermission(userUUID, accountUUID uuid.UUID, permission string) error {
+	return checkPermission(p.Repo, userUUID, accountUUID, permission)
+}
+
+// checkPermission is CheckAccountPermission within the repository transaction.
+func checkPermission(repo repository.Repository, userUUID, accountUUID uuid.UUID, permission string) error {
+	granted, err := accountPermission(repo, userUUID, accountUUID)
+	if err != nil {
+		return err
+	}
+	if permissionRanks[granted] < permissionRanks[permission] {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// InviteMember invites the user with the email to the account, the user
+// gets the permission after accepting the invite.
+func (p *PaymentSystem) InviteMember(ownerUUID, accountUUID uuid.UUID, email, permission string) (models.AccountMember, error) {
+	if !validPermission(permission) {
+		return models.AccountMember{}, ErrWrongPermission
+	}
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	if account.Status == CLOSED {
+		return models.AccountMember{}, ErrAccountClosed
+	}
+	user, err := p.Repo.GetUserByEmail(email)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	if user.UUID == account.UserUUID {
+		return models.AccountMember{}, ErrAlreadyMember
+	}
+	member := models.AccountMember{
+		AccountUUID: accountUUID,
+		UserUUID:    user.UUID,
+		Permission:  permission,
+		Status:      INVITED,
+		InvitedBy:   ownerUUID,
+	}
+	err = p.Repo.CreateMember(member)
+	if errors.Is(err, repository.ErrorCreated) {
+		return models.AccountMember{}, ErrAlreadyMember
+	}
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	stored, err := p.Repo.GetMember(accountUUID, user.UUID)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	return *stored, nil
+}
+
+func (p *PaymentSystem) GetMembers(accountUUID uuid.UUID) ([]models.AccountMember, error) {
+	return p.Repo.GetMembers(accountUUID)
+}
+
+// SetMemberPermission changes the permission of the member or of the invite.
+func (p *PaymentSystem) SetMemberPermission(accountUUID, userUUID uuid.UUID, permission string) (models.AccountMember, error) {
+	if !validPermission(permission) {
+		return models.AccountMember{}, ErrWrongPermission
+	}
+	member, err := p.Repo.GetMember(accountUUID, userUUID)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	updated := *member
+	updated.Permission = permission
+	err = p.Repo.UpdateMember(updated)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	member, err = p.Repo.GetMember(accountUUID, userUUID)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	return *member, nil
+}
+
+// RemoveMember removes the member or withdraws the invite, it is used both by
+// the holder and by the member who leaves the account or declines the invite.
+func (p *PaymentSystem) RemoveMember(accountUUID, userUUID uuid.UUID) error {
+	return p.Repo.DeleteMember(accountUUID, userUUID)
+}
+
+// GetMemberships returns the invites and the memberships of the user.
+func (p *PaymentSystem) GetMemberships(userUUID uuid.UUID) ([]models.AccountMember, error) {
+	return p.Repo.GetMembershipsForUser(userUUID)
+}
+
+// AcceptInvite gives the invited user access to the account.
+func (p *PaymentSystem) AcceptInvite(userUUID, accountUUID uuid.UUID) (models.AccountMember, error) {
+	member, err := p.Repo.GetMember(accountUUID, userUUID)
+	if errors.Is(err, repository.ErrorUnknownMember) {
+		return models.AccountMember{}, ErrNotInvited
+	}
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	if member.Status != INVITED {
+		return models.AccountMember{}, ErrNotInvited
+	}
+	accepted := *member
+	accepted.Status = ACTIVE
+	err = p.Repo.UpdateMember(accepted)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	member, err = p.Repo.GetMember(accountUUID, userUUID)
+	if err != nil {
+		return models.AccountMember{}, err
+	}
+	return *member, nil
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OVERDRAFT_INTEREST = "overdraft-interest"
+	OVERDRAFT_FEE      = "overdraft-fee"
+)
+
+var ErrWrongOverdraft = errors.New("overdraft limit can't be negative")
+
+// SetOverdraftLimit sets how far below zero the balance of the account may go;
+// lowering the limit doesn't affect the current balance.
+func (p *PaymentSystem) SetOverdraftLimit(accountUUID uuid.UUID, limit models.Money) (models.Account, error) {
+	if limit.IsNegative() {
+		return models.Account{}, ErrWrongOverdraft
+	}
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return models.Account{}, err
+	}
+	limit, err = limit.Rescale(models.CurrencyExponent(account.Currency))
+	if err != nil {
+		return models.Account{}, err
+	}
+	err = p.Repo.UpdateOverdraftLimit(accountUUID, limit)
+	if err != nil {
+		return models.Account{}, err
+	}
+	return p.GetAccount(accountUUID)
+}
+
+// chargeOverdraft debits the account with the whole minor units of the
+// overdraft interest and with the overdraft fee when the account was below
+// zero since the last payout; both go to the revenue account.
+func (p *PaymentSystem) chargeOverdraft(repo repository.Repository, account *models.Account, accrual *models.InterestAccrual) (bool, error) {
+	interest, rest, err := wholeUnits(accrual.Overdraft, account.Currency)
+	if err != nil {
+		return false, err
+	}
+	accrual.Overdraft = rest
+	fee := models.NewMoney(0, models.CurrencyExponent(account.Currency))
+	if accrual.OverdrawnDays > 0 && p.OverdraftFee.IsPositive() {
+		fee, err = p.convert(p.OverdraftFee, DEFAULT_CURRENCY, account.Currency)
+		if err != nil {
+			return false, err
+		}
+		fee = fee.ForCurrency(account.Currency)
+	}
+	charged := false
+	for _, charge := range []struct {
+		amount models.Money
+		kind   string
+	}{{interest, OVERDRAFT_INTEREST}, {fee, OVERDRAFT_FEE}} {
+		if !charge.amount.IsPositive() {
+			continue
+		}
+		err = systemTransaction(repo, revenueAccount(account.Currency), account, charge.amount.Neg(), charge.kind)
+		if err != nil {
+			return false, err
+		}
+		charged = true
+	}
+	return charged, nil
+}
@@ -0,0 +1,393 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OPEN     = "open"
+	ACCEPTED = "accepted"
+	DECLINED = "declined"
+
+	DEFAULT_REQUEST_TTL = 14 * 24 * time.Hour
+	// REMINDER_INTERVAL is the least time between two reminders of a request.
+	REMINDER_INTERVAL = 24 * time.Hour
+)
+
+var (
+	ErrPayerRequired  = errors.New("payer email or iban is required")
+	ErrSelfRequest    = errors.New("can't request money from yourself")
+	ErrRequestNotOpen = errors.New("payment request is not open")
+	ErrRemindTooSoon  = errors.New("payment request was reminded recently")
+	ErrSourceRequired = errors.New("account to pay from is required")
+)
+
+type PaymentRequest struct {
+	RequesterUUID uuid.UUID
+	// AccountUUID is the account of the requester the money is paid to.
+	AccountUUID uuid.UUID
+	// PayerEmail asks the user to pay from any own account, PayerIBAN asks
+	// for the payment from the account of the IBAN.
+	PayerEmail  string
+	PayerIBAN   string
+	Amount      models.Money
+	Description string
+	// TTL is DEFAULT_REQUEST_TTL when it is zero.
+	TTL time.Duration
+}
+
+// RequestMoney asks the payer to pay the amount in the currency of the account
+// to it; the payer sees the request among the incoming ones until it is
+// accepted, declined, cancelled or expires.
+func (p *PaymentSystem) RequestMoney(r PaymentRequest) (models.PaymentRequest, error) {
+	request, err := newPaymentRequest(p.Repo, r)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	err = p.Repo.CreatePaymentRequest(request)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return p.GetPaymentRequest(request.AccountUUID, request.UUID)
+}
+
+// newPaymentRequest resolves the payer of the request and checks it, the
+// request isn't stored.
+func newPaymentRequest(repo repository.Repository, r PaymentRequest) (models.PaymentRequest, error) {
+	account, err := repo.GetAccountByUUID(r.AccountUUID)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	if account.Status == CLOSED {
+		return models.PaymentRequest{}, ErrAccountClosed
+	}
+	amount, err := r.Amount.Rescale(models.CurrencyExponent(account.Currency))
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	if !amount.IsPositive() {
+		return models.PaymentRequest{}, ErrWrongAmount
+	}
+	if r.TTL == 0 {
+		r.TTL = DEFAULT_REQUEST_TTL
+	}
+	request := models.PaymentRequest{
+		RequesterUUID: r.RequesterUUID,
+		AccountUUID:   account.UUID,
+		Amount:        amount,
+		Currency:      account.Currency,
+		Description:   r.Description,
+		Status:        OPEN,
+		ExpiresAt:     time.Now().Add(r.TTL),
+	}
+	switch {
+	case r.PayerIBAN != "":
+		iban, err := ValidateIBAN(r.PayerIBAN)
+		if err != nil {
+			return models.PaymentRequest{}, err
+		}
+		payerAccount, err := repo.GetAccountByIBAN(iban)
+		if err != nil {
+			return models.PaymentRequest{}, err
+		}
+		if payerAccount.UUID == account.UUID {
+			return models.PaymentRequest{}, ErrWrongDestination
+		}
+		request.PayerUUID = payerAccount.UserUUID
+		request.PayerAccountUUID = payerAccount.UUID
+	case r.PayerEmail != "":
+		payer, err := repo.GetUserByEmail(r.PayerEmail)
+		if err != nil {
+			return models.PaymentRequest{}, err
+		}
+		request.PayerUUID = payer.UUID
+	default:
+		return models.PaymentRequest{}, ErrPayerRequired
+	}
+	if request.PayerUUID == r.RequesterUUID {
+		return models.PaymentRequest{}, ErrSelfRequest
+	}
+	request.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return request, nil
+}
+
+// GetPaymentRequests returns the requests made to be paid to the account.
+func (p *PaymentSystem) GetPaymentRequests(accountUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	return p.Repo.GetPaymentRequestsForAccount(accountUUID, query)
+}
+
+func (p *PaymentSystem) GetPaymentRequest(accountUUID, requestUUID uuid.UUID) (models.PaymentRequest, error) {
+	request, err := p.Repo.GetPaymentRequestByUUID(requestUUID)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	if request.AccountUUID != accountUUID {
+		return models.PaymentRequest{}, ErrPermissionDenied
+	}
+	return *request, nil
+}
+
+// GetIncomingPaymentRequests returns the requests the user is asked to pay.
+func (p *PaymentSystem) GetIncomingPaymentRequests(payerUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	return p.Repo.GetPaymentRequestsForPayer(payerUUID, query)
+}
+
+// openRequest locks the accounts and returns the request when it can still
+// be paid; the payer account is locked when it is set.
+func openRequest(repo repository.Repository, requestUUID, payerAccountUUID uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := repo.GetPaymentRequestByUUID(requestUUID)
+	if err != nil {
+		return nil, err
+	}
+	locked := []uuid.UUID{request.AccountUUID}
+	if payerAccountUUID != uuid.Nil {
+		locked = append(locked, payerAccountUUID)
+	}
+	err = lockAccounts(repo, locked...)
+	if err != nil {
+		return nil, err
+	}
+	// the request may have been answered while the locks were awaited
+	request, err = repo.GetPaymentRequestByUUID(requestUUID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != OPEN || !request.ExpiresAt.After(time.Now()) {
+		return nil, ErrRequestNotOpen
+	}
+	return request, nil
+}
+
+// AcceptPaymentRequest pays the request from the source account of the payer
+// by a new transaction which is sent at once, the pocket is the one the money
+// is spent from. The requested amount is converted to the currency of the
+// source account; the source is fixed when the request was made to an IBAN.
+// The request is accepted when the transaction is sent, a transaction waiting
+// for approval leaves it pending until the transaction is reviewed.
+func (p *PaymentSystem) AcceptPaymentRequest(payerUUID, requestUUID, sourceUUID, pocketUUID uuid.UUID) (models.Transaction, error) {
+	request, err := p.Repo.GetPaymentRequestByUUID(requestUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if request.PayerUUID != payerUUID {
+		return models.Transaction{}, ErrPermissionDenied
+	}
+	if request.PayerAccountUUID != uuid.Nil {
+		if sourceUUID != uuid.Nil && sourceUUID != request.PayerAccountUUID {
+			return models.Transaction{}, ErrPermissionDenied
+		}
+		sourceUUID = request.PayerAccountUUID
+	}
+	if sourceUUID == uuid.Nil {
+		return models.Transaction{}, ErrSourceRequired
+	}
+	err = p.CheckAccountPermission(payerUUID, sourceUUID, SEND)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	err = checkActive(p.Repo, sourceUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	source, err := p.Repo.GetAccountByUUID(sourceUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	amount := request.Amount
+	if source.Currency != request.Currency {
+		rate, err := p.Rates.Rate(request.Currency, source.Currency)
+		if err != nil {
+			return models.Transaction{}, err
+		}
+		amount, err = scale(amount, new(big.Rat).SetFloat64(rate), models.CurrencyExponent(source.Currency))
+		if err != nil {
+			return models.Transaction{}, err
+		}
+	}
+	transaction, err := p.prepare(Transaction{
+		UserUUID:        payerUUID,
+		SourceUUID:      sourceUUID,
+		DestinationUUID: request.AccountUUID,
+		PocketUUID:      pocketUUID,
+		Amount:          amount,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			request, err := openRequest(repo, requestUUID, sourceUUID)
+			if err != nil {
+				return err
+			}
+			err = createTransaction(repo, transaction)
+			if err != nil {
+				return err
+			}
+			err = p.sendIn(repo, transaction.UUID, PREPARED)
+			if err != nil {
+				return err
+			}
+			sent, err := repo.GetTransactionByUUID(transaction.UUID)
+			if err != nil {
+				return err
+			}
+			accepted := *request
+			accepted.TransactionUUID = transaction.UUID
+			if sent.Status == PENDING_APPROVAL {
+				accepted.Status = PENDING_APPROVAL
+				return repo.UpdatePaymentRequest(accepted)
+			}
+			accepted.Status = ACCEPTED
+			accepted.PayerAccountUUID = sourceUUID
+			return repo.UpdatePaymentRequest(accepted)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transactionModel, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *transactionModel, nil
+}
+
+// settlePaymentRequest accepts the pending request paid by the reviewed
+// transaction when it is sent and opens the request again when it isn't.
+func settlePaymentRequest(repo repository.Repository, transaction *models.Transaction) error {
+	request, err := repo.GetPaymentRequestForTransaction(transaction.UUID)
+	if errors.Is(err, repository.ErrorUnknownPaymentRequest) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if request.Status != PENDING_APPROVAL {
+		return nil
+	}
+	settled := *request
+	if transaction.Status == SENT {
+		settled.Status = ACCEPTED
+		settled.PayerAccountUUID = transaction.SourceUUID
+	} else {
+		settled.Status = OPEN
+		settled.Reason = "payment " + transaction.Status
+		settled.TransactionUUID = uuid.Nil
+	}
+	return repo.UpdatePaymentRequest(settled)
+}
+
+// DeclinePaymentRequest refuses to pay the request.
+func (p *PaymentSystem) DeclinePaymentRequest(payerUUID, requestUUID uuid.UUID, reason string) (models.PaymentRequest, error) {
+	var declined models.PaymentRequest
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			request, err := openRequest(repo, requestUUID, uuid.Nil)
+			if err != nil {
+				return err
+			}
+			if request.PayerUUID != payerUUID {
+				return ErrPermissionDenied
+			}
+			declined = *request
+			declined.Status = DECLINED
+			declined.Reason = reason
+			return repo.UpdatePaymentRequest(declined)
+		})
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return p.GetPaymentRequest(declined.AccountUUID, requestUUID)
+}
+
+// CancelPaymentRequest withdraws the open request of the account.
+func (p *PaymentSystem) CancelPaymentRequest(accountUUID, requestUUID uuid.UUID) (models.PaymentRequest, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			request, err := openRequest(repo, requestUUID, uuid.Nil)
+			if err != nil {
+				return err
+			}
+			if request.AccountUUID != accountUUID {
+				return ErrPermissionDenied
+			}
+			cancelled := *request
+			cancelled.Status = CANCELLED
+			cancelled.Reason = "cancelled by requester"
+			return repo.UpdatePaymentRequest(cancelled)
+		})
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return p.GetPaymentRequest(accountUUID, requestUUID)
+}
+
+// RemindPaymentRequest reminds the payer of the open request, which moves it
+// up among the incoming requests of the payer; a request is reminded at most
+// once per REMINDER_INTERVAL.
+func (p *PaymentSystem) RemindPaymentRequest(accountUUID, requestUUID uuid.UUID, now time.Time) (models.PaymentRequest, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			request, err := openRequest(repo, requestUUID, uuid.Nil)
+			if err != nil {
+				return err
+			}
+			if request.AccountUUID != accountUUID {
+				return ErrPermissionDenied
+			}
+			if request.RemindedAt != nil && now.Sub(*request.RemindedAt) < REMINDER_INTERVAL {
+				return ErrRemindTooSoon
+			}
+			reminded := *request
+			reminded.Reminders++
+			reminded.RemindedAt = &now
+			return repo.UpdatePaymentRequest(reminded)
+		})
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return p.GetPaymentRequest(accountUUID, requestUUID)
+}
+
+// ExpirePaymentRequests expires the open requests which were not paid in time
+// and returns how many of them were expired.
+func (p *PaymentSystem) ExpirePaymentRequests(now time.Time) (int, error) {
+	requests, err := p.Repo.GetExpiredPaymentRequests(now)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, r := range requests {
+		err := p.Repo.Transaction(
+			func(repo repository.Repository) error {
+				err := lockAccounts(repo, r.AccountUUID)
+				if err != nil {
+					return err
+				}
+				request, err := repo.GetPaymentRequestByUUID(r.UUID)
+				if err != nil {
+					return err
+				}
+				if request.Status != OPEN {
+					return ErrRequestNotOpen
+				}
+				request.Status = EXPIRED
+				request.Reason = "not paid in time"
+				return repo.UpdatePaymentRequest(*request)
+			})
+		if err != nil {
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
@@ -2,12 +2,17 @@ package core
 
 import (
 	"errors"
+	"math"
 	"payment/models"
 	"payment/repository"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
 )
 
 func TestRegister(t *testing.T) {
@@ -198,7 +203,7 @@ func TestCreateNewAccountSucces(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	if _, err := system.NewAccount(bob.UUID); err != nil {
+	if _, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL); err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
 }
@@ -212,7 +217,7 @@ func TestCreateNewAccountUnknownUser(t *testing.T) {
 		Email:     "bob.black@gmail.com",
 		Password:  "bob123",
 	}
-	account, err := system.NewAccount(bob.UUID)
+	account, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if !assert.IsEqual(err, repository.ErrorUnknownUser) {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -239,7 +244,7 @@ func TestGetAccounts(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	if _, err := system.NewAccount(bob.UUID); err != nil {
+	if _, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL); err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
 	accs, err := system.GetAccounts(bob.UUID, models.QueryParams{
@@ -256,7 +261,7 @@ func TestGetAccounts(t *testing.T) {
 	if accs[0].UserUUID != bob.UUID {
 		t.Errorf("different userUUID : %v, exp: %v", accs[0].UserUUID, bob.UUID)
 	}
-	if accs[0].Balance != 0 {
+	if !accs[0].Balance.IsZero() {
 		t.Errorf("balance has to be 0")
 	}
 
@@ -278,20 +283,39 @@ func TestAddMoney(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	account, err := system.NewAccount(bob.UUID)
+	account, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
-	if _, err := system.AddMoney(account.UUID, 100); err != nil {
+	if _, err := system.AddMoney(account.UUID, money(100)); err != nil {
 		t.Errorf("add money error: %v", err)
 	}
-	acc, err := system.AddMoney(account.UUID, 45)
+	acc, err := system.AddMoney(account.UUID, money(45))
 	if err != nil {
 		t.Errorf("add money error: %v", err)
 	}
-	if acc.Balance != 145 {
+	if acc.Balance.Cmp(money(145)) != 0 {
 		t.Errorf("wrong balance: %v exp: %v", acc.Balance, 145)
 	}
+	// only admins debit accounts, by DebitAccount
+	for _, amount := range []models.Money{money(0), money(-5)} {
+		if _, err := system.AddMoney(account.UUID, amount); !assert.IsEqual(err, ErrWrongAmount) {
+			t.Errorf("add money %v: %v, exp: %v", amount, err, ErrWrongAmount)
+		}
+		if _, err := system.DebitAccount(account.UUID, amount); !assert.IsEqual(err, ErrWrongAmount) {
+			t.Errorf("debit %v: %v, exp: %v", amount, err, ErrWrongAmount)
+		}
+	}
+	if _, err := system.DebitAccount(account.UUID, money(146)); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("debit over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	acc, err = system.DebitAccount(account.UUID, money(45))
+	if err != nil || acc.Balance.Cmp(money(100)) != 0 {
+		t.Errorf("debit account: %v, %v exp: %v", acc.Balance, err, 100)
+	}
+	if err := system.CheckLedger(account.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
 }
 
 func TestCreateTransactionSuccess(t *testing.T) {
@@ -310,11 +334,11 @@ func TestCreateTransactionSuccess(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	source, err := system.NewAccount(bob.UUID)
+	source, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
-	destination, err := system.NewAccount(bob.UUID)
+	destination, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -322,11 +346,18 @@ func TestCreateTransactionSuccess(t *testing.T) {
 		UserUUID:        bob.UUID,
 		SourceUUID:      source.UUID,
 		DestinationUUID: destination.UUID,
-		Amount:          0,
+		Amount:          models.Money{},
 	}
 	if err := system.CheckAccountExists(bob.UUID, source.UUID); err != nil {
 		t.Errorf("unappropriate account for user: %v", err)
 	}
+	if _, err := system.NewTransaction(tr); !assert.IsEqual(err, ErrWrongAmount) {
+		t.Errorf("zero amount: %v, exp: %v", err, ErrWrongAmount)
+	}
+	if _, err := system.AddMoney(source.UUID, money(10)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	tr.Amount = money(10)
 	transaction, err := system.NewTransaction(tr)
 	if err != nil {
 		t.Errorf("create new transaction error: %v", err)
@@ -373,11 +404,11 @@ func TestCreateTransactionInsufficientFunds(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	source, err := system.NewAccount(bob.UUID)
+	source, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
-	destination, err := system.NewAccount(bob.UUID)
+	destination, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -385,7 +416,7 @@ func TestCreateTransactionInsufficientFunds(t *testing.T) {
 		UserUUID:        bob.UUID,
 		SourceUUID:      source.UUID,
 		DestinationUUID: destination.UUID,
-		Amount:          1000,
+		Amount:          money(1000),
 	}
 	if err := system.CheckAccountExists(bob.UUID, source.UUID); err != nil {
 		t.Errorf("unappropriate account for user: %v", err)
@@ -424,7 +455,7 @@ func TestCreateTransactionsFailed(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	source, err := system.NewAccount(bob.UUID)
+	source, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -449,14 +480,14 @@ func TestSendTransactionSuccess(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	source, err := system.NewAccount(bob.UUID)
+	source, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
-	if _, err := system.AddMoney(source.UUID, 123); err != nil {
+	if _, err := system.AddMoney(source.UUID, money(123)); err != nil {
 		t.Errorf("add money error: %v", err)
 	}
-	destination, err := system.NewAccount(bob.UUID)
+	destination, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -464,7 +495,7 @@ func TestSendTransactionSuccess(t *testing.T) {
 		UserUUID:        bob.UUID,
 		SourceUUID:      source.UUID,
 		DestinationUUID: destination.UUID,
-		Amount:          100,
+		Amount:          money(100),
 	}
 	if err := system.CheckAccountExists(bob.UUID, source.UUID); err != nil {
 		t.Errorf("unappropriate account for user: %v", err)
@@ -496,7 +527,7 @@ func TestSendTransactionSuccess(t *testing.T) {
 	if !reflect.DeepEqual(transactionsSource[0], transactionsDestination[0]) {
 		t.Error("diff transactions")
 	}
-	if _, err := system.SendTransaction(transaction.UUID); err != nil {
+	if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); err != nil {
 		t.Errorf("send transaction err: %v", err)
 	}
 	tranc, err := system.Repo.GetTransactionByUUID(transactionsSource[0].UUID)
@@ -507,11 +538,11 @@ func TestSendTransactionSuccess(t *testing.T) {
 		t.Errorf("sent transaction error: %v", err)
 	}
 
-	if balance, _ := system.ShowBalance(source.UUID); balance != 23 {
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(23)) != 0 {
 		t.Errorf("diff balance: %v, exp %v", balance, 23)
 	}
 
-	if balance, _ := system.ShowBalance(destination.UUID); balance != 100 {
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(100)) != 0 {
 		t.Errorf("diff balance: %v, exp %v", balance, 100)
 	}
 
@@ -614,7 +645,7 @@ func TestBlockRequestUnblockAccount(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	account, err := system.NewAccount(bob.UUID)
+	account, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -667,7 +698,7 @@ func TestRequestUnblockAccountFailed(t *testing.T) {
 	if err != nil {
 		t.Errorf("login error: %v", err)
 	}
-	account, err := system.NewAccount(bob.UUID)
+	account, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
 	if err != nil {
 		t.Errorf("create new account error: %v", err)
 	}
@@ -676,3 +707,2015 @@ func TestRequestUnblockAccountFailed(t *testing.T) {
 	}
 
 }
+
+func TestIdempotentRequest(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob := &models.User{
+		FisrtName: "Bob",
+		LastName:  "Black",
+		Email:     "bob.black@gmail.com",
+		Password:  "bob123",
+	}
+	if err := system.Register(bob); err != nil {
+		t.Errorf("register error: %v", err)
+	}
+	stored, err := system.BeginIdempotentRequest(bob.UUID, "key", "hash")
+	if err != nil || stored != nil {
+		t.Fatalf("begin idempotent request: %v, stored: %v", err, stored)
+	}
+	if _, err := system.BeginIdempotentRequest(bob.UUID, "key", "hash"); !assert.IsEqual(err, ErrIdempotencyInProgress) {
+		t.Errorf("begin idempotent request: %v, exp: %v", err, ErrIdempotencyInProgress)
+	}
+	if err := system.FinishIdempotentRequest(bob.UUID, "key", 200, []byte(`{"message":"add money"}`)); err != nil {
+		t.Errorf("finish idempotent request: %v", err)
+	}
+	stored, err = system.BeginIdempotentRequest(bob.UUID, "key", "hash")
+	if err != nil {
+		t.Fatalf("begin idempotent request: %v", err)
+	}
+	if stored.ResponseCode != 200 || string(stored.ResponseBody) != `{"message":"add money"}` {
+		t.Errorf("wrong stored response: %v %s", stored.ResponseCode, stored.ResponseBody)
+	}
+	if _, err := system.BeginIdempotentRequest(bob.UUID, "key", "other hash"); !assert.IsEqual(err, ErrIdempotencyConflict) {
+		t.Errorf("begin idempotent request: %v, exp: %v", err, ErrIdempotencyConflict)
+	}
+	if _, err := system.BeginIdempotentRequest(bob.UUID, "", "hash"); !assert.IsEqual(err, ErrIdempotencyKey) {
+		t.Errorf("begin idempotent request: %v, exp: %v", err, ErrIdempotencyKey)
+	}
+	// the stored response isn't released
+	if err := system.ReleaseIdempotentRequest(bob.UUID, "key"); err != nil {
+		t.Errorf("release finished request: %v", err)
+	}
+	if stored, err := system.BeginIdempotentRequest(bob.UUID, "key", "hash"); err != nil || stored == nil {
+		t.Errorf("begin released finished request: %v, stored: %v", err, stored)
+	}
+	// the key of the failed request is released and the abandoned one expires
+	if _, err := system.BeginIdempotentRequest(bob.UUID, "failed", "hash"); err != nil {
+		t.Fatalf("begin idempotent request: %v", err)
+	}
+	if err := system.ReleaseIdempotentRequest(bob.UUID, "failed"); err != nil {
+		t.Errorf("release idempotent request: %v", err)
+	}
+	if stored, err := system.BeginIdempotentRequest(bob.UUID, "failed", "hash"); err != nil || stored != nil {
+		t.Errorf("begin released request: %v, stored: %v", err, stored)
+	}
+	abandoned, err := testRepo.GetIdempotencyKey(bob.UUID, "failed")
+	if err != nil {
+		t.Fatalf("get idempotency key: %v", err)
+	}
+	abandoned.CreatedAt = abandoned.CreatedAt.Add(-IDEMPOTENCY_TIMEOUT)
+	if stored, err := system.BeginIdempotentRequest(bob.UUID, "failed", "hash"); err != nil || stored != nil {
+		t.Errorf("begin abandoned request: %v, stored: %v", err, stored)
+	}
+	if _, err := system.BeginIdempotentRequest(bob.UUID, "failed", "hash"); !assert.IsEqual(err, ErrIdempotencyInProgress) {
+		t.Errorf("begin taken over request: %v, exp: %v", err, ErrIdempotencyInProgress)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob := &models.User{
+		FisrtName: "Bob",
+		LastName:  "Black",
+		Email:     "bob.black@gmail.com",
+		Password:  "bob123",
+	}
+	if err := system.Register(bob); err != nil {
+		t.Errorf("register error: %v", err)
+	}
+	source, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
+	if err != nil {
+		t.Errorf("create new account error: %v", err)
+	}
+	destination, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
+	if err != nil {
+		t.Errorf("create new account error: %v", err)
+	}
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	transaction, err := system.NewTransaction(Transaction{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(40),
+	})
+	if err != nil {
+		t.Errorf("create new transaction error: %v", err)
+	}
+	if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); err != nil {
+		t.Errorf("send transaction err: %v", err)
+	}
+	entries, err := system.GetLedgerEntries(source.UUID, models.QueryParams{Limit: 30})
+	if err != nil {
+		t.Errorf("get ledger entries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("wrong number of entries: %v, exp: %v", len(entries), 2)
+	}
+	for _, account := range []uuid.UUID{source.UUID, destination.UUID} {
+		if err := system.CheckLedger(account); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+	if balance, _ := system.LedgerBalance(destination.UUID); balance.Cmp(money(40)) != 0 {
+		t.Errorf("diff ledger balance: %v, exp %v", balance, 40)
+	}
+	var debit, credit models.Money
+	for _, entry := range testRepo.Ledger {
+		var err error
+		if entry.Direction == DEBIT {
+			debit, err = debit.Add(entry.Amount)
+		} else {
+			credit, err = credit.Add(entry.Amount)
+		}
+		if err != nil {
+			t.Fatalf("sum ledger: %v", err)
+		}
+	}
+	if debit.Cmp(credit) != 0 {
+		t.Errorf("unbalanced ledger: debit %v, credit %v", debit, credit)
+	}
+	testRepo.Accounts[source.UUID].Balance = money(1000)
+	if err := system.CheckLedger(source.UUID); !assert.IsEqual(err, ErrLedgerMismatch) {
+		t.Errorf("check ledger: %v, exp: %v", err, ErrLedgerMismatch)
+	}
+
+	// balances from before the ledger get opening entries once
+	testRepo.Accounts[destination.UUID].Balance = money(25)
+	if opened, err := system.OpenLedger(); err != nil || opened != 2 {
+		t.Errorf("open ledger: %v, err %v, exp: %v", opened, err, 2)
+	}
+	for _, account := range []uuid.UUID{source.UUID, destination.UUID} {
+		if err := system.CheckLedger(account); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+	testRepo.Accounts[source.UUID].Balance = money(2000)
+	if opened, err := system.OpenLedger(); err != nil || opened != 0 {
+		t.Errorf("open ledger again: %v, err %v, exp: %v", opened, err, 0)
+	}
+	if err := system.CheckLedger(source.UUID); !assert.IsEqual(err, ErrLedgerMismatch) {
+		t.Errorf("check ledger: %v, exp: %v", err, ErrLedgerMismatch)
+	}
+}
+
+func setupAccounts(t *testing.T, system *PaymentSystem, email string, n int) (*models.User, []models.Account) {
+	user := &models.User{
+		FisrtName: "Bob",
+		LastName:  "Black",
+		Email:     email,
+		Password:  "bob123",
+		Status:    ACTIVE,
+	}
+	if err := system.Register(user); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	accounts := make([]models.Account, n)
+	for i := range accounts {
+		account, err := system.NewAccount(user.UUID, DEFAULT_CURRENCY, PERSONAL)
+		if err != nil {
+			t.Fatalf("create new account error: %v", err)
+		}
+		accounts[i] = account
+	}
+	return user, accounts
+}
+
+func money(units int64) models.Money {
+	return models.NewMoney(units*100, models.DEFAULT_EXPONENT)
+}
+
+func sendMoney(t *testing.T, system *PaymentSystem, user *models.User, source, destination models.Account, amount models.Money) models.Transaction {
+	transaction, err := system.NewTransaction(Transaction{
+		UserUUID:        user.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          amount,
+	})
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	sent, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID)
+	if err != nil {
+		t.Fatalf("send transaction err: %v", err)
+	}
+	return sent
+}
+
+func TestRefundTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	transaction := sendMoney(t, &system, bob, source, destination, money(80))
+	if _, err := system.RefundReceivedTransaction(source.UUID, transaction.UUID, money(10)); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("refund by sender: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	refund, err := system.RefundReceivedTransaction(destination.UUID, transaction.UUID, money(30))
+	if err != nil {
+		t.Fatalf("partial refund error: %v", err)
+	}
+	if refund.OriginalUUID != transaction.UUID || refund.SourceUUID != destination.UUID || refund.Amount.Cmp(money(30)) != 0 {
+		t.Errorf("wrong refund transaction: %v", refund)
+	}
+	if _, err := system.RefundTransaction(transaction.UUID, money(60)); !assert.IsEqual(err, ErrRefundAmount) {
+		t.Errorf("refund over amount: %v, exp: %v", err, ErrRefundAmount)
+	}
+	if _, err := system.RefundTransaction(transaction.UUID, models.Money{}); err != nil {
+		t.Errorf("full refund error: %v", err)
+	}
+	original, _ := system.Repo.GetTransactionByUUID(transaction.UUID)
+	if original.Status != REVERSED {
+		t.Errorf("wrong status: %v, exp: %v", original.Status, REVERSED)
+	}
+	if _, err := system.RefundTransaction(transaction.UUID, models.Money{}); !assert.IsEqual(err, ErrNotRefundable) {
+		t.Errorf("refund reversed transaction: %v, exp: %v", err, ErrNotRefundable)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 100)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(0)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 0)
+	}
+}
+
+func TestCancelExpireTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	tr := Transaction{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(10),
+	}
+	cancelled, err := system.NewTransaction(tr)
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	if _, err := system.CancelTransaction(destination.UUID, cancelled.UUID); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("cancel foreign transaction: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	if _, err := system.CancelTransaction(source.UUID, cancelled.UUID); err != nil {
+		t.Errorf("cancel transaction error: %v", err)
+	}
+	if _, err := system.SendTransaction(cancelled.SourceUUID, cancelled.UUID); !assert.IsEqual(err, ErrTransactionNotPrepared) {
+		t.Errorf("send cancelled transaction: %v, exp: %v", err, ErrTransactionNotPrepared)
+	}
+	stale, err := system.NewTransaction(tr)
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	testRepo.Transactions[stale.UUID].CreatedAt = time.Now().Add(-2 * DEFAULT_TRANSACTION_TTL)
+	fresh, err := system.NewTransaction(tr)
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	expired, err := system.ExpirePreparedTransactions(time.Now().Add(-DEFAULT_TRANSACTION_TTL))
+	if err != nil || expired != 1 {
+		t.Errorf("expire transactions: %v, expired %v, exp: %v", err, expired, 1)
+	}
+	if status := testRepo.Transactions[stale.UUID].Status; status != EXPIRED {
+		t.Errorf("wrong status: %v, exp: %v", status, EXPIRED)
+	}
+	if _, err := system.SendTransaction(stale.SourceUUID, stale.UUID); !assert.IsEqual(err, ErrTransactionNotPrepared) {
+		t.Errorf("send expired transaction: %v, exp: %v", err, ErrTransactionNotPrepared)
+	}
+	if _, err := system.SendTransaction(fresh.SourceUUID, fresh.UUID); err != nil {
+		t.Errorf("send transaction err: %v", err)
+	}
+	if _, err := system.SendTransaction(fresh.SourceUUID, fresh.UUID); !assert.IsEqual(err, ErrTransactionNotPrepared) {
+		t.Errorf("send transaction twice: %v, exp: %v", err, ErrTransactionNotPrepared)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(90)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 90)
+	}
+}
+
+func TestTransactionStatusHistory(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	transaction := sendMoney(t, &system, bob, source, destination, money(50))
+	details, err := system.GetTransaction(destination.UUID, transaction.UUID)
+	if err != nil {
+		t.Fatalf("get transaction error: %v", err)
+	}
+	expStatuses := []string{PREPARED, PROCESSING, SENT}
+	if len(details.History) != len(expStatuses) {
+		t.Fatalf("wrong history length: %v, exp: %v", len(details.History), len(expStatuses))
+	}
+	for i, change := range details.History {
+		if change.To != expStatuses[i] {
+			t.Errorf("wrong status change %v: %v, exp: %v", i, change.To, expStatuses[i])
+		}
+	}
+	if _, err := system.GetTransaction(accounts[2].UUID, transaction.UUID); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("get foreign transaction: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	if err := updateStatus(&testRepo, &transaction, PROCESSING, ""); !assert.IsEqual(err, ErrStatusTransition) {
+		t.Errorf("update status: %v, exp: %v", err, ErrStatusTransition)
+	}
+	if err := updateStatus(&testRepo, &transaction, CANCELLED, ""); !assert.IsEqual(err, ErrStatusTransition) {
+		t.Errorf("update status: %v, exp: %v", err, ErrStatusTransition)
+	}
+}
+
+func TestScheduledTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	executeAt := time.Now().Add(time.Hour)
+	tr := Transaction{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(70),
+		ExecuteAt:       executeAt,
+	}
+	if _, err := system.NewTransaction(Transaction{SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(70), ExecuteAt: time.Now().Add(-time.Hour)}); !assert.IsEqual(err, ErrExecuteAt) {
+		t.Errorf("schedule in the past: %v, exp: %v", err, ErrExecuteAt)
+	}
+	rent, err := system.NewTransaction(tr)
+	if err != nil {
+		t.Fatalf("create scheduled transaction error: %v", err)
+	}
+	if rent.Status != SCHEDULED {
+		t.Errorf("wrong status: %v, exp: %v", rent.Status, SCHEDULED)
+	}
+	if _, err := system.SendTransaction(rent.SourceUUID, rent.UUID); !assert.IsEqual(err, ErrTransactionNotPrepared) {
+		t.Errorf("send scheduled transaction: %v, exp: %v", err, ErrTransactionNotPrepared)
+	}
+	if sent, _ := system.ExecuteScheduledTransactions(time.Now()); sent != 0 {
+		t.Errorf("sent not due transactions: %v", sent)
+	}
+	now := executeAt.Add(time.Minute)
+	if sent, _ := system.ExecuteScheduledTransactions(now); sent != 0 {
+		t.Errorf("sent transaction without money: %v", sent)
+	}
+	retried, _ := system.Repo.GetTransactionByUUID(rent.UUID)
+	if retried.Status != SCHEDULED || retried.Attempts != 1 || !retried.ExecuteAt.Equal(now.Add(RETRY_DELAY)) {
+		t.Errorf("wrong retry: %v, attempts %v, execute at %v", retried.Status, retried.Attempts, retried.ExecuteAt)
+	}
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	if sent, _ := system.ExecuteScheduledTransactions(now.Add(RETRY_DELAY)); sent != 1 {
+		t.Errorf("sent transactions: %v, exp: %v", sent, 1)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(70)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 70)
+	}
+
+	failed, err := system.NewTransaction(tr)
+	if err != nil {
+		t.Fatalf("create scheduled transaction error: %v", err)
+	}
+	for i := 0; i < MAX_SEND_ATTEMPTS; i++ {
+		system.ExecuteScheduledTransactions(now.Add(time.Duration(i) * RETRY_DELAY))
+	}
+	if status := testRepo.Transactions[failed.UUID].Status; status != FAILED {
+		t.Errorf("wrong status: %v, exp: %v", status, FAILED)
+	}
+	// the failed transaction never was processing
+	details, err := system.GetTransaction(source.UUID, failed.UUID)
+	if err != nil {
+		t.Fatalf("get transaction error: %v", err)
+	}
+	expStatuses := []string{SCHEDULED, FAILED}
+	if len(details.History) != len(expStatuses) {
+		t.Fatalf("wrong history length: %v, exp: %v", len(details.History), len(expStatuses))
+	}
+	for i, change := range details.History {
+		if change.To != expStatuses[i] {
+			t.Errorf("wrong status change %v: %v, exp: %v", i, change.To, expStatuses[i])
+		}
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(30)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 30)
+	}
+}
+
+func TestStandingOrder(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(150)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	year := time.Now().Year() + 1
+	start := time.Date(year, time.January, 31, 10, 0, 0, 0, time.UTC)
+	if _, err := system.NewStandingOrder(StandingOrder{SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(1), Interval: "yearly"}); !assert.IsEqual(err, ErrUnknownInterval) {
+		t.Errorf("create standing order: %v, exp: %v", err, ErrUnknownInterval)
+	}
+	past := time.Now().AddDate(0, 0, -1)
+	if _, err := system.NewStandingOrder(StandingOrder{SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(1), Interval: DAILY, StartAt: past}); !assert.IsEqual(err, ErrStartInPast) {
+		t.Errorf("create standing order in the past: %v, exp: %v", err, ErrStartInPast)
+	}
+	if _, err := system.NewStandingOrder(StandingOrder{SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(1), Interval: DAILY, StartAt: start, EndAt: &past}); !assert.IsEqual(err, ErrEndBeforeStart) {
+		t.Errorf("create standing order ending before start: %v, exp: %v", err, ErrEndBeforeStart)
+	}
+	order, err := system.NewStandingOrder(StandingOrder{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(100),
+		Interval:        MONTHLY,
+		StartAt:         start,
+		MaxRuns:         3,
+	})
+	if err != nil {
+		t.Fatalf("create standing order error: %v", err)
+	}
+	if sent, _ := system.RunStandingOrders(start); sent != 1 {
+		t.Errorf("sent: %v, exp: %v", sent, 1)
+	}
+	order, _ = system.GetStandingOrder(source.UUID, order.UUID)
+	// the last day of February
+	if exp := time.Date(year, time.March, 0, 10, 0, 0, 0, time.UTC); !order.NextRunAt.Equal(exp) {
+		t.Errorf("wrong next run: %v, exp: %v", order.NextRunAt, exp)
+	}
+	if sent, _ := system.RunStandingOrders(order.NextRunAt); sent != 0 {
+		t.Errorf("sent without money: %v", sent)
+	}
+	order, err = system.UpdateStandingOrder(source.UUID, order.UUID, StandingOrder{Amount: money(50)})
+	if err != nil {
+		t.Fatalf("update standing order error: %v", err)
+	}
+	if sent, _ := system.RunStandingOrders(order.NextRunAt); sent != 1 {
+		t.Errorf("sent: %v, exp: %v", sent, 1)
+	}
+	order, _ = system.GetStandingOrder(source.UUID, order.UUID)
+	if order.Status != FINISHED || order.Runs != 3 {
+		t.Errorf("wrong order status: %v, runs: %v", order.Status, order.Runs)
+	}
+	runs, err := system.GetStandingOrderRuns(source.UUID, order.UUID, models.QueryParams{Limit: 30})
+	if err != nil {
+		t.Errorf("get runs error: %v", err)
+	}
+	expStatuses := []string{SENT, FAILED, SENT}
+	for i, run := range runs {
+		if run.Status != expStatuses[i] {
+			t.Errorf("wrong run %v status: %v, exp: %v", i, run.Status, expStatuses[i])
+		}
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(150)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 150)
+	}
+	if _, err := system.CancelStandingOrder(source.UUID, order.UUID); !assert.IsEqual(err, ErrStandingOrderDone) {
+		t.Errorf("cancel finished order: %v, exp: %v", err, ErrStandingOrderDone)
+	}
+	if _, err := system.AddMoney(source.UUID, money(10)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	daily, err := system.NewStandingOrder(StandingOrder{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(1),
+		Interval:        DAILY,
+		StartAt:         start,
+	})
+	if err != nil {
+		t.Fatalf("create daily standing order error: %v", err)
+	}
+	if sent, _ := system.RunStandingOrders(start.AddDate(0, 0, 5)); sent != 1 {
+		t.Errorf("sent overdue: %v, exp: %v", sent, 1)
+	}
+	daily, _ = system.GetStandingOrder(source.UUID, daily.UUID)
+	if exp := start.AddDate(0, 0, 6); !daily.NextRunAt.Equal(exp) {
+		t.Errorf("wrong next run after overdue: %v, exp: %v", daily.NextRunAt, exp)
+	}
+	// a worker with a stale list of the due orders doesn't pay the period again
+	stale := staleOrdersRepo{TestRepo: &testRepo}
+	stale.due, _ = testRepo.GetDueStandingOrders(daily.NextRunAt)
+	if sent, _ := system.RunStandingOrders(daily.NextRunAt); sent != 1 {
+		t.Errorf("sent: %v, exp: %v", sent, 1)
+	}
+	worker := NewPaymentSystem(&stale)
+	if sent, err := worker.RunStandingOrders(daily.NextRunAt); err != nil || sent != 0 {
+		t.Errorf("sent by stale worker: %v, %v, exp: %v", sent, err, 0)
+	}
+	if runs, _ := system.GetStandingOrderRuns(source.UUID, daily.UUID, models.QueryParams{Limit: 30}); len(runs) != 2 {
+		t.Errorf("diff runs: %v, exp: %v", len(runs), 2)
+	}
+}
+
+// staleOrdersRepo returns the due standing orders read before, like a worker
+// which read them before another one ran them.
+type staleOrdersRepo struct {
+	*repository.TestRepo
+	due []models.StandingOrder
+}
+
+func (r *staleOrdersRepo) GetDueStandingOrders(now time.Time) ([]models.StandingOrder, error) {
+	return r.due, nil
+}
+
+func TestExchangeTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.Rates = NewStaticRates("UAH", map[string]float64{"USD": 0.025})
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 1)
+	uah := accounts[0]
+	usd, err := system.NewAccount(bob.UUID, "usd", PERSONAL)
+	if err != nil {
+		t.Fatalf("create new account error: %v", err)
+	}
+	if usd.Currency != "USD" || uah.Currency != DEFAULT_CURRENCY {
+		t.Errorf("wrong currencies: %v, %v", usd.Currency, uah.Currency)
+	}
+	if _, err := system.NewAccount(bob.UUID, "XXX", PERSONAL); !assert.IsEqual(err, ErrUnknownCurrency) {
+		t.Errorf("create account: %v, exp: %v", err, ErrUnknownCurrency)
+	}
+	if _, err := system.AddMoney(uah.UUID, money(4000)); err != nil {
+		t.Errorf("add money error: %v", err)
+	}
+	transaction := sendMoney(t, &system, bob, uah, usd, money(4000))
+	if transaction.Rate != 0.025 || transaction.DestinationAmount.Cmp(money(100)) != 0 || transaction.DestinationCurrency != "USD" {
+		t.Errorf("wrong exchange: rate %v, amount %v %v", transaction.Rate, transaction.DestinationAmount, transaction.DestinationCurrency)
+	}
+	if balance, _ := system.ShowBalance(usd.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 100)
+	}
+	if _, err := system.RefundTransaction(transaction.UUID, money(25)); err != nil {
+		t.Errorf("refund error: %v", err)
+	}
+	if balance, _ := system.ShowBalance(uah.UUID); balance.Cmp(money(1000)) != 0 {
+		t.Errorf("diff balance: %v, exp %v", balance, 1000)
+	}
+	for _, account := range []uuid.UUID{uah.UUID, usd.UUID} {
+		if err := system.CheckLedger(account); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	for _, s := range []string{"0", "12", "-0.5", "12.34", "0.001"} {
+		m, err := models.ParseMoney(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if m.String() != s {
+			t.Errorf("format: %v, exp: %v", m, s)
+		}
+	}
+	for _, s := range []string{"", "1.", ".5", "1,5", "--1", "1e3"} {
+		if _, err := models.ParseMoney(s); !assert.IsEqual(err, models.ErrMoneyFormat) {
+			t.Errorf("parse %q: %v, exp: %v", s, err, models.ErrMoneyFormat)
+		}
+	}
+	if _, err := models.ParseMoney("92233720368547758.08"); !assert.IsEqual(err, models.ErrMoneyOverflow) {
+		t.Errorf("parse overflow: %v, exp: %v", err, models.ErrMoneyOverflow)
+	}
+	if _, err := models.NewMoney(math.MaxInt64, 2).Add(money(1)); !assert.IsEqual(err, models.ErrMoneyOverflow) {
+		t.Errorf("add overflow: %v, exp: %v", err, models.ErrMoneyOverflow)
+	}
+	if _, err := models.NewMoney(1005, 3).Rescale(2); !assert.IsEqual(err, models.ErrMoneyPrecision) {
+		t.Errorf("rescale: %v, exp: %v", err, models.ErrMoneyPrecision)
+	}
+	sum, err := models.NewMoney(10, 1).Add(models.NewMoney(-25, 2))
+	if err != nil || sum.String() != "0.75" {
+		t.Errorf("add: %v %v, exp: %v", sum, err, "0.75")
+	}
+	if m, _ := models.ParseMoney("7.50"); m.Cmp(models.NewMoney(75, 1)) != 0 {
+		t.Errorf("compare: %v, exp: %v", m, "7.5")
+	}
+}
+
+func TestTransferLimits(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	source, second, destination := accounts[0], accounts[1], accounts[2]
+	for _, account := range []models.Account{source, second} {
+		if _, err := system.AddMoney(account.UUID, money(500)); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	if _, err := system.SetLimit(models.Limit{UserUUID: bob.UUID, Daily: money(-1)}); !assert.IsEqual(err, ErrWrongLimit) {
+		t.Errorf("negative limit: %v, exp: %v", err, ErrWrongLimit)
+	}
+	if _, err := system.SetLimit(models.Limit{UserUUID: bob.UUID, AccountUUID: source.UUID, MaxTransfer: money(100), Daily: money(150)}); err != nil {
+		t.Fatalf("set account limit error: %v", err)
+	}
+	userLimit, err := system.SetLimit(models.Limit{UserUUID: bob.UUID, Monthly: money(200)})
+	if err != nil {
+		t.Fatalf("set user limit error: %v", err)
+	}
+	var limitErr *LimitError
+	_, err = system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(120)})
+	if !errors.As(err, &limitErr) || limitErr.Limit != MAX_TRANSFER_LIMIT || limitErr.Scope != ACCOUNT_LIMIT || limitErr.Remaining.Cmp(money(100)) != 0 {
+		t.Errorf("max transfer: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	sendMoney(t, &system, bob, source, destination, money(100))
+	_, err = system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(60)})
+	if !errors.As(err, &limitErr) || limitErr.Limit != DAILY_LIMIT || limitErr.Remaining.Cmp(money(50)) != 0 {
+		t.Errorf("daily limit: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	pending, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: second.UUID, DestinationUUID: destination.UUID, Amount: money(100)})
+	if err != nil {
+		t.Fatalf("create transaction error: %v", err)
+	}
+	sendMoney(t, &system, bob, second, destination, money(100))
+	_, err = system.SendTransaction(pending.SourceUUID, pending.UUID)
+	if !errors.As(err, &limitErr) || limitErr.Limit != MONTHLY_LIMIT || limitErr.Scope != USER_LIMIT || !limitErr.Remaining.IsZero() {
+		t.Errorf("monthly user limit: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	if limits, _ := system.GetLimits(bob.UUID); len(limits) != 2 {
+		t.Errorf("wrong limits: %v, exp: %v", len(limits), 2)
+	}
+	if err := system.DeleteLimit(bob.UUID, userLimit.UUID); err != nil {
+		t.Fatalf("delete limit error: %v", err)
+	}
+	if _, err := system.SendTransaction(pending.SourceUUID, pending.UUID); err != nil {
+		t.Errorf("send without user limit: %v", err)
+	}
+}
+
+func TestApproveTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.ApprovalThreshold = money(100)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(500)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	sendMoney(t, &system, bob, source, destination, money(100))
+	var pending []models.Transaction
+	for _, amount := range []int64{150, 200} {
+		tr, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(amount)})
+		if err != nil {
+			t.Fatalf("create transaction error: %v", err)
+		}
+		tr, err = system.SendTransaction(tr.SourceUUID, tr.UUID)
+		if err != nil {
+			t.Fatalf("send transaction error: %v", err)
+		}
+		if tr.Status != PENDING_APPROVAL {
+			t.Errorf("wrong status: %v, exp: %v", tr.Status, PENDING_APPROVAL)
+		}
+		pending = append(pending, tr)
+	}
+	if queue, _ := system.GetTransactionsPendingApproval(models.QueryParams{Limit: 30}); len(queue) != 2 {
+		t.Errorf("wrong approval queue: %v, exp: %v", len(queue), 2)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(400)) != 0 {
+		t.Errorf("money moved before approval: %v, exp: %v", balance, 400)
+	}
+	if _, err := system.RejectTransaction(pending[0].UUID, ""); !assert.IsEqual(err, ErrRejectReason) {
+		t.Errorf("reject without reason: %v, exp: %v", err, ErrRejectReason)
+	}
+	rejected, err := system.RejectTransaction(pending[0].UUID, "suspicious")
+	if err != nil || rejected.Status != REJECTED {
+		t.Errorf("reject transaction: %v %v, exp: %v", rejected.Status, err, REJECTED)
+	}
+	approved, err := system.ApproveTransaction(pending[1].UUID, "")
+	if err != nil || approved.Status != SENT {
+		t.Errorf("approve transaction: %v %v, exp: %v", approved.Status, err, SENT)
+	}
+	if _, err := system.ApproveTransaction(pending[0].UUID, ""); !assert.IsEqual(err, ErrTransactionNotPending) {
+		t.Errorf("approve rejected transaction: %v, exp: %v", err, ErrTransactionNotPending)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(200)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 200)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(300)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 300)
+	}
+}
+
+func TestApprovalLimits(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.ApprovalThreshold = money(100)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(500)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.SetLimit(models.Limit{UserUUID: bob.UUID, AccountUUID: source.UUID, Daily: money(300)}); err != nil {
+		t.Fatalf("set limit error: %v", err)
+	}
+	pending := sendMoney(t, &system, bob, source, destination, money(200))
+	if pending.Status != PENDING_APPROVAL {
+		t.Fatalf("wrong status: %v, exp: %v", pending.Status, PENDING_APPROVAL)
+	}
+	// the transactions waiting for approval use up the limits
+	var limitErr *LimitError
+	_, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(150)})
+	if !errors.As(err, &limitErr) || limitErr.Limit != DAILY_LIMIT || limitErr.Remaining.Cmp(money(100)) != 0 {
+		t.Errorf("daily limit with pending approval: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	sendMoney(t, &system, bob, source, destination, money(100))
+	// the limits are checked again when the transaction is approved
+	if _, err := system.SetLimit(models.Limit{UserUUID: bob.UUID, AccountUUID: source.UUID, Daily: money(250)}); err != nil {
+		t.Fatalf("set limit error: %v", err)
+	}
+	if _, err := system.ApproveTransaction(pending.UUID, ""); !errors.As(err, &limitErr) || limitErr.Limit != DAILY_LIMIT {
+		t.Errorf("approve over daily limit: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	// the maker cancels the transaction instead
+	if _, err := system.CancelTransaction(destination.UUID, pending.UUID); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("cancel of other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	cancelled, err := system.CancelTransaction(source.UUID, pending.UUID)
+	if err != nil || cancelled.Status != CANCELLED {
+		t.Fatalf("cancel pending transaction: %v, %v, exp: %v", cancelled.Status, err, CANCELLED)
+	}
+	if _, err := system.ApproveTransaction(pending.UUID, ""); !assert.IsEqual(err, ErrTransactionNotPending) {
+		t.Errorf("approve cancelled transaction: %v, exp: %v", err, ErrTransactionNotPending)
+	}
+	sendMoney(t, &system, bob, source, destination, money(100))
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(300)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 300)
+	}
+}
+
+func TestTransactionByIBAN(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.NewTransaction(Transaction{SourceUUID: source.UUID, DestinationIBAN: "UA00000", Amount: money(10)}); !assert.IsEqual(err, ErrInvalidIBAN) {
+		t.Errorf("invalid iban: %v, exp: %v", err, ErrInvalidIBAN)
+	}
+	unknown, err := system.IBANs.Generate()
+	if err != nil {
+		t.Fatalf("generate iban error: %v", err)
+	}
+	if _, err := system.NewTransaction(Transaction{SourceUUID: source.UUID, DestinationIBAN: unknown, Amount: money(10)}); !assert.IsEqual(err, repository.ErrorUnknownAccount) {
+		t.Errorf("unknown iban: %v, exp: %v", err, repository.ErrorUnknownAccount)
+	}
+	if _, err := system.NewTransaction(Transaction{SourceUUID: source.UUID, DestinationUUID: accounts[2].UUID, DestinationIBAN: destination.IBAN, Amount: money(10)}); !assert.IsEqual(err, ErrDestinationMismatch) {
+		t.Errorf("iban mismatch: %v, exp: %v", err, ErrDestinationMismatch)
+	}
+	iban := strings.ToLower(destination.IBAN[:4]) + " " + destination.IBAN[4:8] + " " + destination.IBAN[8:]
+	transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationIBAN: iban, Amount: money(10)})
+	if err != nil {
+		t.Fatalf("create transaction error: %v", err)
+	}
+	if transaction.DestinationUUID != destination.UUID || transaction.SourceIBAN != source.IBAN || transaction.DestinationIBAN != destination.IBAN {
+		t.Errorf("wrong destination: %v %v, exp: %v", transaction.DestinationUUID, transaction.DestinationIBAN, destination.IBAN)
+	}
+	transactions, err := system.GetTransactions(destination.UUID, models.QueryParams{Limit: 30})
+	if err != nil || len(transactions) != 1 || transactions[0].SourceIBAN != source.IBAN {
+		t.Errorf("counterparty iban: %v %v, exp: %v", transactions, err, source.IBAN)
+	}
+}
+
+func TestIBAN(t *testing.T) {
+	for _, iban := range []string{"UA21 3223 1300 0002 6007 2335 6600 1", "GB82 WEST 1234 5698 7654 32", "DE89370400440532013000"} {
+		if _, err := ValidateIBAN(iban); err != nil {
+			t.Errorf("validate %v: %v", iban, err)
+		}
+	}
+	for _, iban := range []string{"UA21 3223 1300 0002 6007 2335 6600 2", "UA2132231300000260072335660", "GB82-WEST-1234-5698-7654-32", "1282WEST12345698765432"} {
+		if _, err := ValidateIBAN(iban); !assert.IsEqual(err, ErrInvalidIBAN) {
+			t.Errorf("validate %v: %v, exp: %v", iban, err, ErrInvalidIBAN)
+		}
+	}
+	if _, err := NewIBANGenerator("XX", DEFAULT_BANK_CODE); !assert.IsEqual(err, ErrIBANCountry) {
+		t.Errorf("unknown country: %v, exp: %v", err, ErrIBANCountry)
+	}
+	if _, err := NewIBANGenerator("UA", "32-23"); !assert.IsEqual(err, ErrIBANBankCode) {
+		t.Errorf("wrong bank code: %v, exp: %v", err, ErrIBANBankCode)
+	}
+	if _, err := NewIBANGenerator("UA", ""); !assert.IsEqual(err, ErrIBANBankCode) {
+		t.Errorf("empty bank code: %v, exp: %v", err, ErrIBANBankCode)
+	}
+	generator, err := NewIBANGenerator("ua", "322313")
+	if err != nil {
+		t.Fatalf("new generator error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		iban, err := generator.Generate()
+		if err != nil {
+			t.Fatalf("generate error: %v", err)
+		}
+		if !strings.HasPrefix(iban, "UA") || iban[4:10] != "322313" {
+			t.Errorf("wrong iban: %v", iban)
+		}
+		if _, err := ValidateIBAN(iban); err != nil {
+			t.Errorf("validate generated %v: %v", iban, err)
+		}
+	}
+}
+
+func TestBackfillIBANs(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	_, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	// accounts opened before the IBANs have a random token which isn't an IBAN
+	token, err := randToken(29)
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	testRepo.Accounts[accounts[0].UUID].IBAN = token
+	testRepo.Accounts[accounts[1].UUID].IBAN = ""
+	filled, err := system.BackfillIBANs()
+	if err != nil || filled != 2 {
+		t.Fatalf("backfill ibans: %v, %v, exp: %v", filled, err, 2)
+	}
+	for _, account := range accounts {
+		stored, _ := system.GetAccount(account.UUID)
+		if _, err := ValidateIBAN(stored.IBAN); err != nil {
+			t.Errorf("wrong iban %v: %v", stored.IBAN, err)
+		}
+	}
+	if stored, _ := system.GetAccount(accounts[2].UUID); stored.IBAN != accounts[2].IBAN {
+		t.Errorf("iban changed: %v, exp: %v", stored.IBAN, accounts[2].IBAN)
+	}
+	if filled, err := system.BackfillIBANs(); err != nil || filled != 0 {
+		t.Errorf("backfill twice: %v, %v, exp: %v", filled, err, 0)
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	source, first, second := accounts[0], accounts[1], accounts[2]
+	if _, err := system.AddMoney(source.UUID, money(300)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.SendBatch(bob.UUID, source.UUID, []BatchItem{{DestinationUUID: first.UUID, Amount: money(1)}}, "all"); !assert.IsEqual(err, ErrBatchMode) {
+		t.Errorf("wrong mode: %v, exp: %v", err, ErrBatchMode)
+	}
+	if _, err := system.SendBatch(bob.UUID, source.UUID, nil, ATOMIC); !assert.IsEqual(err, ErrBatchSize) {
+		t.Errorf("empty batch: %v, exp: %v", err, ErrBatchSize)
+	}
+	over := []BatchItem{{DestinationUUID: first.UUID, Amount: money(200)}, {DestinationUUID: second.UUID, Amount: money(200)}}
+	if _, err := system.SendBatch(bob.UUID, source.UUID, over, PARTIAL); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("batch over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	var batchErr *BatchError
+	invalid := []BatchItem{{DestinationUUID: first.UUID, Amount: money(10)}, {DestinationUUID: source.UUID, Amount: money(10)}}
+	if _, err := system.SendBatch(bob.UUID, source.UUID, invalid, ATOMIC); !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrWrongDestination) {
+		t.Errorf("invalid atomic batch: %v, exp: %v", err, ErrWrongDestination)
+	}
+	if len(testRepo.Transactions) != 0 {
+		t.Errorf("transactions of failed batch: %v", len(testRepo.Transactions))
+	}
+	results, err := system.SendBatch(bob.UUID, source.UUID, []BatchItem{{DestinationUUID: first.UUID, Amount: money(100)}, {DestinationIBAN: second.IBAN, Amount: money(50)}}, ATOMIC)
+	if err != nil {
+		t.Fatalf("atomic batch error: %v", err)
+	}
+	for _, result := range results {
+		if result.Transaction == nil || result.Transaction.Status != SENT {
+			t.Errorf("wrong result %v: %v", result.Index, result.Error)
+		}
+	}
+	results, err = system.SendBatch(bob.UUID, source.UUID, []BatchItem{{DestinationIBAN: "UA00", Amount: money(50)}, {DestinationUUID: first.UUID, Amount: money(50)}}, PARTIAL)
+	if err != nil {
+		t.Fatalf("partial batch error: %v", err)
+	}
+	if results[0].Error != ErrInvalidIBAN.Error() || results[0].Transaction != nil {
+		t.Errorf("wrong result: %v, exp: %v", results[0].Error, ErrInvalidIBAN)
+	}
+	if results[1].Transaction == nil || results[1].Transaction.Status != SENT {
+		t.Errorf("wrong result: %v", results[1].Error)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 100)
+	}
+	if balance, _ := system.ShowBalance(first.UUID); balance.Cmp(money(150)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 150)
+	}
+	// the atomic batch isn't split into sent and waiting transfers
+	system.ApprovalThreshold = money(60)
+	approval := []BatchItem{{DestinationUUID: first.UUID, Amount: money(10)}, {DestinationUUID: second.UUID, Amount: money(70)}}
+	if _, err := system.SendBatch(bob.UUID, source.UUID, approval, ATOMIC); !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrBatchApproval) {
+		t.Errorf("atomic batch with approval: %v, exp: %v", err, ErrBatchApproval)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 100)
+	}
+	results, err = system.SendBatch(bob.UUID, source.UUID, approval, PARTIAL)
+	if err != nil {
+		t.Fatalf("partial batch error: %v", err)
+	}
+	if results[0].Transaction == nil || results[0].Transaction.Status != SENT || results[1].Transaction == nil || results[1].Transaction.Status != PENDING_APPROVAL {
+		t.Errorf("wrong partial results: %v", results)
+	}
+}
+
+func TestTransactionFee(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	business, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, BUSINESS)
+	if err != nil {
+		t.Fatalf("create new account error: %v", err)
+	}
+	for _, account := range []models.Account{source, business} {
+		if _, err := system.AddMoney(account.UUID, money(300)); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: "fixed"}); !assert.IsEqual(err, ErrFeeKind) {
+		t.Errorf("wrong kind: %v, exp: %v", err, ErrFeeKind)
+	}
+	unbounded := []models.FeeTier{{Flat: money(1)}, {UpTo: money(100), Flat: money(2)}}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: TIERED_FEE, Tiers: unbounded}); !assert.IsEqual(err, ErrFeeTiers) {
+		t.Errorf("wrong tiers: %v, exp: %v", err, ErrFeeTiers)
+	}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: PERCENT_FEE, BasisPoints: 20000}); !assert.IsEqual(err, ErrWrongFee) {
+		t.Errorf("wrong basis points: %v, exp: %v", err, ErrWrongFee)
+	}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: PERCENT_FEE, BasisPoints: 100, Min: money(1), Max: money(5)}); err != nil {
+		t.Fatalf("set fee schedule error: %v", err)
+	}
+	tiers := []models.FeeTier{{UpTo: money(100), Flat: models.NewMoney(50, 2)}, {Flat: money(1), BasisPoints: 10}}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{AccountType: BUSINESS, Kind: TIERED_FEE, Tiers: tiers}); err != nil {
+		t.Fatalf("set fee schedule error: %v", err)
+	}
+	for _, c := range []struct {
+		source models.Account
+		amount models.Money
+		fee    models.Money
+	}{
+		{source, money(50), money(1)},
+		{source, money(200), money(2)},
+		{source, money(40), money(1)},
+		{business, money(100), models.NewMoney(50, 2)},
+		{business, money(150), models.NewMoney(115, 2)},
+	} {
+		transaction, err := system.NewTransaction(Transaction{
+			UserUUID:        bob.UUID,
+			SourceUUID:      c.source.UUID,
+			DestinationUUID: destination.UUID,
+			Amount:          c.amount,
+		})
+		if err != nil {
+			t.Fatalf("create new transaction error: %v", err)
+		}
+		if transaction.Fee.Cmp(c.fee) != 0 {
+			t.Errorf("diff fee of %v: %v, exp: %v", c.amount, transaction.Fee, c.fee)
+		}
+		if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); err != nil {
+			t.Errorf("send transaction err: %v", err)
+		}
+	}
+	if _, err := system.NewTransaction(Transaction{
+		UserUUID:        bob.UUID,
+		SourceUUID:      source.UUID,
+		DestinationUUID: destination.UUID,
+		Amount:          money(6),
+	}); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("amount with fee over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(6)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 6)
+	}
+	if balance, _ := system.LedgerBalance(revenueAccount(DEFAULT_CURRENCY)); balance.Cmp(models.NewMoney(565, 2)) != 0 {
+		t.Errorf("diff revenue: %v, exp: %v", balance, "5.65")
+	}
+	for _, account := range []models.Account{source, destination, business} {
+		if err := system.CheckLedger(account.UUID); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+}
+
+func TestAccrueInterest(t *testing.T) {
+	if _, err := ParseInterestRates("savings=abc"); !assert.IsEqual(err, ErrInterestRates) {
+		t.Errorf("wrong rates: %v, exp: %v", err, ErrInterestRates)
+	}
+	rates, err := ParseInterestRates("savings=365, personal=0")
+	if err != nil {
+		t.Fatalf("parse rates error: %v", err)
+	}
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.InterestRates = rates
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 1)
+	personal := accounts[0]
+	savings, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, SAVINGS)
+	if err != nil {
+		t.Fatalf("create new account error: %v", err)
+	}
+	small, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, SAVINGS)
+	if err != nil {
+		t.Fatalf("create new account error: %v", err)
+	}
+	for _, c := range []struct {
+		account models.Account
+		amount  models.Money
+	}{{personal, money(1000)}, {savings, money(1000)}, {small, money(10)}} {
+		if _, err := system.AddMoney(c.account.UUID, c.amount); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	start := time.Date(2024, time.January, 30, 10, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{start, start.Add(time.Hour), start.AddDate(0, 0, 1)} {
+		if paid, err := system.AccrueInterest(now); err != nil || paid != 0 {
+			t.Errorf("accrue interest: %v, %v, exp: %v", paid, err, 0)
+		}
+	}
+	// 3.65% a year of 1000 is 0.10 a day
+	if _, accrual, _ := system.GetInterest(savings.UUID); accrual.Accrued.Cmp(models.NewMoney(10, 2)) != 0 {
+		t.Errorf("diff accrued: %v, exp: %v", accrual.Accrued, "0.10")
+	}
+	if paid, err := system.AccrueInterest(start.AddDate(0, 0, 2)); err != nil || paid != 1 {
+		t.Errorf("pay interest: %v, %v, exp: %v", paid, err, 1)
+	}
+	if balance, _ := system.ShowBalance(savings.UUID); balance.Cmp(models.NewMoney(100020, 2)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, "1000.20")
+	}
+	if balance, _ := system.ShowBalance(personal.UUID); balance.Cmp(money(1000)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 1000)
+	}
+	if balance, _ := system.ShowBalance(small.UUID); balance.Cmp(money(10)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 10)
+	}
+	if _, accrual, _ := system.GetInterest(small.UUID); accrual.Accrued.Cmp(models.NewMoney(2, 3)) != 0 {
+		t.Errorf("diff accrued: %v, exp: %v", accrual.Accrued, "0.002")
+	}
+	transactions, err := system.GetTransactions(savings.UUID, models.QueryParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("get transactions error: %v", err)
+	}
+	var payouts int
+	for _, transaction := range transactions {
+		if transaction.SourceUUID == interestAccount(DEFAULT_CURRENCY) && transaction.Status == SENT {
+			payouts++
+		}
+	}
+	if payouts != 1 {
+		t.Errorf("diff payouts: %v, exp: %v", payouts, 1)
+	}
+	if err := system.CheckLedger(savings.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
+}
+
+func TestHolds(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	source, merchant, other := accounts[0], accounts[1], accounts[2]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.AuthorizeHold(Hold{SourceUUID: source.UUID, DestinationUUID: merchant.UUID, Amount: money(101)}); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("hold over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	hold, err := system.AuthorizeHold(Hold{SourceUUID: source.UUID, DestinationIBAN: merchant.IBAN, Amount: money(60)})
+	if err != nil {
+		t.Fatalf("authorize hold error: %v", err)
+	}
+	if hold.Status != AUTHORIZED || hold.DestinationUUID != merchant.UUID {
+		t.Errorf("wrong hold: %v, %v", hold.Status, hold.DestinationUUID)
+	}
+	// the held amount stays on the balance but can't be sent
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 100)
+	}
+	transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: other.UUID, Amount: money(50)})
+	if !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("transfer over available balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	transaction, err = system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: other.UUID, Amount: money(30)})
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	if _, err := system.AuthorizeHold(Hold{SourceUUID: source.UUID, DestinationUUID: merchant.UUID, Amount: money(20)}); err != nil {
+		t.Fatalf("authorize hold error: %v", err)
+	}
+	if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("send over available balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	if _, err := system.CaptureHold(source.UUID, hold.UUID, money(61)); !assert.IsEqual(err, ErrCaptureAmount) {
+		t.Errorf("capture over hold: %v, exp: %v", err, ErrCaptureAmount)
+	}
+	if _, err := system.CaptureHold(other.UUID, hold.UUID, money(10)); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("capture of other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	captured, err := system.CaptureHold(source.UUID, hold.UUID, money(40))
+	if err != nil {
+		t.Fatalf("capture hold error: %v", err)
+	}
+	if captured.Status != SENT || captured.Amount.Cmp(money(40)) != 0 {
+		t.Errorf("wrong capture: %v, %v", captured.Status, captured.Amount)
+	}
+	hold, err = system.VoidHold(source.UUID, hold.UUID)
+	if err != nil {
+		t.Fatalf("void hold error: %v", err)
+	}
+	if hold.Status != VOIDED || hold.Captured.Cmp(money(40)) != 0 {
+		t.Errorf("wrong voided hold: %v, %v", hold.Status, hold.Captured)
+	}
+	if _, err := system.CaptureHold(source.UUID, hold.UUID, money(10)); !assert.IsEqual(err, ErrHoldNotAuthorized) {
+		t.Errorf("capture of voided hold: %v, exp: %v", err, ErrHoldNotAuthorized)
+	}
+	if expired, err := system.ExpireHolds(time.Now().Add(DEFAULT_HOLD_TTL + time.Minute)); err != nil || expired != 1 {
+		t.Errorf("expire holds: %v, %v, exp: %v", expired, err, 1)
+	}
+	account, err := system.GetAccount(source.UUID)
+	if err != nil {
+		t.Fatalf("get account error: %v", err)
+	}
+	if account.Balance.Cmp(money(60)) != 0 || !account.HeldAmount.IsZero() {
+		t.Errorf("diff balance: %v, held: %v, exp: %v, %v", account.Balance, account.HeldAmount, 60, 0)
+	}
+	if balance, _ := system.ShowBalance(merchant.UUID); balance.Cmp(money(40)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 40)
+	}
+	if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); err != nil {
+		t.Errorf("send transaction err: %v", err)
+	}
+	for _, account := range []models.Account{source, merchant} {
+		if err := system.CheckLedger(account.UUID); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+}
+
+func TestCaptureHoldChecks(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.ApprovalThreshold = money(50)
+	_, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, merchant := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: FLAT_FEE, Flat: money(1)}); err != nil {
+		t.Fatalf("set fee schedule error: %v", err)
+	}
+	hold, err := system.AuthorizeHold(Hold{SourceUUID: source.UUID, DestinationUUID: merchant.UUID, Amount: money(80)})
+	if err != nil {
+		t.Fatalf("authorize hold error: %v", err)
+	}
+	captured, err := system.CaptureHold(source.UUID, hold.UUID, money(20))
+	if err != nil {
+		t.Fatalf("capture hold error: %v", err)
+	}
+	if captured.Status != SENT || captured.Fee.Cmp(money(1)) != 0 {
+		t.Errorf("wrong capture: %v, fee: %v, exp: %v, %v", captured.Status, captured.Fee, SENT, 1)
+	}
+	captured, err = system.CaptureHold(source.UUID, hold.UUID, money(60))
+	if err != nil {
+		t.Fatalf("capture hold error: %v", err)
+	}
+	if captured.Status != PENDING_APPROVAL {
+		t.Errorf("wrong capture status: %v, exp: %v", captured.Status, PENDING_APPROVAL)
+	}
+	if _, err := system.ApproveTransaction(captured.UUID, ""); err != nil {
+		t.Fatalf("approve capture error: %v", err)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(18)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 18)
+	}
+	if balance, _ := system.ShowBalance(merchant.UUID); balance.Cmp(money(80)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 80)
+	}
+}
+
+func TestOverdraft(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.OverdraftRate = 3650
+	system.OverdraftFee = money(2)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	newTransaction := func(amount models.Money) (models.Transaction, error) {
+		return system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: amount})
+	}
+	if _, err := newTransaction(money(150)); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("transfer over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	if _, err := system.SetOverdraftLimit(source.UUID, money(-1)); !assert.IsEqual(err, ErrWrongOverdraft) {
+		t.Errorf("negative overdraft: %v, exp: %v", err, ErrWrongOverdraft)
+	}
+	if _, err := system.SetOverdraftLimit(source.UUID, money(100)); err != nil {
+		t.Fatalf("set overdraft error: %v", err)
+	}
+	sendMoney(t, &system, bob, source, destination, money(150))
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(money(-50)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, -50)
+	}
+	if _, err := newTransaction(money(60)); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("transfer over overdraft: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	start := time.Date(2024, time.January, 30, 10, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{start, start.AddDate(0, 0, 1)} {
+		if charged, err := system.AccrueInterest(now); err != nil || charged != 0 {
+			t.Errorf("accrue interest: %v, %v, exp: %v", charged, err, 0)
+		}
+	}
+	// 36.5% a year of 50 is 0.05 a day
+	if charged, err := system.AccrueInterest(start.AddDate(0, 0, 2)); err != nil || charged != 1 {
+		t.Errorf("charge overdraft: %v, %v, exp: %v", charged, err, 1)
+	}
+	if balance, _ := system.ShowBalance(source.UUID); balance.Cmp(models.NewMoney(-5210, 2)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, "-52.10")
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(150)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 150)
+	}
+	if balance, _ := system.LedgerBalance(revenueAccount(DEFAULT_CURRENCY)); balance.Cmp(models.NewMoney(210, 2)) != 0 {
+		t.Errorf("diff revenue: %v, exp: %v", balance, "2.10")
+	}
+	if err := system.CheckLedger(source.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
+}
+
+func TestConcurrentSend(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	for _, account := range accounts {
+		if _, err := system.AddMoney(account.UUID, money(10)); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	// every transaction fits the balance alone, together they overdraw both accounts
+	transactions := make([]models.Transaction, 0)
+	for i := 0; i < 30; i++ {
+		from, to := source, destination
+		if i%3 == 0 {
+			from, to = destination, source
+		}
+		transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: from.UUID, DestinationUUID: to.UUID, Amount: money(1)})
+		if err != nil {
+			t.Fatalf("create new transaction error: %v", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	var mu sync.Mutex
+	sent := make(map[uuid.UUID]int)
+	var wg sync.WaitGroup
+	for _, transaction := range transactions {
+		// every transaction is sent twice at once, only one send may pass
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(transaction models.Transaction) {
+				defer wg.Done()
+				_, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID)
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) && !errors.Is(err, ErrTransactionNotPrepared) {
+					t.Errorf("send transaction err: %v", err)
+				}
+				if err == nil {
+					mu.Lock()
+					sent[transaction.UUID]++
+					mu.Unlock()
+				}
+			}(transaction)
+		}
+	}
+	wg.Wait()
+	expected := map[uuid.UUID]models.Money{source.UUID: money(10), destination.UUID: money(10)}
+	for _, transaction := range transactions {
+		if sent[transaction.UUID] > 1 {
+			t.Errorf("transaction sent %v times", sent[transaction.UUID])
+		}
+		if sent[transaction.UUID] == 1 {
+			expected[transaction.SourceUUID], _ = expected[transaction.SourceUUID].Sub(money(1))
+			expected[transaction.DestinationUUID], _ = expected[transaction.DestinationUUID].Add(money(1))
+		}
+	}
+	for _, account := range accounts {
+		balance, _ := system.ShowBalance(account.UUID)
+		if balance.IsNegative() || balance.Cmp(expected[account.UUID]) != 0 {
+			t.Errorf("diff balance: %v, exp: %v", balance, expected[account.UUID])
+		}
+		if err := system.CheckLedger(account.UUID); err != nil {
+			t.Errorf("check ledger: %v", err)
+		}
+	}
+}
+
+func TestWithdrawal(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	_, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 1)
+	account := accounts[0]
+	if _, err := system.AddMoney(account.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	external, err := IBANGenerator{Country: "DE", BankCode: "37040044"}.Generate()
+	if err != nil {
+		t.Fatalf("generate iban error: %v", err)
+	}
+	rejected, err := IBANGenerator{Country: "FR", BankCode: "30006"}.Generate()
+	if err != nil {
+		t.Fatalf("generate iban error: %v", err)
+	}
+	if _, err := system.Withdraw(account.UUID, external, money(10)); !assert.IsEqual(err, ErrNoPayoutGateway) {
+		t.Errorf("withdraw without gateway: %v, exp: %v", err, ErrNoPayoutGateway)
+	}
+	report, completed := make(chan struct{}), make(chan models.Transaction)
+	system.Payouts = &FakeGateway{
+		Fail: func(payout Payout) string {
+			if payout.IBAN == rejected {
+				return "account closed"
+			}
+			return ""
+		},
+		Complete: func(transactionUUID uuid.UUID, succeeded bool, reason string) (models.Transaction, error) {
+			<-report
+			transaction, err := system.CompletePayout(transactionUUID, succeeded, reason)
+			completed <- transaction
+			return transaction, err
+		},
+	}
+	if _, err := system.Withdraw(account.UUID, account.IBAN, money(10)); !assert.IsEqual(err, ErrInternalIBAN) {
+		t.Errorf("withdraw to internal iban: %v, exp: %v", err, ErrInternalIBAN)
+	}
+	if _, err := system.Withdraw(account.UUID, external, money(101)); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("withdraw over balance: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	tests := []struct {
+		name    string
+		iban    string
+		status  string
+		pending models.Money
+		balance models.Money
+	}{
+		{name: "succeeded", iban: external, status: SENT, pending: money(70), balance: money(70)},
+		{name: "failed", iban: rejected, status: FAILED, pending: money(40), balance: money(70)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			transaction, err := system.Withdraw(account.UUID, tc.iban, money(30))
+			if err != nil {
+				t.Fatalf("withdraw error: %v", err)
+			}
+			// the money leaves the account before the gateway reports the result
+			if balance, _ := system.ShowBalance(account.UUID); balance.Cmp(tc.pending) != 0 {
+				t.Errorf("diff balance: %v, exp: %v", balance, tc.pending)
+			}
+			report <- struct{}{}
+			result := <-completed
+			if result.UUID != transaction.UUID || result.Status != tc.status {
+				t.Errorf("wrong payout: %v, exp: %v", result.Status, tc.status)
+			}
+			if balance, _ := system.ShowBalance(account.UUID); balance.Cmp(tc.balance) != 0 {
+				t.Errorf("diff balance: %v, exp: %v", balance, tc.balance)
+			}
+			if _, err := system.CompletePayout(transaction.UUID, true, ""); !assert.IsEqual(err, ErrPayoutCompleted) {
+				t.Errorf("complete payout twice: %v, exp: %v", err, ErrPayoutCompleted)
+			}
+			if err := system.CheckLedger(account.UUID); err != nil {
+				t.Errorf("check ledger: %v", err)
+			}
+		})
+	}
+	// payouts pay the fee and wait for approval like transfers
+	if _, err := system.SetFeeSchedule(models.FeeSchedule{Kind: FLAT_FEE, Flat: money(1)}); err != nil {
+		t.Fatalf("set fee schedule error: %v", err)
+	}
+	system.ApprovalThreshold = money(20)
+	transaction, err := system.Withdraw(account.UUID, external, money(30))
+	if err != nil {
+		t.Fatalf("withdraw error: %v", err)
+	}
+	if transaction.Status != PENDING_APPROVAL || transaction.Fee.Cmp(money(1)) != 0 {
+		t.Errorf("wrong payout: %v, fee: %v", transaction.Status, transaction.Fee)
+	}
+	if balance, _ := system.ShowBalance(account.UUID); balance.Cmp(money(70)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 70)
+	}
+	if transaction, err = system.ApproveTransaction(transaction.UUID, ""); err != nil || transaction.Status != PROCESSING {
+		t.Fatalf("approve payout: %v, %v", err, transaction.Status)
+	}
+	report <- struct{}{}
+	if result := <-completed; result.Status != SENT {
+		t.Errorf("wrong approved payout: %v, exp: %v", result.Status, SENT)
+	}
+	// the money of the payout has left the system
+	if _, err := system.RefundTransaction(transaction.UUID, models.Money{}); !assert.IsEqual(err, ErrNotRefundable) {
+		t.Errorf("refund payout: %v, exp: %v", err, ErrNotRefundable)
+	}
+	if _, err := system.Withdraw(account.UUID, rejected, money(5)); err != nil {
+		t.Fatalf("withdraw error: %v", err)
+	}
+	if balance, _ := system.ShowBalance(account.UUID); balance.Cmp(money(33)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 33)
+	}
+	report <- struct{}{}
+	<-completed
+	// the failed payout returns its fee
+	if balance, _ := system.ShowBalance(account.UUID); balance.Cmp(money(39)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 39)
+	}
+	// the payouts waiting for the gateway count against the limits
+	if _, err := system.SetLimit(models.Limit{UserUUID: account.UserUUID, Daily: money(70)}); err != nil {
+		t.Fatalf("set limit error: %v", err)
+	}
+	if _, err := system.Withdraw(account.UUID, external, money(6)); err != nil {
+		t.Fatalf("withdraw error: %v", err)
+	}
+	var limitErr *LimitError
+	if _, err := system.Withdraw(account.UUID, external, money(6)); !errors.As(err, &limitErr) || limitErr.Limit != DAILY_LIMIT || limitErr.Remaining.Cmp(money(4)) != 0 {
+		t.Errorf("daily limit with processing payout: %v, exp: %v", err, ErrLimitExceeded)
+	}
+	report <- struct{}{}
+	<-completed
+	if err := system.CheckLedger(account.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
+}
+
+func TestJointAccount(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 1)
+	alice, _ := setupAccounts(t, &system, "alice.white@gmail.com", 0)
+	joint := accounts[0]
+	if _, err := system.InviteMember(bob.UUID, joint.UUID, alice.Email, OWNER); !assert.IsEqual(err, ErrWrongPermission) {
+		t.Errorf("invite as owner: %v, exp: %v", err, ErrWrongPermission)
+	}
+	if _, err := system.InviteMember(bob.UUID, joint.UUID, bob.Email, VIEW); !assert.IsEqual(err, ErrAlreadyMember) {
+		t.Errorf("invite holder: %v, exp: %v", err, ErrAlreadyMember)
+	}
+	member, err := system.InviteMember(bob.UUID, joint.UUID, alice.Email, INITIATE)
+	if err != nil {
+		t.Fatalf("invite member error: %v", err)
+	}
+	if member.Status != INVITED || member.UserUUID != alice.UUID {
+		t.Errorf("wrong member: %v, %v", member.Status, member.UserUUID)
+	}
+	if _, err := system.InviteMember(bob.UUID, joint.UUID, alice.Email, VIEW); !assert.IsEqual(err, ErrAlreadyMember) {
+		t.Errorf("invite twice: %v, exp: %v", err, ErrAlreadyMember)
+	}
+	// the invite gives no access until it is accepted
+	if err := system.CheckAccountExists(alice.UUID, joint.UUID); !assert.IsEqual(err, ErrUnknownAccount) {
+		t.Errorf("access before accept: %v, exp: %v", err, ErrUnknownAccount)
+	}
+	if accs, _ := system.GetAccounts(alice.UUID, models.QueryParams{}); len(accs) != 0 {
+		t.Errorf("diff accounts: %v, exp: %v", len(accs), 0)
+	}
+	if _, err := system.AcceptInvite(alice.UUID, joint.UUID); err != nil {
+		t.Fatalf("accept invite error: %v", err)
+	}
+	if _, err := system.AcceptInvite(alice.UUID, joint.UUID); !assert.IsEqual(err, ErrNotInvited) {
+		t.Errorf("accept twice: %v, exp: %v", err, ErrNotInvited)
+	}
+	if accs, _ := system.GetAccounts(alice.UUID, models.QueryParams{}); len(accs) != 1 || accs[0].UUID != joint.UUID {
+		t.Errorf("diff accounts: %v, exp: %v", accs, joint.UUID)
+	}
+	tests := []struct {
+		permission string
+		bob        error
+		alice      error
+	}{
+		{permission: VIEW},
+		{permission: INITIATE},
+		{permission: SEND, alice: ErrPermissionDenied},
+		{permission: OWNER, alice: ErrPermissionDenied},
+	}
+	for _, tc := range tests {
+		if err := system.CheckAccountPermission(bob.UUID, joint.UUID, tc.permission); !assert.IsEqual(err, tc.bob) {
+			t.Errorf("bob %v: %v, exp: %v", tc.permission, err, tc.bob)
+		}
+		if err := system.CheckAccountPermission(alice.UUID, joint.UUID, tc.permission); !assert.IsEqual(err, tc.alice) {
+			t.Errorf("alice %v: %v, exp: %v", tc.permission, err, tc.alice)
+		}
+	}
+	if _, err := system.SetMemberPermission(joint.UUID, alice.UUID, SEND); err != nil {
+		t.Fatalf("set member permission error: %v", err)
+	}
+	if err := system.CheckAccountPermission(alice.UUID, joint.UUID, SEND); err != nil {
+		t.Errorf("send after permission change: %v", err)
+	}
+	if members, _ := system.GetMembers(joint.UUID); len(members) != 1 || members[0].Permission != SEND || members[0].Status != ACTIVE {
+		t.Errorf("wrong members: %v", members)
+	}
+	if err := system.RemoveMember(joint.UUID, alice.UUID); err != nil {
+		t.Fatalf("remove member error: %v", err)
+	}
+	if err := system.CheckAccountExists(alice.UUID, joint.UUID); !assert.IsEqual(err, ErrUnknownAccount) {
+		t.Errorf("access after remove: %v, exp: %v", err, ErrUnknownAccount)
+	}
+	// the transaction is sent only through its source account, whose
+	// permission is the one checked
+	other, err := system.NewAccount(bob.UUID, DEFAULT_CURRENCY, PERSONAL)
+	if err != nil {
+		t.Fatalf("create new account error: %v", err)
+	}
+	if _, err := system.AddMoney(joint.UUID, money(10)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: joint.UUID, DestinationUUID: other.UUID, Amount: money(10)})
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	if _, err := system.SendTransaction(other.UUID, transaction.UUID); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("send through other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	if _, err := system.CancelTransaction(other.UUID, transaction.UUID); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("cancel through other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+}
+
+func TestJointAccountSchedules(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	alice, _ := setupAccounts(t, &system, "alice.white@gmail.com", 0)
+	joint, other := accounts[0], accounts[1]
+	if _, err := system.AddMoney(joint.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	if _, err := system.InviteMember(bob.UUID, joint.UUID, alice.Email, SEND); err != nil {
+		t.Fatalf("invite member error: %v", err)
+	}
+	if _, err := system.AcceptInvite(alice.UUID, joint.UUID); err != nil {
+		t.Fatalf("accept invite error: %v", err)
+	}
+	start := time.Now().Add(time.Hour)
+	order, err := system.NewStandingOrder(StandingOrder{UserUUID: alice.UUID, SourceUUID: joint.UUID, DestinationUUID: other.UUID, Amount: money(10), Interval: DAILY, StartAt: start})
+	if err != nil {
+		t.Fatalf("create standing order error: %v", err)
+	}
+	scheduled, err := system.NewTransaction(Transaction{UserUUID: alice.UUID, SourceUUID: joint.UUID, DestinationUUID: other.UUID, Amount: money(10), ExecuteAt: start})
+	if err != nil {
+		t.Fatalf("schedule transaction error: %v", err)
+	}
+	own, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: joint.UUID, DestinationUUID: other.UUID, Amount: money(10), ExecuteAt: start})
+	if err != nil {
+		t.Fatalf("schedule transaction error: %v", err)
+	}
+	// the permission of the creator is checked again when they run
+	if _, err := system.SetMemberPermission(joint.UUID, alice.UUID, VIEW); err != nil {
+		t.Fatalf("set member permission error: %v", err)
+	}
+	if sent, _ := system.RunStandingOrders(start); sent != 0 {
+		t.Errorf("sent by downgraded member: %v, exp: %v", sent, 0)
+	}
+	if runs, _ := system.GetStandingOrderRuns(joint.UUID, order.UUID, models.QueryParams{Limit: 30}); len(runs) != 1 || runs[0].Status != FAILED || runs[0].Error != ErrPermissionDenied.Error() {
+		t.Errorf("wrong runs: %v", runs)
+	}
+	if sent, _ := system.ExecuteScheduledTransactions(start); sent != 1 {
+		t.Errorf("sent scheduled: %v, exp: %v", sent, 1)
+	}
+	for _, tc := range []struct {
+		transaction models.Transaction
+		status      string
+	}{{scheduled, FAILED}, {own, SENT}} {
+		if details, _ := system.GetTransaction(joint.UUID, tc.transaction.UUID); details.Transaction.Status != tc.status {
+			t.Errorf("wrong scheduled status: %v, exp: %v", details.Transaction.Status, tc.status)
+		}
+	}
+	if balance, _ := system.ShowBalance(joint.UUID); balance.Cmp(money(90)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 90)
+	}
+}
+
+func TestCloseAccountChecks(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	system.ApprovalThreshold = money(50)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	closing, destination := accounts[0], accounts[1]
+	for _, account := range accounts {
+		if _, err := system.AddMoney(account.UUID, money(100)); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	// the incoming transactions don't block the closure
+	if _, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: destination.UUID, DestinationUUID: closing.UUID, Amount: money(10), ExecuteAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("schedule transaction error: %v", err)
+	}
+	if _, err := system.CloseAccount(closing.UUID, destination.UUID, ""); !assert.IsEqual(err, ErrSweepPending) {
+		t.Errorf("close above approval threshold: %v, exp: %v", err, ErrSweepPending)
+	}
+	if ok, _ := system.IsActiveAccount(closing.UUID); !ok {
+		t.Errorf("account with pending sweep is closed")
+	}
+	pending, err := system.GetTransactionsPendingApproval(models.QueryParams{Limit: 10})
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("get pending transactions: %v, %v, exp: %v", err, len(pending), 1)
+	}
+	if _, err := system.ApproveTransaction(pending[0].UUID, ""); err != nil {
+		t.Fatalf("approve sweep error: %v", err)
+	}
+	account, err := system.CloseAccount(closing.UUID, uuid.Nil, "")
+	if err != nil {
+		t.Fatalf("close account error: %v", err)
+	}
+	if account.Status != CLOSED || !account.Balance.IsZero() {
+		t.Errorf("wrong closed account: %v, %v", account.Status, account.Balance)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(200)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 200)
+	}
+}
+
+func TestCloseAccount(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 3)
+	closing, destination, empty := accounts[0], accounts[1], accounts[2]
+	if _, err := system.AddMoney(closing.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: closing.UUID, DestinationUUID: destination.UUID, Amount: money(10)})
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	if _, err := system.CloseAccount(closing.UUID, destination.UUID, ""); !assert.IsEqual(err, ErrPendingTransactions) {
+		t.Errorf("close with prepared transaction: %v, exp: %v", err, ErrPendingTransactions)
+	}
+	if _, err := system.CancelTransaction(closing.UUID, transaction.UUID); err != nil {
+		t.Fatalf("cancel transaction error: %v", err)
+	}
+	if _, err := system.CloseAccount(closing.UUID, uuid.Nil, ""); !assert.IsEqual(err, ErrSweepDestination) {
+		t.Errorf("close without destination: %v, exp: %v", err, ErrSweepDestination)
+	}
+	account, err := system.CloseAccount(closing.UUID, uuid.Nil, destination.IBAN)
+	if err != nil {
+		t.Fatalf("close account error: %v", err)
+	}
+	if account.Status != CLOSED || !account.Balance.IsZero() {
+		t.Errorf("wrong closed account: %v, %v", account.Status, account.Balance)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(100)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 100)
+	}
+	// the account can't be reopened and money can't move to or from it
+	if _, err := system.CloseAccount(closing.UUID, destination.UUID, ""); !assert.IsEqual(err, ErrAccountClosed) {
+		t.Errorf("close twice: %v, exp: %v", err, ErrAccountClosed)
+	}
+	if err := system.UnblockAccount(closing.UUID); !assert.IsEqual(err, ErrAccountClosed) {
+		t.Errorf("unblock closed account: %v, exp: %v", err, ErrAccountClosed)
+	}
+	if _, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: destination.UUID, DestinationUUID: closing.UUID, Amount: money(10)}); !assert.IsEqual(err, ErrAccountClosed) {
+		t.Errorf("transfer to closed account: %v, exp: %v", err, ErrAccountClosed)
+	}
+	if ok, _ := system.IsActiveAccount(closing.UUID); ok {
+		t.Errorf("closed account is active")
+	}
+	// the history stays readable
+	if transactions, _ := system.GetTransactions(closing.UUID, models.QueryParams{}); len(transactions) != 2 {
+		t.Errorf("diff transactions: %v, exp: %v", len(transactions), 2)
+	}
+	if err := system.CheckLedger(closing.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
+	if _, err := system.CloseAccount(empty.UUID, uuid.Nil, ""); err != nil {
+		t.Errorf("close empty account error: %v", err)
+	}
+}
+
+func TestPockets(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, accounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	source, destination := accounts[0], accounts[1]
+	if _, err := system.AddMoney(source.UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	savings, err := system.CreatePocket(source.UUID, "savings")
+	if err != nil {
+		t.Fatalf("create pocket error: %v", err)
+	}
+	holiday, err := system.CreatePocket(source.UUID, "holiday")
+	if err != nil {
+		t.Fatalf("create pocket error: %v", err)
+	}
+	if _, err := system.MovePocketMoney(source.UUID, uuid.Nil, savings.UUID, money(60)); err != nil {
+		t.Fatalf("move pocket money error: %v", err)
+	}
+	if _, err := system.MovePocketMoney(source.UUID, savings.UUID, holiday.UUID, money(20)); err != nil {
+		t.Fatalf("move pocket money error: %v", err)
+	}
+	cases := []struct {
+		name     string
+		from, to uuid.UUID
+		amount   models.Money
+		expErr   error
+	}{
+		{"main above balance", uuid.Nil, savings.UUID, money(50), ErrInsufficientFunds},
+		{"pocket above balance", holiday.UUID, uuid.Nil, money(30), ErrPocketFunds},
+		{"same pocket", savings.UUID, savings.UUID, money(1), ErrSamePocket},
+		{"zero amount", uuid.Nil, savings.UUID, money(0), ErrWrongAmount},
+		{"other account", uuid.Nil, uuid.New(), money(1), repository.ErrorUnknownPocket},
+	}
+	for _, tc := range cases {
+		if _, err := system.MovePocketMoney(source.UUID, tc.from, tc.to, tc.amount); !assert.IsEqual(err, tc.expErr) {
+			t.Errorf("%v: %v, exp: %v", tc.name, err, tc.expErr)
+		}
+	}
+	// the overdraft can't be set aside
+	if _, err := system.SetOverdraftLimit(source.UUID, money(100)); err != nil {
+		t.Fatalf("set overdraft limit error: %v", err)
+	}
+	if _, err := system.MovePocketMoney(source.UUID, uuid.Nil, savings.UUID, money(50)); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("move overdraft to pocket: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	if _, err := system.SetOverdraftLimit(source.UUID, money(0)); err != nil {
+		t.Fatalf("set overdraft limit error: %v", err)
+	}
+	// the moves stay inside the account
+	account, _ := system.GetAccount(source.UUID)
+	if account.Balance.Cmp(money(100)) != 0 || account.PocketAmount.Cmp(money(60)) != 0 {
+		t.Errorf("diff balance: %v, %v, exp: %v, %v", account.Balance, account.PocketAmount, 100, 60)
+	}
+	// the transactions spend from the main pocket unless the pocket is given
+	if _, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, Amount: money(50)}); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("spend pockets: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	if _, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, PocketUUID: holiday.UUID, Amount: money(30)}); !assert.IsEqual(err, ErrPocketFunds) {
+		t.Errorf("spend above pocket: %v, exp: %v", err, ErrPocketFunds)
+	}
+	if _, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: destination.UUID, DestinationUUID: source.UUID, PocketUUID: holiday.UUID, Amount: money(1)}); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("spend pocket of other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	transaction, err := system.NewTransaction(Transaction{UserUUID: bob.UUID, SourceUUID: source.UUID, DestinationUUID: destination.UUID, PocketUUID: savings.UUID, Amount: money(25)})
+	if err != nil {
+		t.Fatalf("create new transaction error: %v", err)
+	}
+	if _, err := system.SendTransaction(transaction.SourceUUID, transaction.UUID); err != nil {
+		t.Fatalf("send transaction error: %v", err)
+	}
+	if pocket, _ := system.GetPocket(source.UUID, savings.UUID); pocket.Balance.Cmp(money(15)) != 0 {
+		t.Errorf("diff pocket balance: %v, exp: %v", pocket.Balance, 15)
+	}
+	if balance, _ := system.ShowBalance(destination.UUID); balance.Cmp(money(25)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 25)
+	}
+	// the deleted pocket returns its balance to the main pocket
+	if err := system.DeletePocket(source.UUID, holiday.UUID); err != nil {
+		t.Fatalf("delete pocket error: %v", err)
+	}
+	account, _ = system.GetAccount(source.UUID)
+	if account.Balance.Cmp(money(75)) != 0 || account.PocketAmount.Cmp(money(15)) != 0 {
+		t.Errorf("diff balance: %v, %v, exp: %v, %v", account.Balance, account.PocketAmount, 75, 15)
+	}
+	if pockets, _ := system.GetPockets(source.UUID); len(pockets) != 1 {
+		t.Errorf("diff pockets: %v, exp: %v", len(pockets), 1)
+	}
+	if err := system.CheckLedger(source.UUID); err != nil {
+		t.Errorf("check ledger: %v", err)
+	}
+}
+
+func TestPaymentRequests(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, bobAccounts := setupAccounts(t, &system, "bob.black@gmail.com", 1)
+	alice, aliceAccounts := setupAccounts(t, &system, "alice.white@gmail.com", 2)
+	if _, err := system.AddMoney(aliceAccounts[0].UUID, money(100)); err != nil {
+		t.Fatalf("add money error: %v", err)
+	}
+	cases := []struct {
+		name   string
+		r      PaymentRequest
+		expErr error
+	}{
+		{"no payer", PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, Amount: money(10)}, ErrPayerRequired},
+		{"self", PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerEmail: bob.Email, Amount: money(10)}, ErrSelfRequest},
+		{"zero amount", PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerEmail: alice.Email, Amount: money(0)}, ErrWrongAmount},
+	}
+	for _, tc := range cases {
+		if _, err := system.RequestMoney(tc.r); !assert.IsEqual(err, tc.expErr) {
+			t.Errorf("%v: %v, exp: %v", tc.name, err, tc.expErr)
+		}
+	}
+	byEmail, err := system.RequestMoney(PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerEmail: alice.Email, Amount: money(30), Description: "dinner"})
+	if err != nil {
+		t.Fatalf("request money error: %v", err)
+	}
+	byIBAN, err := system.RequestMoney(PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerIBAN: aliceAccounts[1].IBAN, Amount: money(20)})
+	if err != nil {
+		t.Fatalf("request money error: %v", err)
+	}
+	if byIBAN.PayerUUID != alice.UUID || byIBAN.PayerAccountUUID != aliceAccounts[1].UUID {
+		t.Errorf("wrong payer: %v, %v", byIBAN.PayerUUID, byIBAN.PayerAccountUUID)
+	}
+	if incoming, _ := system.GetIncomingPaymentRequests(alice.UUID, models.QueryParams{}); len(incoming) != 2 {
+		t.Errorf("diff incoming requests: %v, exp: %v", len(incoming), 2)
+	}
+	// only the payer pays and the request by email needs the account to pay from
+	if _, err := system.AcceptPaymentRequest(bob.UUID, byEmail.UUID, bobAccounts[0].UUID, uuid.Nil); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("accept by requester: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	if _, err := system.AcceptPaymentRequest(alice.UUID, byEmail.UUID, uuid.Nil, uuid.Nil); !assert.IsEqual(err, ErrSourceRequired) {
+		t.Errorf("accept without account: %v, exp: %v", err, ErrSourceRequired)
+	}
+	if _, err := system.AcceptPaymentRequest(alice.UUID, byIBAN.UUID, aliceAccounts[0].UUID, uuid.Nil); !assert.IsEqual(err, ErrPermissionDenied) {
+		t.Errorf("accept from other account: %v, exp: %v", err, ErrPermissionDenied)
+	}
+	if _, err := system.AcceptPaymentRequest(alice.UUID, byIBAN.UUID, uuid.Nil, uuid.Nil); !assert.IsEqual(err, ErrInsufficientFunds) {
+		t.Errorf("accept without funds: %v, exp: %v", err, ErrInsufficientFunds)
+	}
+	// a blocked account can't pay, the route has no CheckBlockedAccount
+	if err := system.BlockAccount(aliceAccounts[0].UUID); err != nil {
+		t.Fatalf("block account error: %v", err)
+	}
+	if _, err := system.AcceptPaymentRequest(alice.UUID, byEmail.UUID, aliceAccounts[0].UUID, uuid.Nil); !assert.IsEqual(err, ErrAccountBlocked) {
+		t.Errorf("accept from blocked account: %v, exp: %v", err, ErrAccountBlocked)
+	}
+	if err := system.UnblockAccount(aliceAccounts[0].UUID); err != nil {
+		t.Fatalf("unblock account error: %v", err)
+	}
+	transaction, err := system.AcceptPaymentRequest(alice.UUID, byEmail.UUID, aliceAccounts[0].UUID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("accept payment request error: %v", err)
+	}
+	if transaction.Status != SENT || transaction.DestinationUUID != bobAccounts[0].UUID {
+		t.Errorf("wrong transaction: %v, %v", transaction.Status, transaction.DestinationUUID)
+	}
+	if balance, _ := system.ShowBalance(bobAccounts[0].UUID); balance.Cmp(money(30)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 30)
+	}
+	request, _ := system.GetPaymentRequest(bobAccounts[0].UUID, byEmail.UUID)
+	if request.Status != ACCEPTED || request.TransactionUUID != transaction.UUID {
+		t.Errorf("wrong accepted request: %v, %v", request.Status, request.TransactionUUID)
+	}
+	if _, err := system.AcceptPaymentRequest(alice.UUID, byEmail.UUID, aliceAccounts[0].UUID, uuid.Nil); !assert.IsEqual(err, ErrRequestNotOpen) {
+		t.Errorf("accept twice: %v, exp: %v", err, ErrRequestNotOpen)
+	}
+	// reminders are limited to one per interval
+	now := time.Now()
+	if request, err = system.RemindPaymentRequest(bobAccounts[0].UUID, byIBAN.UUID, now); err != nil || request.Reminders != 1 {
+		t.Errorf("remind error: %v, reminders: %v", err, request.Reminders)
+	}
+	if _, err := system.RemindPaymentRequest(bobAccounts[0].UUID, byIBAN.UUID, now.Add(time.Hour)); !assert.IsEqual(err, ErrRemindTooSoon) {
+		t.Errorf("remind too soon: %v, exp: %v", err, ErrRemindTooSoon)
+	}
+	if request, err = system.RemindPaymentRequest(bobAccounts[0].UUID, byIBAN.UUID, now.Add(REMINDER_INTERVAL)); err != nil || request.Reminders != 2 {
+		t.Errorf("remind error: %v, reminders: %v", err, request.Reminders)
+	}
+	if request, err = system.DeclinePaymentRequest(alice.UUID, byIBAN.UUID, "not mine"); err != nil || request.Status != DECLINED {
+		t.Errorf("decline error: %v, status: %v", err, request.Status)
+	}
+	if _, err := system.CancelPaymentRequest(bobAccounts[0].UUID, byIBAN.UUID); !assert.IsEqual(err, ErrRequestNotOpen) {
+		t.Errorf("cancel declined: %v, exp: %v", err, ErrRequestNotOpen)
+	}
+	expiring, err := system.RequestMoney(PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerEmail: alice.Email, Amount: money(5), TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("request money error: %v", err)
+	}
+	if expired, err := system.ExpirePaymentRequests(now.Add(2 * time.Hour)); err != nil || expired != 1 {
+		t.Errorf("expire error: %v, expired: %v, exp: %v", err, expired, 1)
+	}
+	if request, _ = system.GetPaymentRequest(bobAccounts[0].UUID, expiring.UUID); request.Status != EXPIRED {
+		t.Errorf("diff status: %v, exp: %v", request.Status, EXPIRED)
+	}
+	// a payment waiting for approval leaves the request pending until it is reviewed
+	system.ApprovalThreshold = money(5)
+	reviewed, err := system.RequestMoney(PaymentRequest{RequesterUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, PayerEmail: alice.Email, Amount: money(10)})
+	if err != nil {
+		t.Fatalf("request money error: %v", err)
+	}
+	for _, exp := range []string{OPEN, ACCEPTED} {
+		transaction, err := system.AcceptPaymentRequest(alice.UUID, reviewed.UUID, aliceAccounts[0].UUID, uuid.Nil)
+		if err != nil {
+			t.Fatalf("accept payment request error: %v", err)
+		}
+		if request, _ = system.GetPaymentRequest(bobAccounts[0].UUID, reviewed.UUID); request.Status != PENDING_APPROVAL {
+			t.Errorf("diff status: %v, exp: %v", request.Status, PENDING_APPROVAL)
+		}
+		if exp == OPEN {
+			_, err = system.RejectTransaction(transaction.UUID, "suspicious")
+		} else {
+			_, err = system.ApproveTransaction(transaction.UUID, "")
+		}
+		if err != nil {
+			t.Fatalf("review transaction error: %v", err)
+		}
+		if request, _ = system.GetPaymentRequest(bobAccounts[0].UUID, reviewed.UUID); request.Status != exp {
+			t.Errorf("diff reviewed status: %v, exp: %v", request.Status, exp)
+		}
+	}
+	if request.PayerAccountUUID != aliceAccounts[0].UUID {
+		t.Errorf("diff payer account: %v, exp: %v", request.PayerAccountUUID, aliceAccounts[0].UUID)
+	}
+}
+
+func TestSplitTransaction(t *testing.T) {
+	testRepo := repository.NewTestRepo()
+	system := NewPaymentSystem(&testRepo)
+	bob, bobAccounts := setupAccounts(t, &system, "bob.black@gmail.com", 2)
+	alice, aliceAccounts := setupAccounts(t, &system, "alice.white@gmail.com", 1)
+	carol, _ := setupAccounts(t, &system, "carol.green@gmail.com", 0)
+	for _, account := range []models.Account{bobAccounts[0], aliceAccounts[0]} {
+		if _, err := system.AddMoney(account.UUID, money(110)); err != nil {
+			t.Fatalf("add money error: %v", err)
+		}
+	}
+	dinner := sendMoney(t, &system, bob, bobAccounts[0], bobAccounts[1], money(100))
+	taxi := sendMoney(t, &system, bob, bobAccounts[0], bobAccounts[1], money(10))
+	shares := []SplitShare{{PayerEmail: alice.Email}, {PayerEmail: carol.Email, Weight: 2}}
+	cases := []struct {
+		name   string
+		s      Split
+		expErr error
+	}{
+		{"no shares", Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: PROPORTIONAL}, ErrNoShares},
+		{"unknown mode", Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: "even", Shares: shares}, ErrUnknownSplitMode},
+		{"incoming transaction", Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[1].UUID, TransactionUUID: dinner.UUID, Mode: PROPORTIONAL, Shares: shares}, ErrNotSplittable},
+		{"above amount", Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: CUSTOM, Shares: []SplitShare{{PayerEmail: alice.Email, Amount: money(60)}, {PayerEmail: carol.Email, Amount: money(50)}}}, ErrSplitAmount},
+		{"self share", Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: PROPORTIONAL, Shares: []SplitShare{{PayerEmail: bob.Email}}}, ErrSelfRequest},
+	}
+	for _, tc := range cases {
+		if _, err := system.SplitTransaction(tc.s); !assert.IsEqual(err, tc.expErr) {
+			t.Errorf("%v: %v, exp: %v", tc.name, err, tc.expErr)
+		}
+	}
+	// the remainder of the rounding stays with the own share
+	summary, err := system.SplitTransaction(Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: taxi.UUID, Mode: PROPORTIONAL, OwnWeight: 1, Shares: []SplitShare{{PayerEmail: alice.Email}, {PayerEmail: carol.Email}}})
+	if err != nil {
+		t.Fatalf("split transaction error: %v", err)
+	}
+	if summary.Split.OwnShare.Cmp(models.NewMoney(334, 2)) != 0 || summary.Requested.Cmp(models.NewMoney(666, 2)) != 0 {
+		t.Errorf("diff shares: %v, %v, exp: %v, %v", summary.Split.OwnShare, summary.Requested, "3.34", "6.66")
+	}
+	taxiSplit := summary
+	summary, err = system.SplitTransaction(Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: PROPORTIONAL, OwnWeight: 1, Shares: shares})
+	if err != nil {
+		t.Fatalf("split transaction error: %v", err)
+	}
+	if summary.Split.OwnShare.Cmp(money(25)) != 0 || summary.Outstanding.Cmp(money(75)) != 0 || len(summary.Shares) != 2 {
+		t.Errorf("diff split: %v, %v, %v", summary.Split.OwnShare, summary.Outstanding, len(summary.Shares))
+	}
+	if _, err := system.SplitTransaction(Split{UserUUID: bob.UUID, AccountUUID: bobAccounts[0].UUID, TransactionUUID: dinner.UUID, Mode: PROPORTIONAL, Shares: shares}); !assert.IsEqual(err, ErrAlreadySplit) {
+		t.Errorf("split twice: %v, exp: %v", err, ErrAlreadySplit)
+	}
+	// the shares are settled by accepting their requests
+	for _, share := range summary.Shares {
+		switch share.PayerUUID {
+		case alice.UUID:
+			if share.Amount.Cmp(money(25)) != 0 {
+				t.Errorf("diff share: %v, exp: %v", share.Amount, 25)
+			}
+			if _, err := system.AcceptPaymentRequest(alice.UUID, share.UUID, aliceAccounts[0].UUID, uuid.Nil); err != nil {
+				t.Fatalf("accept payment request error: %v", err)
+			}
+		case carol.UUID:
+			if share.Amount.Cmp(money(50)) != 0 {
+				t.Errorf("diff share: %v, exp: %v", share.Amount, 50)
+			}
+			if _, err := system.DeclinePaymentRequest(carol.UUID, share.UUID, ""); err != nil {
+				t.Fatalf("decline payment request error: %v", err)
+			}
+		}
+	}
+	summary, err = system.GetSplit(bobAccounts[0].UUID, summary.Split.UUID)
+	if err != nil {
+		t.Fatalf("get split error: %v", err)
+	}
+	if summary.Settled.Cmp(money(25)) != 0 || !summary.Outstanding.IsZero() || summary.SettledShares != 1 || summary.Complete {
+		t.Errorf("diff summary: %v, %v, %v, %v", summary.Settled, summary.Outstanding, summary.SettledShares, summary.Complete)
+	}
+	if balance, _ := system.ShowBalance(bobAccounts[0].UUID); balance.Cmp(money(25)) != 0 {
+		t.Errorf("diff balance: %v, exp: %v", balance, 25)
+	}
+	if splits, _ := system.GetSplits(bobAccounts[0].UUID, models.QueryParams{}); len(splits) != 2 {
+		t.Errorf("diff splits: %v, exp: %v", len(splits), 2)
+	}
+	// a share paid by a transaction waiting for approval isn't settled yet
+	system.ApprovalThreshold = money(3)
+	var paid models.Transaction
+	for _, share := range taxiSplit.Shares {
+		if share.PayerUUID == alice.UUID {
+			paid, err = system.AcceptPaymentRequest(alice.UUID, share.UUID, aliceAccounts[0].UUID, uuid.Nil)
+			if err != nil {
+				t.Fatalf("accept payment request error: %v", err)
+			}
+		}
+	}
+	summary, _ = system.GetSplit(bobAccounts[0].UUID, taxiSplit.Split.UUID)
+	if !summary.Settled.IsZero() || summary.Outstanding.Cmp(models.NewMoney(666, 2)) != 0 {
+		t.Errorf("diff pending summary: %v, %v, exp: %v, %v", summary.Settled, summary.Outstanding, 0, "6.66")
+	}
+	if _, err := system.ApproveTransaction(paid.UUID, ""); err != nil {
+		t.Fatalf("approve transaction error: %v", err)
+	}
+	summary, _ = system.GetSplit(bobAccounts[0].UUID, taxiSplit.Split.UUID)
+	if summary.Settled.Cmp(models.NewMoney(333, 2)) != 0 || summary.SettledShares != 1 {
+		t.Errorf("diff approved summary: %v, %v, exp: %v, %v", summary.Settled, summary.SettledShares, "3.33", 1)
+	}
+}
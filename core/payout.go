@@ -0,0 +1,252 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PAYOUT          = "payout"
+	PAYOUT_REVERSAL = "payout-reversal"
+
+	DEFAULT_PAYOUT_DELAY = 5 * time.Second
+)
+
+var (
+	ErrNoPayoutGateway = errors.New("payouts are not configured")
+	ErrInternalIBAN    = errors.New("iban belongs to an account of the system, use a transfer")
+	ErrNotPayout       = errors.New("transaction is not a payout")
+	ErrPayoutCompleted = errors.New("payout is already completed")
+)
+
+// Payout is the money which leaves the system to an external IBAN.
+type Payout struct {
+	TransactionUUID uuid.UUID
+	IBAN            string
+	Amount          models.Money
+	Currency        string
+}
+
+// PayoutGateway sends the payouts out of the system. Payout only submits the
+// payout, the gateway reports its result later through CompletePayout.
+type PayoutGateway interface {
+	Payout(payout Payout) error
+}
+
+// FakeGateway is the local PayoutGateway which reports the result of every
+// payout to Complete after the delay. Fail returns the reason the payout
+// fails with, the payouts succeed when it is nil or returns "".
+type FakeGateway struct {
+	Delay    time.Duration
+	Fail     func(payout Payout) string
+	Complete func(transactionUUID uuid.UUID, succeeded bool, reason string) (models.Transaction, error)
+}
+
+func (g *FakeGateway) Payout(payout Payout) error {
+	reason := ""
+	if g.Fail != nil {
+		reason = g.Fail(payout)
+	}
+	time.AfterFunc(g.Delay, func() {
+		if _, err := g.Complete(payout.TransactionUUID, reason == "", reason); err != nil {
+			log.Printf("can't complete payout %v, err %v", payout.TransactionUUID, err.Error())
+		}
+	})
+	return nil
+}
+
+// payoutAccount is the system account which holds the money of the payouts
+// in the currency until the gateway reports their result.
+func payoutAccount(currency string) uuid.UUID {
+	return systemAccount("payout:" + currency)
+}
+
+// Withdraw debits the account with the amount and its fee and submits the
+// payout of the amount to the external IBAN. The transaction stays processing
+// until the gateway completes the payout; a payout above the approval
+// threshold waits for approval before it is debited and submitted.
+func (p *PaymentSystem) Withdraw(accountUUID uuid.UUID, iban string, amount models.Money) (models.Transaction, error) {
+	if p.Payouts == nil {
+		return models.Transaction{}, ErrNoPayoutGateway
+	}
+	iban, err := ValidateIBAN(iban)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if _, err := p.Repo.GetAccountByIBAN(iban); err == nil {
+		return models.Transaction{}, ErrInternalIBAN
+	} else if !errors.Is(err, repository.ErrorUnknownAccount) {
+		return models.Transaction{}, err
+	}
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	amount, err = amount.Rescale(models.CurrencyExponent(account.Currency))
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if !amount.IsPositive() {
+		return models.Transaction{}, ErrWrongAmount
+	}
+	transaction := models.Transaction{
+		Status:              PREPARED,
+		SourceUUID:          account.UUID,
+		DestinationUUID:     payoutAccount(account.Currency),
+		SourceIBAN:          account.IBAN,
+		DestinationIBAN:     iban,
+		Amount:              amount,
+		Currency:            account.Currency,
+		DestinationAmount:   amount,
+		DestinationCurrency: account.Currency,
+		Rate:                1,
+	}
+	transaction.Fee, err = fee(p.Repo, &transaction)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transaction.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, transaction.SourceUUID)
+			if err != nil {
+				return err
+			}
+			err = createTransaction(repo, transaction)
+			if err != nil {
+				return err
+			}
+			// the local copy keeps its status for the retries of the repository transaction
+			created, err := repo.GetTransactionByUUID(transaction.UUID)
+			if err != nil {
+				return err
+			}
+			approval, err := p.admit(repo, created)
+			if err != nil {
+				return err
+			}
+			if approval {
+				return updateStatus(repo, created, PENDING_APPROVAL, "")
+			}
+			return startPayout(repo, created, "")
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transactionModel, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if transactionModel.Status != PROCESSING {
+		return *transactionModel, nil
+	}
+	return p.submitPayout(*transactionModel)
+}
+
+func isPayout(transaction *models.Transaction) bool {
+	return transaction.DestinationUUID == payoutAccount(transaction.Currency)
+}
+
+// startPayout debits the account with the amount of the payout and its fee,
+// the payout is processing until the gateway completes it.
+func startPayout(repo repository.Repository, transaction *models.Transaction, reason string) error {
+	err := updateStatus(repo, transaction, PROCESSING, reason)
+	if err != nil {
+		return err
+	}
+	journalUUID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	err = move(repo, journalUUID, transaction.UUID, PAYOUT, transaction.SourceUUID, transaction.DestinationUUID, transaction.Amount)
+	if err != nil {
+		return err
+	}
+	if transaction.Fee.IsPositive() {
+		return move(repo, journalUUID, transaction.UUID, FEE, transaction.SourceUUID, revenueAccount(transaction.Currency), transaction.Fee)
+	}
+	return nil
+}
+
+// submitPayout hands the processing payout to the gateway, the payout which
+// can't be submitted fails at once.
+func (p *PaymentSystem) submitPayout(transaction models.Transaction) (models.Transaction, error) {
+	err := p.Payouts.Payout(Payout{TransactionUUID: transaction.UUID, IBAN: transaction.DestinationIBAN, Amount: transaction.Amount, Currency: transaction.Currency})
+	if err != nil {
+		if _, failErr := p.CompletePayout(transaction.UUID, false, err.Error()); failErr != nil {
+			return models.Transaction{}, failErr
+		}
+		return models.Transaction{}, err
+	}
+	transactionModel, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *transactionModel, nil
+}
+
+// CompletePayout records the result of the payout reported by the gateway. A
+// succeeded payout is sent out of the system, a failed one returns the money
+// and the fee to the account.
+func (p *PaymentSystem) CompletePayout(transactionUUID uuid.UUID, succeeded bool, reason string) (models.Transaction, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			transaction, err := repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			if transaction.DestinationUUID != payoutAccount(transaction.Currency) {
+				return ErrNotPayout
+			}
+			err = lockAccounts(repo, transaction.SourceUUID)
+			if err != nil {
+				return err
+			}
+			// a concurrent callback may have completed the payout while the lock was awaited
+			transaction, err = repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			if transaction.Status != PROCESSING {
+				return ErrPayoutCompleted
+			}
+			journalUUID, err := uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			if succeeded {
+				err = move(repo, journalUUID, transaction.UUID, PAYOUT, transaction.DestinationUUID, EXTERNAL_ACCOUNT, transaction.Amount)
+				if err != nil {
+					return err
+				}
+				return updateStatus(repo, transaction, SENT, reason)
+			}
+			err = move(repo, journalUUID, transaction.UUID, PAYOUT_REVERSAL, transaction.DestinationUUID, transaction.SourceUUID, transaction.Amount)
+			if err != nil {
+				return err
+			}
+			if transaction.Fee.IsPositive() {
+				err = move(repo, journalUUID, transaction.UUID, PAYOUT_REVERSAL, revenueAccount(transaction.Currency), transaction.SourceUUID, transaction.Fee)
+				if err != nil {
+					return err
+				}
+			}
+			return updateStatus(repo, transaction, FAILED, reason)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transaction, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *transaction, nil
+}
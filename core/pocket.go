@@ -0,0 +1,197 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPocketFunds = errors.New("insufficient funds in the pocket")
+	ErrSamePocket  = errors.New("source pocket equals destination pocket")
+)
+
+// CreatePocket adds an empty pocket to the account.
+func (p *PaymentSystem) CreatePocket(accountUUID uuid.UUID, name string) (models.Pocket, error) {
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return models.Pocket{}, err
+	}
+	if account.Status == CLOSED {
+		return models.Pocket{}, ErrAccountClosed
+	}
+	pocket := models.Pocket{
+		AccountUUID: accountUUID,
+		Name:        name,
+		Balance:     models.NewMoney(0, models.CurrencyExponent(account.Currency)),
+		Currency:    account.Currency,
+	}
+	pocket.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.Pocket{}, err
+	}
+	err = p.Repo.CreatePocket(pocket)
+	if err != nil {
+		return models.Pocket{}, err
+	}
+	return p.GetPocket(accountUUID, pocket.UUID)
+}
+
+func (p *PaymentSystem) GetPockets(accountUUID uuid.UUID) ([]models.Pocket, error) {
+	return p.Repo.GetPocketsForAccount(accountUUID)
+}
+
+func (p *PaymentSystem) GetPocket(accountUUID, pocketUUID uuid.UUID) (models.Pocket, error) {
+	pocket, err := pocketOf(p.Repo, accountUUID, pocketUUID)
+	if err != nil {
+		return models.Pocket{}, err
+	}
+	return *pocket, nil
+}
+
+func pocketOf(repo repository.Repository, accountUUID, pocketUUID uuid.UUID) (*models.Pocket, error) {
+	pocket, err := repo.GetPocketByUUID(pocketUUID)
+	if err != nil {
+		return nil, err
+	}
+	if pocket.AccountUUID != accountUUID {
+		return nil, ErrPermissionDenied
+	}
+	return pocket, nil
+}
+
+// MovePocketMoney moves the amount between the pockets of the account, a nil
+// pocket is the main one. The move is internal, so the balance of the account
+// and the ledger don't change.
+func (p *PaymentSystem) MovePocketMoney(accountUUID, fromUUID, toUUID uuid.UUID, amount models.Money) ([]models.Pocket, error) {
+	if fromUUID == toUUID {
+		return []models.Pocket{}, ErrSamePocket
+	}
+	account, err := p.Repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return []models.Pocket{}, err
+	}
+	amount, err = amount.Rescale(models.CurrencyExponent(account.Currency))
+	if err != nil {
+		return []models.Pocket{}, err
+	}
+	if !amount.IsPositive() {
+		return []models.Pocket{}, ErrWrongAmount
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			if fromUUID == uuid.Nil {
+				err = checkOwnAmount(repo, accountUUID, amount)
+			} else {
+				err = spendPocket(repo, accountUUID, fromUUID, amount)
+			}
+			if err != nil {
+				return err
+			}
+			if toUUID == uuid.Nil {
+				return nil
+			}
+			if _, err := pocketOf(repo, accountUUID, toUUID); err != nil {
+				return err
+			}
+			err = repo.IncPocketBalance(toUUID, amount)
+			if err != nil {
+				return err
+			}
+			return repo.IncPocketAmount(accountUUID, amount)
+		})
+	if err != nil {
+		return []models.Pocket{}, err
+	}
+	return p.GetPockets(accountUUID)
+}
+
+// checkOwnAmount returns ErrInsufficientFunds when the available balance of
+// the account without the overdraft is less than the amount, so a pocket
+// can't be funded by the overdraft.
+func checkOwnAmount(repo repository.Repository, accountUUID uuid.UUID, amount models.Money) error {
+	account, err := repo.GetAccountByUUID(accountUUID)
+	if err != nil {
+		return err
+	}
+	balance, err := available(account)
+	if err != nil {
+		return err
+	}
+	balance, err = balance.Sub(account.OverdraftLimit)
+	if err != nil {
+		return err
+	}
+	if amount.Cmp(balance) > 0 {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// DeletePocket removes the pocket, its balance goes back to the main pocket.
+func (p *PaymentSystem) DeletePocket(accountUUID, pocketUUID uuid.UUID) error {
+	return p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			err := lockAccounts(repo, accountUUID)
+			if err != nil {
+				return err
+			}
+			return deletePocket(repo, accountUUID, pocketUUID)
+		})
+}
+
+func deletePocket(repo repository.Repository, accountUUID, pocketUUID uuid.UUID) error {
+	pocket, err := pocketOf(repo, accountUUID, pocketUUID)
+	if err != nil {
+		return err
+	}
+	if pocket.Balance.IsPositive() {
+		err = repo.DecPocketAmount(accountUUID, pocket.Balance)
+		if err != nil {
+			return err
+		}
+	}
+	return repo.DeletePocket(pocketUUID)
+}
+
+// spendPocket takes the amount out of the pocket of the account, so the
+// amount leaves the money set aside.
+func spendPocket(repo repository.Repository, accountUUID, pocketUUID uuid.UUID, amount models.Money) error {
+	err := checkPocket(repo, accountUUID, pocketUUID, amount)
+	if err != nil {
+		return err
+	}
+	err = repo.DecPocketBalance(pocketUUID, amount)
+	if err != nil {
+		return err
+	}
+	return repo.DecPocketAmount(accountUUID, amount)
+}
+
+// checkPocket returns ErrPocketFunds when the balance of the pocket is less
+// than the amount.
+func checkPocket(repo repository.Repository, accountUUID, pocketUUID uuid.UUID, amount models.Money) error {
+	pocket, err := pocketOf(repo, accountUUID, pocketUUID)
+	if err != nil {
+		return err
+	}
+	if amount.Cmp(pocket.Balance) > 0 {
+		return ErrPocketFunds
+	}
+	return nil
+}
+
+// checkFunds checks the amount against the pocket the transaction is spent
+// from, the main pocket when it isn't set.
+func checkFunds(repo repository.Repository, transaction *models.Transaction, amount models.Money) error {
+	if transaction.PocketUUID == uuid.Nil {
+		return checkAmount(repo, transaction.SourceUUID, amount)
+	}
+	return checkPocket(repo, transaction.SourceUUID, transaction.PocketUUID, amount)
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	REVERSED = "reversed"
+
+	REFUND = "refund"
+)
+
+var (
+	ErrNotRefundable = errors.New("transaction can't be refunded")
+	ErrRefundAmount  = errors.New("refund amount exceeds refundable amount")
+)
+
+// RefundTransaction moves the amount back from the destination to the source
+// of the sent transaction. The amount is in the currency of the destination.
+// Zero amount refunds everything that is left.
+func (p *PaymentSystem) RefundTransaction(transactionUUID uuid.UUID, amount models.Money) (models.Transaction, error) {
+	var refund models.Transaction
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			original, err := repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			err = lockAccounts(repo, original.SourceUUID, original.DestinationUUID)
+			if err != nil {
+				return err
+			}
+			original, err = repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			if original.Status != SENT || original.OriginalUUID != uuid.Nil {
+				return ErrNotRefundable
+			}
+			// the money of a payout has left the system and the system
+			// accounts only book fees, interest and the like
+			if isPayout(original) || isSystemAccount(original.SourceUUID) || isSystemAccount(original.DestinationUUID) {
+				return ErrNotRefundable
+			}
+			refunds, err := repo.GetRefunds(original.UUID)
+			if err != nil {
+				return err
+			}
+			refundable := received(original)
+			for _, r := range refunds {
+				refundable, err = refundable.Sub(r.Amount)
+				if err != nil {
+					return err
+				}
+			}
+			// the parameter stays unchanged for the retries of the repository transaction
+			refunded := amount
+			if refunded.IsZero() {
+				refunded = refundable
+			}
+			refunded, err = refunded.Rescale(models.CurrencyExponent(original.DestinationCurrency))
+			if err != nil {
+				return err
+			}
+			if !refunded.IsPositive() || refunded.Cmp(refundable) > 0 {
+				return ErrRefundAmount
+			}
+			err = checkAmount(repo, original.DestinationUUID, refunded)
+			if err != nil {
+				return err
+			}
+			refund = models.Transaction{
+				Status:              SENT,
+				SourceUUID:          original.DestinationUUID,
+				DestinationUUID:     original.SourceUUID,
+				SourceIBAN:          original.DestinationIBAN,
+				DestinationIBAN:     original.SourceIBAN,
+				Amount:              refunded,
+				Currency:            original.DestinationCurrency,
+				DestinationAmount:   refunded,
+				DestinationCurrency: original.Currency,
+				Rate:                1,
+				OriginalUUID:        original.UUID,
+			}
+			if original.Currency != original.DestinationCurrency {
+				// the sender gets back the share of the original amount at the original rate
+				share := new(big.Rat).SetFrac(big.NewInt(refunded.Minor), big.NewInt(received(original).Minor))
+				refund.DestinationAmount, err = scale(original.Amount, share, original.Amount.Exponent)
+				if err != nil {
+					return err
+				}
+				refund.Rate = 1 / original.Rate
+			}
+			refund.UUID, err = uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			err = createTransaction(repo, refund)
+			if err != nil {
+				return err
+			}
+			journalUUID, err := uuid.NewRandom()
+			if err != nil {
+				return err
+			}
+			err = transfer(repo, journalUUID, &refund, REFUND)
+			if err != nil {
+				return err
+			}
+			if refunded.Cmp(refundable) == 0 {
+				return updateStatus(repo, original, REVERSED, "refunded")
+			}
+			return nil
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tr, err := p.Repo.GetTransactionByUUID(refund.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *tr, nil
+}
+
+// RefundReceivedTransaction refunds the transaction on behalf of its recipient.
+func (p *PaymentSystem) RefundReceivedTransaction(accountUUID, transactionUUID uuid.UUID, amount models.Money) (models.Transaction, error) {
+	transaction, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if transaction.DestinationUUID != accountUUID {
+		return models.Transaction{}, ErrPermissionDenied
+	}
+	return p.RefundTransaction(transactionUUID, amount)
+}
+
+func (p *PaymentSystem) GetRefunds(transactionUUID uuid.UUID) ([]models.Transaction, error) {
+	return p.Repo.GetRefunds(transactionUUID)
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SCHEDULED = "scheduled"
+
+	MAX_SEND_ATTEMPTS = 3
+	RETRY_DELAY       = time.Hour
+)
+
+var ErrExecuteAt = errors.New("execution time has to be in the future")
+
+// ExecuteScheduledTransactions sends the scheduled transactions which are due.
+// A transaction which can't be sent because of insufficient funds or an
+// exceeded limit is retried after RETRY_DELAY and fails after
+// MAX_SEND_ATTEMPTS attempts.
+func (p *PaymentSystem) ExecuteScheduledTransactions(now time.Time) (int, error) {
+	transactions, err := p.Repo.GetDueTransactions(now)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, tr := range transactions {
+		active, err := p.IsActiveAccount(tr.SourceUUID)
+		if err != nil || !active {
+			if err := p.failTransaction(tr.UUID, SCHEDULED, "source account is not active"); err != nil {
+				log.Printf("can't fail scheduled transaction %v, err %v", tr.UUID, err.Error())
+			}
+			continue
+		}
+		// the creator may have been removed from the account or downgraded
+		// since, the transactions scheduled before the creator was kept have
+		// none and run as the holder's
+		if tr.UserUUID != uuid.Nil {
+			if err := p.CheckAccountPermission(tr.UserUUID, tr.SourceUUID, SEND); err != nil {
+				if failErr := p.failTransaction(tr.UUID, SCHEDULED, err.Error()); failErr != nil {
+					log.Printf("can't fail scheduled transaction %v, err %v", tr.UUID, failErr.Error())
+				}
+				continue
+			}
+		}
+		transaction, err := p.send(tr.UUID, SCHEDULED)
+		switch {
+		case err == nil:
+			if transaction.Status == SENT {
+				sent++
+			}
+		case (errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrLimitExceeded)) && tr.Attempts+1 < MAX_SEND_ATTEMPTS:
+			if err := p.Repo.UpdateScheduleTransaction(tr.UUID, tr.Attempts+1, now.Add(RETRY_DELAY)); err != nil {
+				log.Printf("can't reschedule transaction %v, err %v", tr.UUID, err.Error())
+			}
+		default:
+			if err := p.Repo.UpdateScheduleTransaction(tr.UUID, tr.Attempts+1, now); err != nil {
+				log.Printf("can't update scheduled transaction %v, err %v", tr.UUID, err.Error())
+			}
+			if failErr := p.failTransaction(tr.UUID, SCHEDULED, err.Error()); failErr != nil {
+				log.Printf("can't fail scheduled transaction %v, err %v", tr.UUID, failErr.Error())
+			}
+		}
+	}
+	return sent, nil
+}
+
+// failTransaction moves the transaction in the given status to the failed one.
+func (p *PaymentSystem) failTransaction(transactionUUID uuid.UUID, status, reason string) error {
+	return p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			transaction, err := repo.GetTransactionByUUID(transactionUUID)
+			if err != nil {
+				return err
+			}
+			if transaction.Status != status {
+				return ErrStatusTransition
+			}
+			return failIn(repo, transaction, reason)
+		})
+}
+
+// failIn is failTransaction within the repository transaction.
+func failIn(repo repository.Repository, transaction *models.Transaction, reason string) error {
+	return updateStatus(repo, transaction, FAILED, reason)
+}
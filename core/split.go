@@ -0,0 +1,270 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PROPORTIONAL splits the amount by the weights of the shares, CUSTOM
+	// requests the amount of every share.
+	PROPORTIONAL = "proportional"
+	CUSTOM       = "custom"
+)
+
+var (
+	ErrUnknownSplitMode = errors.New("split mode has to be proportional or custom")
+	ErrNotSplittable    = errors.New("only sent transactions from the account can be split")
+	ErrAlreadySplit     = errors.New("transaction is already split")
+	ErrNoShares         = errors.New("split needs at least one share")
+	ErrSplitAmount      = errors.New("shares exceed the amount of the transaction")
+)
+
+// SplitShare is the part of the split one payer is requested to pay; Weight
+// is used by PROPORTIONAL splits, where zero counts as one, and Amount by
+// CUSTOM ones.
+type SplitShare struct {
+	PayerEmail string
+	PayerIBAN  string
+	Weight     uint
+	Amount     models.Money
+}
+
+type Split struct {
+	UserUUID        uuid.UUID
+	AccountUUID     uuid.UUID
+	TransactionUUID uuid.UUID
+	Mode            string
+	Shares          []SplitShare
+	// OwnWeight is the weight of the share the user keeps in a PROPORTIONAL
+	// split, zero leaves the whole amount to the shares.
+	OwnWeight   uint
+	Description string
+	// TTL of the requests, DEFAULT_REQUEST_TTL when it is zero.
+	TTL time.Duration
+}
+
+type SplitSummary struct {
+	Split  models.Split            `json:"split"`
+	Shares []models.PaymentRequest `json:"shares"`
+	// Requested is the sum of the shares, Settled of the ones paid by a sent
+	// transaction and Outstanding of the open ones and of the ones whose
+	// payment waits for approval.
+	Requested   models.Money `json:"requested"`
+	Settled     models.Money `json:"settled"`
+	Outstanding models.Money `json:"outstanding"`
+	// SettledShares counts the settled shares, Complete is set when every
+	// share is settled.
+	SettledShares int  `json:"settled_shares"`
+	Complete      bool `json:"complete"`
+}
+
+// proportionalShares divides the amount by the weights, the shares are
+// rounded down and the remainder stays with the own share, or is spread by a
+// minor unit over the first shares when there is no own share.
+func proportionalShares(amount models.Money, ownWeight uint, weights []uint) (models.Money, []models.Money) {
+	total := new(big.Int).SetUint64(uint64(ownWeight))
+	for _, weight := range weights {
+		total.Add(total, new(big.Int).SetUint64(uint64(weight)))
+	}
+	shares := make([]models.Money, len(weights))
+	left := amount.Minor
+	for i, weight := range weights {
+		minor := new(big.Int).Mul(big.NewInt(amount.Minor), new(big.Int).SetUint64(uint64(weight)))
+		minor.Quo(minor, total)
+		shares[i] = models.NewMoney(minor.Int64(), amount.Exponent)
+		left -= minor.Int64()
+	}
+	if ownWeight == 0 {
+		for i := 0; left > 0; i++ {
+			shares[i].Minor++
+			left--
+		}
+	}
+	return models.NewMoney(left, amount.Exponent), shares
+}
+
+// customShares checks the requested amounts against the amount and returns
+// the part the user keeps.
+func customShares(amount models.Money, requested []models.Money) (models.Money, []models.Money, error) {
+	shares := make([]models.Money, len(requested))
+	own := amount
+	for i, share := range requested {
+		share, err := share.Rescale(amount.Exponent)
+		if err != nil {
+			return models.Money{}, nil, err
+		}
+		if !share.IsPositive() {
+			return models.Money{}, nil, ErrWrongAmount
+		}
+		own, err = own.Sub(share)
+		if err != nil {
+			return models.Money{}, nil, err
+		}
+		shares[i] = share
+	}
+	if own.IsNegative() {
+		return models.Money{}, nil, ErrSplitAmount
+	}
+	return own, shares, nil
+}
+
+// SplitTransaction shares the sent transaction of the account with the payers
+// of the shares, each of them gets a payment request for the share which is
+// paid back to the account.
+func (p *PaymentSystem) SplitTransaction(s Split) (SplitSummary, error) {
+	if len(s.Shares) == 0 {
+		return SplitSummary{}, ErrNoShares
+	}
+	transaction, err := p.Repo.GetTransactionByUUID(s.TransactionUUID)
+	if err != nil {
+		return SplitSummary{}, err
+	}
+	if transaction.SourceUUID != s.AccountUUID || transaction.Status != SENT {
+		return SplitSummary{}, ErrNotSplittable
+	}
+	var own models.Money
+	var amounts []models.Money
+	switch s.Mode {
+	case PROPORTIONAL:
+		weights := make([]uint, len(s.Shares))
+		for i, share := range s.Shares {
+			weights[i] = share.Weight
+			if weights[i] == 0 {
+				weights[i] = 1
+			}
+		}
+		own, amounts = proportionalShares(transaction.Amount, s.OwnWeight, weights)
+	case CUSTOM:
+		requested := make([]models.Money, len(s.Shares))
+		for i, share := range s.Shares {
+			requested[i] = share.Amount
+		}
+		own, amounts, err = customShares(transaction.Amount, requested)
+		if err != nil {
+			return SplitSummary{}, err
+		}
+	default:
+		return SplitSummary{}, ErrUnknownSplitMode
+	}
+	split := models.Split{
+		UserUUID:        s.UserUUID,
+		AccountUUID:     s.AccountUUID,
+		TransactionUUID: transaction.UUID,
+		Mode:            s.Mode,
+		Amount:          transaction.Amount,
+		OwnShare:        own,
+		Currency:        transaction.Currency,
+		Description:     s.Description,
+	}
+	split.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return SplitSummary{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			requests := make([]models.PaymentRequest, len(s.Shares))
+			for i, share := range s.Shares {
+				request, err := newPaymentRequest(repo, PaymentRequest{
+					RequesterUUID: s.UserUUID,
+					AccountUUID:   s.AccountUUID,
+					PayerEmail:    share.PayerEmail,
+					PayerIBAN:     share.PayerIBAN,
+					Amount:        amounts[i],
+					Description:   s.Description,
+					TTL:           s.TTL,
+				})
+				if err != nil {
+					return err
+				}
+				request.SplitUUID = split.UUID
+				requests[i] = request
+			}
+			err := repo.CreateSplit(split)
+			if errors.Is(err, repository.ErrorCreated) {
+				return ErrAlreadySplit
+			}
+			if err != nil {
+				return err
+			}
+			for _, request := range requests {
+				err = repo.CreatePaymentRequest(request)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return SplitSummary{}, err
+	}
+	return p.GetSplit(s.AccountUUID, split.UUID)
+}
+
+func (p *PaymentSystem) GetSplits(accountUUID uuid.UUID, query models.QueryParams) ([]models.Split, error) {
+	return p.Repo.GetSplitsForAccount(accountUUID, query)
+}
+
+// GetSplit returns the split of the account with its shares and how much of
+// them is settled.
+func (p *PaymentSystem) GetSplit(accountUUID, splitUUID uuid.UUID) (SplitSummary, error) {
+	split, err := p.Repo.GetSplitByUUID(splitUUID)
+	if err != nil {
+		return SplitSummary{}, err
+	}
+	if split.AccountUUID != accountUUID {
+		return SplitSummary{}, ErrPermissionDenied
+	}
+	shares, err := p.Repo.GetPaymentRequestsForSplit(splitUUID)
+	if err != nil {
+		return SplitSummary{}, err
+	}
+	zero := models.NewMoney(0, split.Amount.Exponent)
+	summary := SplitSummary{
+		Split:       *split,
+		Shares:      shares,
+		Requested:   zero,
+		Settled:     zero,
+		Outstanding: zero,
+	}
+	for _, share := range shares {
+		summary.Requested, err = summary.Requested.Add(share.Amount)
+		if err != nil {
+			return SplitSummary{}, err
+		}
+		status, err := shareStatus(p.Repo, share)
+		if err != nil {
+			return SplitSummary{}, err
+		}
+		switch status {
+		case SENT:
+			summary.Settled, err = summary.Settled.Add(share.Amount)
+			summary.SettledShares++
+		case OPEN, PENDING_APPROVAL:
+			summary.Outstanding, err = summary.Outstanding.Add(share.Amount)
+		}
+		if err != nil {
+			return SplitSummary{}, err
+		}
+	}
+	summary.Complete = summary.SettledShares == len(shares)
+	return summary, nil
+}
+
+// shareStatus returns the status of the transaction which paid the accepted
+// share, the status of the request otherwise.
+func shareStatus(repo repository.Repository, share models.PaymentRequest) (string, error) {
+	if share.Status != ACCEPTED {
+		return share.Status, nil
+	}
+	transaction, err := repo.GetTransactionByUUID(share.TransactionUUID)
+	if err != nil {
+		return "", err
+	}
+	return transaction.Status, nil
+}
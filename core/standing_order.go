@@ -0,0 +1,301 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DAILY   = "daily"
+	WEEKLY  = "weekly"
+	MONTHLY = "monthly"
+
+	FINISHED = "finished"
+)
+
+var (
+	ErrUnknownInterval   = errors.New("unknown interval")
+	ErrStandingOrderDone = errors.New("standing order is not active")
+	ErrStartInPast       = errors.New("start of the standing order is in the past")
+	ErrEndBeforeStart    = errors.New("end of the standing order is before its start")
+)
+
+type StandingOrder struct {
+	UserUUID        uuid.UUID
+	SourceUUID      uuid.UUID
+	DestinationUUID uuid.UUID
+	Amount          models.Money
+	Interval        string
+	StartAt         time.Time
+	EndAt           *time.Time
+	MaxRuns         uint
+}
+
+// nextRun returns the time of the n-th run of the order starting at start.
+// Monthly runs keep the day of the start clamped to the end of shorter months.
+func nextRun(start time.Time, interval string, n uint) time.Time {
+	switch interval {
+	case DAILY:
+		return start.AddDate(0, 0, int(n))
+	case WEEKLY:
+		return start.AddDate(0, 0, 7*int(n))
+	}
+	year, month, day := start.Date()
+	firstOfMonth := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+func validInterval(interval string) bool {
+	return interval == DAILY || interval == WEEKLY || interval == MONTHLY
+}
+
+// finished reports whether the order has no more runs left.
+func finished(order *models.StandingOrder) bool {
+	if order.MaxRuns != 0 && order.Runs >= order.MaxRuns {
+		return true
+	}
+	return order.EndAt != nil && order.NextRunAt.After(*order.EndAt)
+}
+
+func (p *PaymentSystem) NewStandingOrder(so StandingOrder) (models.StandingOrder, error) {
+	if so.SourceUUID == so.DestinationUUID {
+		return models.StandingOrder{}, ErrWrongDestination
+	}
+	if !so.Amount.IsPositive() {
+		return models.StandingOrder{}, ErrWrongAmount
+	}
+	if !validInterval(so.Interval) {
+		return models.StandingOrder{}, ErrUnknownInterval
+	}
+	now := time.Now()
+	if so.StartAt.IsZero() {
+		so.StartAt = now
+	}
+	if so.StartAt.Before(now) {
+		return models.StandingOrder{}, ErrStartInPast
+	}
+	if so.EndAt != nil && so.EndAt.Before(so.StartAt) {
+		return models.StandingOrder{}, ErrEndBeforeStart
+	}
+	order := models.StandingOrder{
+		UserUUID:        so.UserUUID,
+		SourceUUID:      so.SourceUUID,
+		DestinationUUID: so.DestinationUUID,
+		Amount:          so.Amount,
+		Interval:        so.Interval,
+		StartAt:         so.StartAt,
+		EndAt:           so.EndAt,
+		MaxRuns:         so.MaxRuns,
+		NextRunAt:       so.StartAt,
+		Status:          ACTIVE,
+	}
+	var err error
+	order.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	err = p.Repo.CreateStandingOrder(order)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	return p.GetStandingOrder(so.SourceUUID, order.UUID)
+}
+
+func (p *PaymentSystem) GetStandingOrders(accountUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrder, error) {
+	return p.Repo.GetStandingOrdersForAccount(accountUUID, query)
+}
+
+func (p *PaymentSystem) GetStandingOrder(accountUUID, orderUUID uuid.UUID) (models.StandingOrder, error) {
+	order, err := p.Repo.GetStandingOrderByUUID(orderUUID)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	if order.SourceUUID != accountUUID {
+		return models.StandingOrder{}, ErrPermissionDenied
+	}
+	return *order, nil
+}
+
+// UpdateStandingOrder changes the active order; zero values keep the current ones.
+func (p *PaymentSystem) UpdateStandingOrder(accountUUID, orderUUID uuid.UUID, so StandingOrder) (models.StandingOrder, error) {
+	order, err := p.GetStandingOrder(accountUUID, orderUUID)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	if order.Status != ACTIVE {
+		return models.StandingOrder{}, ErrStandingOrderDone
+	}
+	if so.DestinationUUID != uuid.Nil {
+		if so.DestinationUUID == order.SourceUUID {
+			return models.StandingOrder{}, ErrWrongDestination
+		}
+		order.DestinationUUID = so.DestinationUUID
+	}
+	if !so.Amount.IsZero() {
+		if so.Amount.IsNegative() {
+			return models.StandingOrder{}, ErrWrongAmount
+		}
+		order.Amount = so.Amount
+	}
+	if so.Interval != "" {
+		if !validInterval(so.Interval) {
+			return models.StandingOrder{}, ErrUnknownInterval
+		}
+		order.Interval = so.Interval
+		order.NextRunAt = nextRun(order.StartAt, order.Interval, order.Runs)
+	}
+	if so.EndAt != nil {
+		if so.EndAt.Before(order.StartAt) {
+			return models.StandingOrder{}, ErrEndBeforeStart
+		}
+		order.EndAt = so.EndAt
+	}
+	if so.MaxRuns != 0 {
+		order.MaxRuns = so.MaxRuns
+	}
+	if finished(&order) {
+		order.Status = FINISHED
+	}
+	err = p.Repo.UpdateStandingOrder(order)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	return p.GetStandingOrder(accountUUID, orderUUID)
+}
+
+func (p *PaymentSystem) CancelStandingOrder(accountUUID, orderUUID uuid.UUID) (models.StandingOrder, error) {
+	order, err := p.GetStandingOrder(accountUUID, orderUUID)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	if order.Status != ACTIVE {
+		return models.StandingOrder{}, ErrStandingOrderDone
+	}
+	order.Status = CANCELLED
+	err = p.Repo.UpdateStandingOrder(order)
+	if err != nil {
+		return models.StandingOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PaymentSystem) GetStandingOrderRuns(accountUUID, orderUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrderRun, error) {
+	if _, err := p.GetStandingOrder(accountUUID, orderUUID); err != nil {
+		return []models.StandingOrderRun{}, err
+	}
+	return p.Repo.GetStandingOrderRuns(orderUUID, query)
+}
+
+// RunStandingOrders creates and sends the transactions of the due standing
+// orders and records every run. It returns the number of successful runs.
+// An order runs once per call, the periods missed before now are skipped.
+// The order is locked and advanced in the repository transaction of its
+// payment, so concurrent workers and failed updates can't pay a period twice.
+func (p *PaymentSystem) RunStandingOrders(now time.Time) (int, error) {
+	orders, err := p.Repo.GetDueStandingOrders(now)
+	if err != nil {
+		return 0, err
+	}
+	succeeded := 0
+	for _, due := range orders {
+		var run models.StandingOrderRun
+		err = p.Repo.Transaction(
+			func(repo repository.Repository) error {
+				run = models.StandingOrderRun{}
+				order, err := repo.GetStandingOrderForUpdate(due.UUID)
+				if err != nil {
+					return err
+				}
+				// another worker may have run the order meanwhile
+				if order.Status != ACTIVE || order.NextRunAt.After(now) {
+					return nil
+				}
+				run, err = p.runStandingOrder(repo, *order)
+				if err != nil {
+					return err
+				}
+				run.UUID, err = uuid.NewRandom()
+				if err != nil {
+					return err
+				}
+				run.OrderUUID = order.UUID
+				err = repo.CreateStandingOrderRun(run)
+				if err != nil {
+					return err
+				}
+				advance := *order
+				advance.Runs++
+				advance.NextRunAt = nextRun(advance.StartAt, advance.Interval, advance.Runs)
+				// the skipped periods count as runs, so the order keeps its schedule
+				for !advance.NextRunAt.After(now) && !finished(&advance) {
+					advance.Runs++
+					advance.NextRunAt = nextRun(advance.StartAt, advance.Interval, advance.Runs)
+				}
+				if finished(&advance) {
+					advance.Status = FINISHED
+				}
+				return repo.UpdateStandingOrder(advance)
+			})
+		if err != nil {
+			return succeeded, err
+		}
+		if run.Status == SENT {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// runStandingOrder creates and sends the transaction of the locked order
+// within the repository transaction. A transaction which can't be sent is
+// failed and the run records the reason; only repository errors are returned.
+func (p *PaymentSystem) runStandingOrder(repo repository.Repository, order models.StandingOrder) (models.StandingOrderRun, error) {
+	if err := checkActive(repo, order.SourceUUID); err != nil {
+		return models.StandingOrderRun{Status: FAILED, Error: "source account is not active"}, nil
+	}
+	// the creator may have been removed from the account or downgraded since
+	if err := checkPermission(repo, order.UserUUID, order.SourceUUID, SEND); err != nil {
+		return models.StandingOrderRun{Status: FAILED, Error: err.Error()}, nil
+	}
+	transaction, err := p.prepare(Transaction{
+		UserUUID:        order.UserUUID,
+		SourceUUID:      order.SourceUUID,
+		DestinationUUID: order.DestinationUUID,
+		Amount:          order.Amount,
+	})
+	if err != nil {
+		return models.StandingOrderRun{Status: FAILED, Error: err.Error()}, nil
+	}
+	err = createTransaction(repo, transaction)
+	if err != nil {
+		return models.StandingOrderRun{}, err
+	}
+	err = lockAccounts(repo, transaction.SourceUUID, transaction.DestinationUUID)
+	if err != nil {
+		return models.StandingOrderRun{}, err
+	}
+	approval, err := p.admit(repo, &transaction)
+	if err != nil {
+		if failErr := failIn(repo, &transaction, err.Error()); failErr != nil {
+			return models.StandingOrderRun{}, failErr
+		}
+		return models.StandingOrderRun{TransactionUUID: transaction.UUID, Status: FAILED, Error: err.Error()}, nil
+	}
+	if approval {
+		err = updateStatus(repo, &transaction, PENDING_APPROVAL, "")
+	} else {
+		err = execute(repo, &transaction, TRANSFER, "")
+	}
+	if err != nil {
+		return models.StandingOrderRun{}, err
+	}
+	return models.StandingOrderRun{TransactionUUID: transaction.UUID, Status: transaction.Status}, nil
+}
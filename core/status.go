@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"payment/models"
+	"payment/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PROCESSING = "processing"
+	FAILED     = "failed"
+)
+
+var ErrStatusTransition = errors.New("transaction status transition is not allowed")
+
+// transitions lists the statuses every transaction status can be changed to.
+var transitions = map[string][]string{
+	PREPARED:         {PROCESSING, PENDING_APPROVAL, CANCELLED, EXPIRED, FAILED},
+	SCHEDULED:        {PROCESSING, PENDING_APPROVAL, CANCELLED, FAILED},
+	PENDING_APPROVAL: {PROCESSING, REJECTED, CANCELLED},
+	PROCESSING:       {SENT, FAILED},
+	SENT:             {REVERSED},
+}
+
+func canTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func newStatusChange(transactionUUID uuid.UUID, from, to, reason string) (models.TransactionStatusChange, error) {
+	changeUUID, err := uuid.NewRandom()
+	if err != nil {
+		return models.TransactionStatusChange{}, err
+	}
+	return models.TransactionStatusChange{
+		UUID:            changeUUID,
+		TransactionUUID: transactionUUID,
+		From:            from,
+		To:              to,
+		Reason:          reason,
+	}, nil
+}
+
+// createTransaction stores the new transaction and the first entry of its status history.
+func createTransaction(repo repository.Repository, transaction models.Transaction) error {
+	err := repo.CreateTransaction(transaction)
+	if err != nil {
+		return err
+	}
+	change, err := newStatusChange(transaction.UUID, "", transaction.Status, "")
+	if err != nil {
+		return err
+	}
+	return repo.CreateStatusChange(change)
+}
+
+// updateStatus moves the transaction to the new status if the state machine
+// allows it and records the change in the status history.
+func updateStatus(repo repository.Repository, transaction *models.Transaction, status, reason string) error {
+	from := transaction.Status
+	if !canTransition(from, status) {
+		return ErrStatusTransition
+	}
+	err := repo.UpdateStatusTransaction(transaction.UUID, status)
+	if err != nil {
+		return err
+	}
+	change, err := newStatusChange(transaction.UUID, from, status, reason)
+	if err != nil {
+		return err
+	}
+	err = repo.CreateStatusChange(change)
+	if err != nil {
+		return err
+	}
+	transaction.Status = status
+	return nil
+}
+
+func (p *PaymentSystem) GetStatusHistory(transactionUUID uuid.UUID) ([]models.TransactionStatusChange, error) {
+	return p.Repo.GetStatusHistory(transactionUUID)
+}
@@ -4,25 +4,40 @@ import (
 	"errors"
 	"payment/models"
 	"payment/repository"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const (
-	SENT = "sent"
+	PREPARED  = "prepared"
+	SENT      = "sent"
+	CANCELLED = "cancelled"
+	EXPIRED   = "expired"
+
+	DEFAULT_TRANSACTION_TTL = 24 * time.Hour
 )
 
 var (
-	ErrUnknownAccount    = errors.New("unknown account")
-	ErrInsufficientFunds = errors.New("insufficient funds")
-	ErrWrongDestination  = errors.New("source equals destination")
+	ErrUnknownAccount         = errors.New("unknown account")
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+	ErrWrongDestination       = errors.New("source equals destination")
+	ErrTransactionNotPrepared = errors.New("transaction is not prepared")
+	ErrDestinationMismatch    = errors.New("destination iban doesn't match destination uuid")
+	ErrWrongAmount            = errors.New("amount has to be positive")
 )
 
 type Transaction struct {
 	UserUUID        uuid.UUID
 	SourceUUID      uuid.UUID
 	DestinationUUID uuid.UUID
-	Amount          uint
+	// DestinationIBAN is resolved to DestinationUUID when it is set.
+	DestinationIBAN string
+	// PocketUUID is the pocket the amount is spent from, the main pocket
+	// when it is nil.
+	PocketUUID uuid.UUID
+	Amount     models.Money
+	ExecuteAt  time.Time
 }
 
 func GetEmail(token string) (string, bool) {
@@ -31,69 +46,287 @@ func GetEmail(token string) (string, bool) {
 }
 
 type PaymentSystem struct {
-	Repo repository.Repository
+	Repo  repository.Repository
+	Rates RateProvider
+	// ApprovalThreshold in DEFAULT_CURRENCY, transfers above it wait for an
+	// admin approval; zero disables the approval.
+	ApprovalThreshold models.Money
+	IBANs             IBANGenerator
+	// InterestRates are the annual rates in basis points per account type.
+	InterestRates map[string]uint
+	// OverdraftRate is the annual rate in basis points charged on the
+	// balances below zero, OverdraftFee in DEFAULT_CURRENCY is charged
+	// monthly when the account was below zero.
+	OverdraftRate uint
+	OverdraftFee  models.Money
+	// Payouts sends the withdrawals out of the system, nil disables them.
+	Payouts PayoutGateway
 }
 
 func NewPaymentSystem(userRepo repository.Repository) PaymentSystem {
 	return PaymentSystem{
-		Repo: userRepo,
+		Repo:  userRepo,
+		Rates: NewStaticRates(DEFAULT_CURRENCY, nil),
+		IBANs: IBANGenerator{Country: DEFAULT_IBAN_COUNTRY, BankCode: DEFAULT_BANK_CODE},
 	}
 }
 
 func (p *PaymentSystem) NewTransaction(tr Transaction) (models.Transaction, error) {
+	transaction, err := p.prepare(tr)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	err = p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			return createTransaction(repo, transaction)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transactionModel, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *transactionModel, nil
+}
+
+// prepare resolves the destination of the new transaction, quotes it and
+// checks the balance and limits, the transaction isn't stored.
+func (p *PaymentSystem) prepare(tr Transaction) (models.Transaction, error) {
+	destinationUUID, err := p.resolveDestination(tr.DestinationUUID, tr.DestinationIBAN)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tr.DestinationUUID = destinationUUID
 	if tr.SourceUUID == tr.DestinationUUID {
 		return models.Transaction{}, ErrWrongDestination
 	}
-	err := p.checkAmount(tr.SourceUUID, tr.Amount)
+	err = checkOpen(p.Repo, tr.SourceUUID, tr.DestinationUUID)
 	if err != nil {
 		return models.Transaction{}, err
 	}
+	if !tr.Amount.IsPositive() {
+		return models.Transaction{}, ErrWrongAmount
+	}
+	if tr.PocketUUID != uuid.Nil {
+		if _, err := pocketOf(p.Repo, tr.SourceUUID, tr.PocketUUID); err != nil {
+			return models.Transaction{}, err
+		}
+	}
 	transaction := models.Transaction{
-		Status:          "prepared",
+		Status:          PREPARED,
 		SourceUUID:      tr.SourceUUID,
 		DestinationUUID: tr.DestinationUUID,
+		PocketUUID:      tr.PocketUUID,
+		UserUUID:        tr.UserUUID,
 		Amount:          tr.Amount,
 	}
-	transaction.UUID, err = uuid.NewRandom()
+	err = p.quote(&transaction)
 	if err != nil {
 		return models.Transaction{}, err
 	}
-	err = p.Repo.CreateTransaction(transaction)
+	transaction.Fee, err = fee(p.Repo, &transaction)
 	if err != nil {
 		return models.Transaction{}, err
 	}
-	transactionModel, err := p.Repo.GetTransactionByUUID(transaction.UUID)
+	total, err := debited(&transaction)
 	if err != nil {
 		return models.Transaction{}, err
 	}
-	return *transactionModel, nil
+	sendAt := time.Now()
+	if tr.ExecuteAt.IsZero() {
+		err := checkFunds(p.Repo, &transaction, total)
+		if err != nil {
+			return models.Transaction{}, err
+		}
+	} else {
+		if !tr.ExecuteAt.After(sendAt) {
+			return models.Transaction{}, ErrExecuteAt
+		}
+		sendAt = tr.ExecuteAt
+		transaction.Status = SCHEDULED
+		transaction.ExecuteAt = &tr.ExecuteAt
+	}
+	err = p.checkLimits(p.Repo, &transaction, sendAt)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transaction.UUID, err = uuid.NewRandom()
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return transaction, nil
+}
+
+// resolveDestination returns the account of the IBAN when it is set, which
+// has to match the destination UUID if both are given.
+func (p *PaymentSystem) resolveDestination(destinationUUID uuid.UUID, destinationIBAN string) (uuid.UUID, error) {
+	if destinationIBAN == "" {
+		return destinationUUID, nil
+	}
+	iban, err := ValidateIBAN(destinationIBAN)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	destination, err := p.Repo.GetAccountByIBAN(iban)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if destinationUUID != uuid.Nil && destinationUUID != destination.UUID {
+		return uuid.Nil, ErrDestinationMismatch
+	}
+	return destination.UUID, nil
 }
 
 func (p *PaymentSystem) GetTransactions(accountUUID uuid.UUID, query models.QueryParams) ([]models.Transaction, error) {
 	return p.Repo.GetTransactionForAccount(accountUUID, query)
 }
 
-func (p *PaymentSystem) SendTransaction(transactionUUID uuid.UUID) (models.Transaction, error) {
+// SendTransaction sends the prepared transaction of the account, the
+// transactions of other accounts are denied.
+func (p *PaymentSystem) SendTransaction(accountUUID, transactionUUID uuid.UUID) (models.Transaction, error) {
+	transaction, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if transaction.SourceUUID != accountUUID {
+		return models.Transaction{}, ErrPermissionDenied
+	}
+	return p.send(transactionUUID, PREPARED)
+}
+
+// send moves the money of the transaction which is in the given status, the
+// transactions above the approval threshold are left for an admin to approve.
+func (p *PaymentSystem) send(transactionUUID uuid.UUID, status string) (models.Transaction, error) {
+	err := p.Repo.Transaction(
+		func(repo repository.Repository) error {
+			return p.sendIn(repo, transactionUUID, status)
+		})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tr, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return *tr, nil
+}
+
+// sendIn is send within the repository transaction. Both accounts are locked
+// before the status and the balance are checked, so concurrent sends can't
+// pass the checks with the same balance or send the transaction twice.
+func (p *PaymentSystem) sendIn(repo repository.Repository, transactionUUID uuid.UUID, status string) error {
+	transaction, err := repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return err
+	}
+	err = lockAccounts(repo, transaction.SourceUUID, transaction.DestinationUUID)
+	if err != nil {
+		return err
+	}
+	// the transaction may have been changed while the locks were awaited
+	transaction, err = repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return err
+	}
+	if transaction.Status != status {
+		return ErrTransactionNotPrepared
+	}
+	approval, err := p.admit(repo, transaction)
+	if err != nil {
+		return err
+	}
+	if approval {
+		return updateStatus(repo, transaction, PENDING_APPROVAL, "")
+	}
+	return execute(repo, transaction, TRANSFER, "")
+}
+
+// admit checks the funds and the limits of the transaction on the locked
+// accounts and reports whether it has to wait for approval.
+func (p *PaymentSystem) admit(repo repository.Repository, transaction *models.Transaction) (bool, error) {
+	total, err := debited(transaction)
+	if err != nil {
+		return false, err
+	}
+	err = checkFunds(repo, transaction, total)
+	if err != nil {
+		return false, err
+	}
+	err = p.checkLimits(repo, transaction, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return p.needsApproval(transaction)
+}
+
+// execute moves the money of the transaction, books its fee and marks it as
+// sent; kind is the kind of the ledger entries of the move. The transaction
+// spent from a pocket takes the money out of it.
+func execute(repo repository.Repository, transaction *models.Transaction, kind, reason string) error {
+	err := updateStatus(repo, transaction, PROCESSING, reason)
+	if err != nil {
+		return err
+	}
+	if transaction.PocketUUID != uuid.Nil {
+		total, err := debited(transaction)
+		if err != nil {
+			return err
+		}
+		err = spendPocket(repo, transaction.SourceUUID, transaction.PocketUUID, total)
+		if err != nil {
+			return err
+		}
+	}
+	journalUUID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	err = transfer(repo, journalUUID, transaction, kind)
+	if err != nil {
+		return err
+	}
+	if transaction.Fee.IsPositive() {
+		err = move(repo, journalUUID, transaction.UUID, FEE, transaction.SourceUUID, revenueAccount(transaction.Currency), transaction.Fee)
+		if err != nil {
+			return err
+		}
+	}
+	return updateStatus(repo, transaction, SENT, "")
+}
+
+// CancelTransaction cancels the prepared, scheduled or waiting for approval
+// transaction of the account.
+func (p *PaymentSystem) CancelTransaction(accountUUID, transactionUUID uuid.UUID) (models.Transaction, error) {
 	err := p.Repo.Transaction(
 		func(repo repository.Repository) error {
 			transaction, err := repo.GetTransactionByUUID(transactionUUID)
 			if err != nil {
 				return err
 			}
-			err = p.checkAmount(transaction.SourceUUID, transaction.Amount)
-			if err != nil {
-				return err
+			if transaction.SourceUUID != accountUUID {
+				return ErrPermissionDenied
 			}
-			err = repo.DecBalance(transaction.SourceUUID, transaction.Amount)
+			// the approval of the transaction locks the accounts as well
+			err = lockAccounts(repo, transaction.SourceUUID, transaction.DestinationUUID)
 			if err != nil {
 				return err
 			}
-			err = repo.IncBalance(transaction.DestinationUUID, transaction.Amount)
+			transaction, err = repo.GetTransactionByUUID(transactionUUID)
 			if err != nil {
 				return err
 			}
-			err = repo.UpdateStatusTransaction(transactionUUID, SENT)
-			return err
+			switch transaction.Status {
+			case PREPARED, SCHEDULED:
+				return updateStatus(repo, transaction, CANCELLED, "cancelled by user")
+			case PENDING_APPROVAL:
+				err = updateStatus(repo, transaction, CANCELLED, "cancelled by user")
+				if err != nil {
+					return err
+				}
+				return settlePaymentRequest(repo, transaction)
+			}
+			return ErrTransactionNotPrepared
 		})
 	if err != nil {
 		return models.Transaction{}, err
@@ -104,3 +337,61 @@ func (p *PaymentSystem) SendTransaction(transactionUUID uuid.UUID) (models.Trans
 	}
 	return *tr, nil
 }
+
+// ExpirePreparedTransactions moves the transactions prepared before the given
+// time to the expired status and returns how many of them were expired.
+func (p *PaymentSystem) ExpirePreparedTransactions(createdBefore time.Time) (int, error) {
+	transactions, err := p.Repo.GetTransactionsByStatus(PREPARED, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, tr := range transactions {
+		err := p.Repo.Transaction(
+			func(repo repository.Repository) error {
+				transaction, err := repo.GetTransactionByUUID(tr.UUID)
+				if err != nil {
+					return err
+				}
+				if transaction.Status != PREPARED {
+					return ErrTransactionNotPrepared
+				}
+				return updateStatus(repo, transaction, EXPIRED, "not sent in time")
+			})
+		if err != nil {
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+type TransactionDetails struct {
+	Transaction models.Transaction               `json:"transaction"`
+	History     []models.TransactionStatusChange `json:"history"`
+	Refunds     []models.Transaction             `json:"refunds"`
+}
+
+// GetTransaction returns the transaction of the account with its status history and refunds.
+func (p *PaymentSystem) GetTransaction(accountUUID, transactionUUID uuid.UUID) (TransactionDetails, error) {
+	transaction, err := p.Repo.GetTransactionByUUID(transactionUUID)
+	if err != nil {
+		return TransactionDetails{}, err
+	}
+	if transaction.SourceUUID != accountUUID && transaction.DestinationUUID != accountUUID {
+		return TransactionDetails{}, ErrPermissionDenied
+	}
+	history, err := p.Repo.GetStatusHistory(transactionUUID)
+	if err != nil {
+		return TransactionDetails{}, err
+	}
+	refunds, err := p.Repo.GetRefunds(transactionUUID)
+	if err != nil {
+		return TransactionDetails{}, err
+	}
+	return TransactionDetails{
+		Transaction: *transaction,
+		History:     history,
+		Refunds:     refunds,
+	}, nil
+}
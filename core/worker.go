@@ -0,0 +1,20 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodically calls the job every interval until the context is done.
+func RunPeriodically(ctx context.Context, interval time.Duration, job func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			job(now)
+		}
+	}
+}
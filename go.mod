@@ -7,13 +7,13 @@ require (
 	github.com/go-playground/assert/v2 v2.2.0
 	github.com/goccy/go-json v0.10.0
 	github.com/google/uuid v1.3.0
+	github.com/jackc/pgx/v5 v5.2.0
 	github.com/matthewhartstonge/argon2 v0.3.2
 )
 
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/jackc/pgx/v5 v5.2.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 )
 
@@ -78,9 +78,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 60 {
-			t.Fatalf("balance %v, exp: %v", money, 60)
+		money := account["balance"].(string)
+		if money != "60.00" {
+			t.Fatalf("balance %v, exp: %v", money, "60.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
 		inputTr := controllers.TransactionInput{
@@ -102,16 +102,75 @@ func TestPaymentIntegration(t *testing.T) {
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, sourceUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 10 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 10)
+		if balance := reqResult["balance"].(string); balance != "10.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "10.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, destinationUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 50 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 50)
+		if balance := reqResult["balance"].(string); balance != "50.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "50.00")
 		}
 
 	})
+	t.Run("concurrentSendNoOverdraft", func(t *testing.T) {
+		input := controllers.RegisterInput{
+			FisrtName: "Bob",
+			LastName:  "Racer",
+			Email:     "racer@i.ua",
+			Password:  "qwerty",
+		}
+		reqResult := sendReq(t, "POST", "http://localhost:8080/users/register", input, nil)
+		if _, ok := reqResult["message"]; !ok {
+			t.Fatal("error register")
+		}
+		var userUUID string = reqResult["uuid"].(string)
+		reqResult = sendReq(t, "POST", "http://localhost:8080/users/login", input, nil)
+		token, ok := reqResult["token"].(string)
+		if !ok {
+			t.Fatal("error login")
+		}
+		url := fmt.Sprintf("http://localhost:8080/users/%v/accounts/new", userUUID)
+		auth := make(map[string]string)
+		auth["Authorization"] = token
+		reqResult = sendReq(t, "POST", url, nil, auth)
+		sourceUUID := reqResult["uuid"].(string)
+		reqResult = sendReq(t, "POST", url, nil, auth)
+		destinationUUID := reqResult["uuid"].(string)
+		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/add-money", userUUID, sourceUUID)
+		reqResult = sendReq(t, "POST", url, controllers.AddMoneyInput{Amount: "10"}, auth)
+		if _, ok := reqResult["message"]; !ok {
+			t.Fatal("add money error")
+		}
+		// every transaction fits the balance alone, together they would overdraw it
+		transactions := make([]string, 0)
+		for i := 0; i < 20; i++ {
+			url := fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
+			reqResult := sendReq(t, "POST", url, controllers.TransactionInput{DestinationUUID: destinationUUID, Amount: "1"}, auth)
+			if _, ok := reqResult["message"]; !ok {
+				t.Fatal("create transaction error")
+			}
+			transactions = append(transactions, reqResult["transaction"].(map[string]any)["uuid"].(string))
+		}
+		var wg sync.WaitGroup
+		for _, transUUID := range transactions {
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func(transUUID string) {
+					defer wg.Done()
+					url := fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/%v/send", userUUID, sourceUUID, transUUID)
+					sendReq(t, "POST", url, nil, auth)
+				}(transUUID)
+			}
+		}
+		wg.Wait()
+		for accountUUID, expected := range map[string]string{sourceUUID: "0.00", destinationUUID: "10.00"} {
+			url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, accountUUID)
+			reqResult = sendReq(t, "GET", url, nil, auth)
+			if balance := reqResult["balance"].(string); balance != expected {
+				t.Fatalf("wrong balance :%v, exp:%v", balance, expected)
+			}
+		}
+	})
 	t.Run("wrongAccount", func(t *testing.T) {
 		inputBob := controllers.RegisterInput{
 			FisrtName: "Bob",
@@ -238,9 +297,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 60 {
-			t.Fatalf("balance %v, exp: %v", money, 60)
+		money := account["balance"].(string)
+		if money != "60.00" {
+			t.Fatalf("balance %v, exp: %v", money, "60.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
 		inputTr := controllers.TransactionInput{
@@ -253,13 +312,13 @@ func TestPaymentIntegration(t *testing.T) {
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, sourceUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 60 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 60)
+		if balance := reqResult["balance"].(string); balance != "60.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "60.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, destinationUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 0 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 0)
+		if balance := reqResult["balance"].(string); balance != "0.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "0.00")
 		}
 
 	})
@@ -302,9 +361,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 100 {
-			t.Fatalf("balance %v, exp: %v", money, 100)
+		money := account["balance"].(string)
+		if money != "100.00" {
+			t.Fatalf("balance %v, exp: %v", money, "100.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
 		inputTr1 := controllers.TransactionInput{
@@ -343,13 +402,13 @@ func TestPaymentIntegration(t *testing.T) {
 
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, sourceUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 30 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 30)
+		if balance := reqResult["balance"].(string); balance != "30.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "30.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, destinationUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 70 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 70)
+		if balance := reqResult["balance"].(string); balance != "70.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "70.00")
 		}
 
 	})
@@ -392,9 +451,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 100 {
-			t.Fatalf("balance %v, exp: %v", money, 100)
+		money := account["balance"].(string)
+		if money != "100.00" {
+			t.Fatalf("balance %v, exp: %v", money, "100.00")
 		}
 		var wg sync.WaitGroup
 		wg.Add(50)
@@ -423,8 +482,8 @@ func TestPaymentIntegration(t *testing.T) {
 		wg.Wait()
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v", userUUID, destinationUUID)
 		reqResult = sendReq(t, "GET", url, nil, auth)
-		if balance := reqResult["balance"].(float64); balance != 50 {
-			t.Fatalf("wrong balance :%v, exp:%v", balance, 50)
+		if balance := reqResult["balance"].(string); balance != "50.00" {
+			t.Fatalf("wrong balance :%v, exp:%v", balance, "50.00")
 		}
 
 	})
@@ -555,9 +614,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 60 {
-			t.Fatalf("balance %v, exp: %v", money, 60)
+		money := account["balance"].(string)
+		if money != "60.00" {
+			t.Fatalf("balance %v, exp: %v", money, "60.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
 		inputTr := controllers.TransactionInput{
@@ -634,9 +693,9 @@ func TestPaymentIntegration(t *testing.T) {
 			t.Fatal("add money error")
 		}
 		account := reqResult["account"].(map[string]any)
-		money := account["balance"].(float64)
-		if money != 60 {
-			t.Fatalf("balance %v, exp: %v", money, 60)
+		money := account["balance"].(string)
+		if money != "60.00" {
+			t.Fatalf("balance %v, exp: %v", money, "60.00")
 		}
 		url = fmt.Sprintf("http://localhost:8080/users/%v/accounts/%v/transactions/new", userUUID, sourceUUID)
 		inputTr := controllers.TransactionInput{
@@ -1,22 +1,126 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 	"payment/app"
 	"payment/controllers"
 	"payment/core"
+	"payment/models"
 	"payment/repository"
+	"strconv"
+	"time"
 )
 
+const WORKER_INTERVAL = time.Minute
+
 func main() {
 	DB := repository.ConnectDataBase()
 	userRepo := repository.NewGormUserRepo(DB)
 	system := core.NewPaymentSystem(userRepo)
+	if ratesFile, ok := os.LookupEnv("PAYMENT_RATES_FILE"); ok {
+		rates, err := core.LoadRates(ratesFile)
+		if err != nil {
+			log.Fatalf("can't load rates, err %v", err.Error())
+		}
+		system.Rates = rates
+	}
+	if thresholdStr, ok := os.LookupEnv("PAYMENT_APPROVAL_THRESHOLD"); ok {
+		threshold, err := models.ParseMoney(thresholdStr)
+		if err != nil {
+			log.Fatalf("wrong PAYMENT_APPROVAL_THRESHOLD, err %v", err.Error())
+		}
+		system.ApprovalThreshold = threshold
+	}
+	if country, ok := os.LookupEnv("PAYMENT_IBAN_COUNTRY"); ok {
+		bankCode, ok := os.LookupEnv("PAYMENT_BANK_CODE")
+		if !ok {
+			log.Fatal("please specify PAYMENT_BANK_CODE with PAYMENT_IBAN_COUNTRY")
+		}
+		ibans, err := core.NewIBANGenerator(country, bankCode)
+		if err != nil {
+			log.Fatalf("wrong iban settings, err %v", err.Error())
+		}
+		system.IBANs = ibans
+	}
+	if filled, err := system.BackfillIBANs(); err != nil {
+		log.Fatalf("can't backfill ibans, err %v", err.Error())
+	} else if filled > 0 {
+		log.Printf("backfilled ibans of %d accounts", filled)
+	}
+	if opened, err := system.OpenLedger(); err != nil {
+		log.Fatalf("can't open ledger, err %v", err.Error())
+	} else if opened > 0 {
+		log.Printf("wrote opening ledger entries of %d accounts", opened)
+	}
+	if ratesStr, ok := os.LookupEnv("PAYMENT_INTEREST_RATES"); ok {
+		rates, err := core.ParseInterestRates(ratesStr)
+		if err != nil {
+			log.Fatalf("wrong PAYMENT_INTEREST_RATES, err %v", err.Error())
+		}
+		system.InterestRates = rates
+	}
+	if rateStr, ok := os.LookupEnv("PAYMENT_OVERDRAFT_RATE"); ok {
+		rate, err := strconv.ParseUint(rateStr, 10, 32)
+		if err != nil || rate > core.MAX_BASIS_POINTS {
+			log.Fatalf("wrong PAYMENT_OVERDRAFT_RATE, it has to be basis points from 0 to %d", core.MAX_BASIS_POINTS)
+		}
+		system.OverdraftRate = uint(rate)
+	}
+	if feeStr, ok := os.LookupEnv("PAYMENT_OVERDRAFT_FEE"); ok {
+		fee, err := models.ParseMoney(feeStr)
+		if err != nil {
+			log.Fatalf("wrong PAYMENT_OVERDRAFT_FEE, err %v", err.Error())
+		}
+		system.OverdraftFee = fee
+	}
+	payoutDelay := core.DEFAULT_PAYOUT_DELAY
+	if delayStr, ok := os.LookupEnv("PAYMENT_PAYOUT_DELAY"); ok {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil {
+			log.Fatalf("wrong PAYMENT_PAYOUT_DELAY, err %v", err.Error())
+		}
+		payoutDelay = delay
+	}
+	gateway := &core.FakeGateway{Delay: payoutDelay}
+	system.Payouts = gateway
 	controller := controllers.NewHttpController(system)
+	gateway.Complete = controller.System.CompletePayout
+	controller.PayoutSecret = os.Getenv("PAYMENT_PAYOUT_SECRET")
 	err := controller.System.SetupAdmin()
 	if err != nil {
 		log.Fatalf("can't create admin, err %v", err.Error())
 	}
+	ttl := core.DEFAULT_TRANSACTION_TTL
+	if ttlStr, ok := os.LookupEnv("PAYMENT_TRANSACTION_TTL"); ok {
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil {
+			log.Fatalf("wrong PAYMENT_TRANSACTION_TTL, err %v", err.Error())
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go core.RunPeriodically(ctx, WORKER_INTERVAL, func(now time.Time) {
+		if _, err := system.ExpirePreparedTransactions(now.Add(-ttl)); err != nil {
+			log.Printf("can't expire transactions, err %v", err.Error())
+		}
+		if _, err := system.ExecuteScheduledTransactions(now); err != nil {
+			log.Printf("can't execute scheduled transactions, err %v", err.Error())
+		}
+		if _, err := system.RunStandingOrders(now); err != nil {
+			log.Printf("can't run standing orders, err %v", err.Error())
+		}
+		if _, err := system.ExpireHolds(now); err != nil {
+			log.Printf("can't expire holds, err %v", err.Error())
+		}
+		if _, err := system.ExpirePaymentRequests(now); err != nil {
+			log.Printf("can't expire payment requests, err %v", err.Error())
+		}
+		if _, err := system.AccrueInterest(now); err != nil {
+			log.Printf("can't accrue interest, err %v", err.Error())
+		}
+	})
 	app := app.New(controller)
 	app.Run(":8080")
 }
@@ -10,6 +10,7 @@ import (
 
 var AccountError = gin.H{"error": "wrong account"}
 var AccountBlockedError = gin.H{"error": "account is blocked"}
+var AccountClosedError = gin.H{"error": "account is closed"}
 
 func CheckAccount(c controllers.Controller) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -53,6 +54,17 @@ func CheckBlockedAccount(c controllers.Controller) gin.HandlerFunc {
 			return
 		}
 		if !ok {
+			closed, err := c.System.IsClosedAccount(accountUUID)
+			if err == nil && closed {
+				// the history of the closed account stays readable
+				if ctx.Request.Method == http.MethodGet {
+					ctx.Next()
+					return
+				}
+				ctx.JSON(http.StatusUnauthorized, AccountClosedError)
+				ctx.Abort()
+				return
+			}
 			ctx.JSON(http.StatusUnauthorized, AccountBlockedError)
 			ctx.Abort()
 			return
@@ -61,3 +73,31 @@ func CheckBlockedAccount(c controllers.Controller) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// CheckPermission lets through only the users who have at least the
+// permission on the account, the holder has all of them.
+func CheckPermission(c controllers.Controller, permission string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userUUIDstr := ctx.Param("user_uuid")
+		userUUID, err := uuid.Parse(userUUIDstr)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, UnauthenticatedError)
+			ctx.Abort()
+			return
+		}
+		accountUUIDstr := ctx.Param("account_uuid")
+		accountUUID, err := uuid.Parse(accountUUIDstr)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, AccountError)
+			ctx.Abort()
+			return
+		}
+		err = c.System.CheckAccountPermission(userUUID, accountUUID, permission)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
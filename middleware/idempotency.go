@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"payment/controllers"
+	"payment/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	IDEMPOTENCY_HEADER = "Idempotency-Key"
+	REPLAYED_HEADER    = "Idempotent-Replayed"
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Idempotency stores the first response for the Idempotency-Key header and
+// replays it for the repeated requests with the same key.
+func Idempotency(c controllers.Controller) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IDEMPOTENCY_HEADER)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		userUUIDstr := ctx.Param("user_uuid")
+		userUUID, err := uuid.Parse(userUUIDstr)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, UnkownUserError)
+			ctx.Abort()
+			return
+		}
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Request.Method + " " + ctx.Request.URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := c.System.BeginIdempotentRequest(userUUID, key, requestHash)
+		switch {
+		case errors.Is(err, core.ErrIdempotencyConflict), errors.Is(err, core.ErrIdempotencyInProgress):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		case err != nil:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		case stored != nil:
+			ctx.Header(REPLAYED_HEADER, "true")
+			ctx.Data(stored.ResponseCode, "application/json; charset=utf-8", stored.ResponseBody)
+			ctx.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer, body: &bytes.Buffer{}}
+		ctx.Writer = recorder
+		finished := false
+		// a panic or a server error releases the key, so the request can be
+		// retried, a crash leaves it to expire after core.IDEMPOTENCY_TIMEOUT
+		defer func() {
+			if finished {
+				return
+			}
+			if err := c.System.ReleaseIdempotentRequest(userUUID, key); err != nil {
+				log.Printf("can't release idempotency key %v, err %v", key, err.Error())
+			}
+		}()
+		ctx.Next()
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		// the money may have moved already, so a key whose response can't be
+		// stored stays reserved until it expires
+		finished = true
+		err = c.System.FinishIdempotentRequest(userUUID, key, recorder.Status(), recorder.body.Bytes())
+		if err != nil {
+			log.Printf("can't store response of idempotency key %v, err %v", key, err.Error())
+		}
+	}
+}
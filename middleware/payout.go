@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"payment/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PAYOUT_SECRET_HEADER = "X-Payout-Secret"
+
+// CheckPayoutSecret lets through only the callbacks which carry the secret
+// shared with the payout gateway.
+func CheckPayoutSecret(c controllers.Controller) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		secret := ctx.GetHeader(PAYOUT_SECRET_HEADER)
+		if c.PayoutSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(c.PayoutSecret)) != 1 {
+			ctx.JSON(http.StatusUnauthorized, UnauthenticatedError)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
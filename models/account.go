@@ -5,9 +5,19 @@ import (
 )
 
 type Account struct {
-	UUID     uuid.UUID `json:"uuid"`
-	IBAN     string    `json:"iban"`
-	Balance  uint      `json:"balance"`
-	UserUUID uuid.UUID `json:"user_uuid"`
-	Status   string    `json:"status"`
+	UUID    uuid.UUID `json:"uuid"`
+	IBAN    string    `json:"iban"`
+	Balance Money     `json:"balance"`
+	// HeldAmount is reserved by the holds, the available balance is Balance
+	// without it.
+	HeldAmount Money `json:"held_amount"`
+	// PocketAmount is set aside in the pockets, the main pocket is Balance
+	// without it.
+	PocketAmount Money `json:"pocket_amount"`
+	// OverdraftLimit is how far below zero the balance may go.
+	OverdraftLimit Money     `json:"overdraft_limit"`
+	Currency       string    `json:"currency"`
+	Type           string    `json:"type"`
+	UserUUID       uuid.UUID `json:"user_uuid"`
+	Status         string    `json:"status"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeeSchedule sets the fee of the transfers from the accounts of the type in
+// the currency, an empty AccountType applies to all types.
+type FeeSchedule struct {
+	UUID        uuid.UUID `json:"uuid"`
+	AccountType string    `json:"account_type"`
+	Currency    string    `json:"currency"`
+	Kind        string    `json:"kind"`
+	Flat        Money     `json:"flat"`
+	BasisPoints uint      `json:"basis_points"`
+	Min         Money     `json:"min"`
+	Max         Money     `json:"max"`
+	Tiers       []FeeTier `json:"tiers"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FeeTier applies to the amounts up to UpTo, a zero UpTo has no upper bound.
+type FeeTier struct {
+	UpTo        Money `json:"up_to"`
+	Flat        Money `json:"flat"`
+	BasisPoints uint  `json:"basis_points"`
+}
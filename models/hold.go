@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Hold reserves the amount on the account until it is captured to the
+// destination, voided or expires; it may be captured in parts.
+type Hold struct {
+	UUID            uuid.UUID `json:"uuid"`
+	AccountUUID     uuid.UUID `json:"account_uuid"`
+	DestinationUUID uuid.UUID `json:"destination_uuid"`
+	Amount          Money     `json:"amount"`
+	Captured        Money     `json:"captured"`
+	Currency        string    `json:"currency"`
+	Description     string    `json:"description"`
+	Status          string    `json:"status"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
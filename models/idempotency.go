@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IdempotencyKey struct {
+	Key          string    `json:"key"`
+	UserUUID     uuid.UUID `json:"user_uuid"`
+	RequestHash  string    `json:"request_hash"`
+	ResponseCode int       `json:"response_code"`
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
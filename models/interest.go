@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InterestAccrual keeps the interest accrued on the account which is not paid
+// out yet and the overdraft interest which is not charged yet, in fractions of
+// the minor unit.
+type InterestAccrual struct {
+	AccountUUID uuid.UUID `json:"account_uuid"`
+	Accrued     Money     `json:"accrued"`
+	Overdraft   Money     `json:"overdraft"`
+	// OverdrawnDays counts the days below zero since the last payout.
+	OverdrawnDays uint `json:"overdrawn_days"`
+	// AccruedAt is the UTC day the interest is accrued up to.
+	AccruedAt time.Time `json:"accrued_at"`
+	PaidAt    time.Time `json:"paid_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LedgerEntry struct {
+	UUID            uuid.UUID `json:"uuid"`
+	JournalUUID     uuid.UUID `json:"journal_uuid"`
+	TransactionUUID uuid.UUID `json:"transaction_uuid"`
+	AccountUUID     uuid.UUID `json:"account_uuid"`
+	Kind            string    `json:"kind"`
+	Direction       string    `json:"direction"`
+	Amount          Money     `json:"amount"`
+	CreatedAt       time.Time `json:"created_at"`
+}
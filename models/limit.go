@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limit restricts outgoing transfers of the account, or of all accounts of
+// the user when AccountUUID is uuid.Nil. A zero amount means no limit.
+type Limit struct {
+	UUID        uuid.UUID `json:"uuid"`
+	UserUUID    uuid.UUID `json:"user_uuid"`
+	AccountUUID uuid.UUID `json:"account_uuid"`
+	Currency    string    `json:"currency"`
+	MaxTransfer Money     `json:"max_transfer"`
+	Daily       Money     `json:"daily"`
+	Monthly     Money     `json:"monthly"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountMember is a co-owner of the joint account besides the account
+// holder; the invited member gets access only after accepting the invite.
+type AccountMember struct {
+	AccountUUID uuid.UUID `json:"account_uuid"`
+	UserUUID    uuid.UUID `json:"user_uuid"`
+	Permission  string    `json:"permission"`
+	Status      string    `json:"status"`
+	InvitedBy   uuid.UUID `json:"invited_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_EXPONENT = 2
+	MAX_EXPONENT     = 18
+)
+
+var (
+	ErrMoneyOverflow  = errors.New("money overflow")
+	ErrMoneyFormat    = errors.New("wrong money format")
+	ErrMoneyPrecision = errors.New("too many decimal places for the currency")
+)
+
+// currencyExponents keeps the number of decimal places of the currencies
+// which differ from DEFAULT_EXPONENT.
+var currencyExponents = map[string]uint8{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"TND": 3,
+}
+
+func CurrencyExponent(currency string) uint8 {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return DEFAULT_EXPONENT
+}
+
+// Money is an amount in minor units, Exponent is the number of decimal places:
+// Money{Minor: 1234, Exponent: 2} is 12.34.
+type Money struct {
+	Minor    int64
+	Exponent uint8
+}
+
+func NewMoney(minor int64, exponent uint8) Money {
+	return Money{Minor: minor, Exponent: exponent}
+}
+
+// ParseMoney parses decimal strings like "12", "-0.5" or "12.34".
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && fraction == "") || len(fraction) > MAX_EXPONENT {
+		return Money{}, ErrMoneyFormat
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return Money{}, ErrMoneyFormat
+		}
+	}
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrMoneyOverflow
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Exponent: uint8(len(fraction))}, nil
+}
+
+func (m Money) String() string {
+	minor := strconv.FormatInt(m.Minor, 10)
+	sign := ""
+	if m.Minor < 0 {
+		sign = "-"
+		minor = minor[1:]
+	}
+	if m.Exponent == 0 {
+		return sign + minor
+	}
+	exponent := int(m.Exponent)
+	if len(minor) <= exponent {
+		minor = strings.Repeat("0", exponent-len(minor)+1) + minor
+	}
+	return sign + minor[:len(minor)-exponent] + "." + minor[len(minor)-exponent:]
+}
+
+func (m Money) big() *big.Int {
+	return big.NewInt(m.Minor)
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func fromBig(value *big.Int, exponent uint8) (Money, error) {
+	if !value.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Minor: value.Int64(), Exponent: exponent}, nil
+}
+
+// Rescale returns the same amount with the given number of decimal places.
+func (m Money) Rescale(exponent uint8) (Money, error) {
+	if exponent == m.Exponent {
+		return m, nil
+	}
+	if exponent > m.Exponent {
+		value := new(big.Int).Mul(m.big(), pow10(exponent-m.Exponent))
+		return fromBig(value, exponent)
+	}
+	quotient, remainder := new(big.Int).QuoRem(m.big(), pow10(m.Exponent-exponent), new(big.Int))
+	if remainder.Sign() != 0 {
+		return Money{}, ErrMoneyPrecision
+	}
+	return fromBig(quotient, exponent)
+}
+
+// ForCurrency rescales the amount to the exponent of the currency when it
+// doesn't lose precision.
+func (m Money) ForCurrency(currency string) Money {
+	rescaled, err := m.Rescale(CurrencyExponent(currency))
+	if err != nil {
+		return m
+	}
+	return rescaled
+}
+
+func maxExponent(a, b Money) uint8 {
+	if a.Exponent > b.Exponent {
+		return a.Exponent
+	}
+	return b.Exponent
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	exponent := maxExponent(m, other)
+	a, err := m.Rescale(exponent)
+	if err != nil {
+		return Money{}, err
+	}
+	b, err := other.Rescale(exponent)
+	if err != nil {
+		return Money{}, err
+	}
+	if (b.Minor > 0 && a.Minor > math.MaxInt64-b.Minor) || (b.Minor < 0 && a.Minor < math.MinInt64-b.Minor) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Minor: a.Minor + b.Minor, Exponent: exponent}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Minor == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(other.Neg())
+}
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Exponent: m.Exponent}
+}
+
+// Cmp returns -1, 0 or +1 when m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) int {
+	exponent := maxExponent(m, other)
+	a := new(big.Int).Mul(m.big(), pow10(exponent-m.Exponent))
+	b := new(big.Int).Mul(other.big(), pow10(exponent-other.Exponent))
+	return a.Cmp(b)
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Minor < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		s = string(data)
+	}
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// Value stores the amount as a decimal string, so it fits numeric columns.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch value := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	case int64:
+		*m = Money{Minor: value}
+		return nil
+	case float64:
+		s = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Errorf("can't scan %T into Money", src)
+	}
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentRequest asks the payer for the amount to the account of the
+// requester; the payer pays it from an own account by accepting it, or the
+// account is fixed when the request was made to its IBAN.
+type PaymentRequest struct {
+	UUID             uuid.UUID  `json:"uuid"`
+	RequesterUUID    uuid.UUID  `json:"requester_uuid"`
+	AccountUUID      uuid.UUID  `json:"account_uuid"`
+	PayerUUID        uuid.UUID  `json:"payer_uuid"`
+	PayerAccountUUID uuid.UUID  `json:"payer_account_uuid"`
+	Amount           Money      `json:"amount"`
+	Currency         string     `json:"currency"`
+	Description      string     `json:"description"`
+	Status           string     `json:"status"`
+	Reason           string     `json:"reason"`
+	TransactionUUID  uuid.UUID  `json:"transaction_uuid"`
+	SplitUUID        uuid.UUID  `json:"split_uuid"`
+	Reminders        uint       `json:"reminders"`
+	RemindedAt       *time.Time `json:"reminded_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pocket sets money aside inside the account without a separate IBAN; its
+// balance is a part of the account balance which the main pocket can't spend.
+type Pocket struct {
+	UUID        uuid.UUID `json:"uuid"`
+	AccountUUID uuid.UUID `json:"account_uuid"`
+	Name        string    `json:"name"`
+	Balance     Money     `json:"balance"`
+	Currency    string    `json:"currency"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Split shares the sent transaction of the account with other users, every
+// share is a PaymentRequest of the split paid back to the account.
+type Split struct {
+	UUID            uuid.UUID `json:"uuid"`
+	UserUUID        uuid.UUID `json:"user_uuid"`
+	AccountUUID     uuid.UUID `json:"account_uuid"`
+	TransactionUUID uuid.UUID `json:"transaction_uuid"`
+	Mode            string    `json:"mode"`
+	Amount          Money     `json:"amount"`
+	OwnShare        Money     `json:"own_share"`
+	Currency        string    `json:"currency"`
+	Description     string    `json:"description"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StandingOrder struct {
+	UUID            uuid.UUID  `json:"uuid"`
+	UserUUID        uuid.UUID  `json:"user_uuid"`
+	SourceUUID      uuid.UUID  `json:"source_uuid"`
+	DestinationUUID uuid.UUID  `json:"destination_uuid"`
+	Amount          Money      `json:"amount"`
+	Interval        string     `json:"interval"`
+	StartAt         time.Time  `json:"start_at"`
+	EndAt           *time.Time `json:"end_at,omitempty"`
+	MaxRuns         uint       `json:"max_runs"`
+	Runs            uint       `json:"runs"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type StandingOrderRun struct {
+	UUID            uuid.UUID `json:"uuid"`
+	OrderUUID       uuid.UUID `json:"order_uuid"`
+	TransactionUUID uuid.UUID `json:"transaction_uuid"`
+	Status          string    `json:"status"`
+	Error           string    `json:"error"`
+	CreatedAt       time.Time `json:"created_at"`
+}
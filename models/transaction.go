@@ -7,11 +7,32 @@ import (
 )
 
 type Transaction struct {
+	UUID                uuid.UUID  `json:"uuid"`
+	Status              string     `json:"status"`
+	SourceUUID          uuid.UUID  `json:"source_uuid"`
+	DestinationUUID     uuid.UUID  `json:"destination_uuid"`
+	SourceIBAN          string     `json:"source_iban"`
+	DestinationIBAN     string     `json:"destination_iban"`
+	Amount              Money      `json:"amount"`
+	Fee                 Money      `json:"fee"`
+	Currency            string     `json:"currency"`
+	DestinationAmount   Money      `json:"destination_amount"`
+	DestinationCurrency string     `json:"destination_currency"`
+	Rate                float64    `json:"rate"`
+	OriginalUUID        uuid.UUID  `json:"original_uuid"`
+	PocketUUID          uuid.UUID  `json:"pocket_uuid"`
+	UserUUID            uuid.UUID  `json:"user_uuid"`
+	ExecuteAt           *time.Time `json:"execute_at,omitempty"`
+	Attempts            uint       `json:"attempts"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type TransactionStatusChange struct {
 	UUID            uuid.UUID `json:"uuid"`
-	Status          string    `json:"status"`
-	SourceUUID      uuid.UUID `json:"source_uuid"`
-	DestinationUUID uuid.UUID `json:"destination_uuid"`
-	Amount          uint      `json:"amount"`
+	TransactionUUID uuid.UUID `json:"transaction_uuid"`
+	From            string    `json:"from"`
+	To              string    `json:"to"`
+	Reason          string    `json:"reason"`
 	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"payment/models"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,25 +24,201 @@ type GormUser struct {
 }
 
 type GormAccount struct {
-	UUID         uuid.UUID `json:"uuid" gorm:"primary_key;type:uuid"`
-	IBAN         string    `json:"iban" gorm:"size:250;not null;unique"`
-	Balance      uint      `json:"balance" gorm:"not null"`
-	UserUUID     uuid.UUID
-	Status       string
-	Sources      []GormTransaction `gorm:"foreignKey:SourceUUID"`
-	Destinations []GormTransaction `gorm:"foreignKey:DestinationUUID"`
+	UUID           uuid.UUID    `json:"uuid" gorm:"primary_key;type:uuid"`
+	IBAN           string       `json:"iban" gorm:"size:250;not null;unique"`
+	Balance        models.Money `json:"balance" gorm:"type:numeric;not null;default:0"`
+	HeldAmount     models.Money `json:"held_amount" gorm:"type:numeric;not null;default:0"`
+	PocketAmount   models.Money `json:"pocket_amount" gorm:"type:numeric;not null;default:0"`
+	OverdraftLimit models.Money `json:"overdraft_limit" gorm:"type:numeric;not null;default:0"`
+	Currency       string       `json:"currency" gorm:"size:3;not null;default:UAH"`
+	Type           string       `json:"type" gorm:"size:50;not null;default:personal"`
+	UserUUID       uuid.UUID
+	Status         string
+	Sources        []GormTransaction `gorm:"foreignKey:SourceUUID"`
+	Destinations   []GormTransaction `gorm:"foreignKey:DestinationUUID"`
 }
 
 type GormTransaction struct {
-	UUID            uuid.UUID `json:"uuid" gorm:"primary_key;type:uuid"`
-	Status          string    `json:"status" gorm:"size:50;not null"`
-	SourceUUID      uuid.UUID `gorm:"type:uuid;not null"`
-	DestinationUUID uuid.UUID `gorm:"type:uuid;not null"`
-	Amount          uint      `gorm:"not null"`
+	UUID                uuid.UUID    `json:"uuid" gorm:"primary_key;type:uuid"`
+	Status              string       `json:"status" gorm:"size:50;not null"`
+	SourceUUID          uuid.UUID    `gorm:"type:uuid;not null"`
+	DestinationUUID     uuid.UUID    `gorm:"type:uuid;not null"`
+	SourceIBAN          string       `gorm:"size:250"`
+	DestinationIBAN     string       `gorm:"size:250"`
+	Amount              models.Money `gorm:"type:numeric;not null"`
+	Fee                 models.Money `gorm:"type:numeric;not null;default:0"`
+	Currency            string       `gorm:"size:3"`
+	DestinationAmount   models.Money `gorm:"type:numeric"`
+	DestinationCurrency string       `gorm:"size:3"`
+	Rate                float64
+	OriginalUUID        uuid.UUID  `gorm:"type:uuid;index"`
+	PocketUUID          uuid.UUID  `gorm:"type:uuid"`
+	UserUUID            uuid.UUID  `gorm:"type:uuid"`
+	ExecuteAt           *time.Time `gorm:"index"`
+	Attempts            uint       `gorm:"not null;default:0"`
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type GormTransactionStatusChange struct {
+	UUID            uuid.UUID `gorm:"primary_key;type:uuid"`
+	TransactionUUID uuid.UUID `gorm:"type:uuid;not null;index"`
+	From            string    `gorm:"size:50"`
+	To              string    `gorm:"size:50;not null"`
+	Reason          string    `gorm:"size:250"`
+	CreatedAt       time.Time
+}
+
+type GormHold struct {
+	UUID            uuid.UUID    `gorm:"primary_key;type:uuid"`
+	AccountUUID     uuid.UUID    `gorm:"type:uuid;not null;index"`
+	DestinationUUID uuid.UUID    `gorm:"type:uuid;not null"`
+	Amount          models.Money `gorm:"type:numeric;not null"`
+	Captured        models.Money `gorm:"type:numeric;not null;default:0"`
+	Currency        string       `gorm:"size:3"`
+	Description     string       `gorm:"size:250"`
+	Status          string       `gorm:"size:50;not null"`
+	ExpiresAt       time.Time    `gorm:"index"`
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
 
+type GormInterestAccrual struct {
+	AccountUUID   uuid.UUID    `gorm:"primary_key;type:uuid"`
+	Accrued       models.Money `gorm:"type:numeric;not null;default:0"`
+	Overdraft     models.Money `gorm:"type:numeric;not null;default:0"`
+	OverdrawnDays uint         `gorm:"not null;default:0"`
+	AccruedAt     time.Time    `gorm:"not null"`
+	PaidAt        time.Time    `gorm:"not null"`
+	UpdatedAt     time.Time
+}
+
+type GormPocket struct {
+	UUID        uuid.UUID    `gorm:"primary_key;type:uuid"`
+	AccountUUID uuid.UUID    `gorm:"type:uuid;not null;index"`
+	Name        string       `gorm:"size:100;not null"`
+	Balance     models.Money `gorm:"type:numeric;not null;default:0"`
+	Currency    string       `gorm:"size:3"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type GormPaymentRequest struct {
+	UUID             uuid.UUID    `gorm:"primary_key;type:uuid"`
+	RequesterUUID    uuid.UUID    `gorm:"type:uuid;not null"`
+	AccountUUID      uuid.UUID    `gorm:"type:uuid;not null;index"`
+	PayerUUID        uuid.UUID    `gorm:"type:uuid;not null;index"`
+	PayerAccountUUID uuid.UUID    `gorm:"type:uuid"`
+	Amount           models.Money `gorm:"type:numeric;not null"`
+	Currency         string       `gorm:"size:3"`
+	Description      string       `gorm:"size:250"`
+	Status           string       `gorm:"size:50;not null"`
+	Reason           string       `gorm:"size:250"`
+	TransactionUUID  uuid.UUID    `gorm:"type:uuid;index"`
+	SplitUUID        uuid.UUID    `gorm:"type:uuid;index"`
+	Reminders        uint         `gorm:"not null;default:0"`
+	RemindedAt       *time.Time
+	ExpiresAt        time.Time `gorm:"index"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type GormSplit struct {
+	UUID            uuid.UUID    `gorm:"primary_key;type:uuid"`
+	UserUUID        uuid.UUID    `gorm:"type:uuid;not null"`
+	AccountUUID     uuid.UUID    `gorm:"type:uuid;not null;index"`
+	TransactionUUID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex"`
+	Mode            string       `gorm:"size:50;not null"`
+	Amount          models.Money `gorm:"type:numeric;not null"`
+	OwnShare        models.Money `gorm:"type:numeric;not null"`
+	Currency        string       `gorm:"size:3"`
+	Description     string       `gorm:"size:250"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type GormAccountMember struct {
+	AccountUUID uuid.UUID `gorm:"primary_key;type:uuid"`
+	UserUUID    uuid.UUID `gorm:"primary_key;type:uuid;index"`
+	Permission  string    `gorm:"size:50;not null"`
+	Status      string    `gorm:"size:50;not null"`
+	InvitedBy   uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type GormFeeSchedule struct {
+	UUID        uuid.UUID        `gorm:"primary_key;type:uuid"`
+	AccountType string           `gorm:"size:50;not null;uniqueIndex:idx_fee_schedule"`
+	Currency    string           `gorm:"size:3;not null;uniqueIndex:idx_fee_schedule"`
+	Kind        string           `gorm:"size:50;not null"`
+	Flat        models.Money     `gorm:"type:numeric;not null;default:0"`
+	BasisPoints uint             `gorm:"not null;default:0"`
+	Min         models.Money     `gorm:"type:numeric;not null;default:0"`
+	Max         models.Money     `gorm:"type:numeric;not null;default:0"`
+	Tiers       []models.FeeTier `gorm:"serializer:json"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type GormLimit struct {
+	UUID        uuid.UUID    `gorm:"primary_key;type:uuid"`
+	UserUUID    uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_limit_owner"`
+	AccountUUID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_limit_owner"`
+	Currency    string       `gorm:"size:3;not null"`
+	MaxTransfer models.Money `gorm:"type:numeric;not null;default:0"`
+	Daily       models.Money `gorm:"type:numeric;not null;default:0"`
+	Monthly     models.Money `gorm:"type:numeric;not null;default:0"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type GormStandingOrder struct {
+	UUID            uuid.UUID    `gorm:"primary_key;type:uuid"`
+	UserUUID        uuid.UUID    `gorm:"type:uuid;not null"`
+	SourceUUID      uuid.UUID    `gorm:"type:uuid;not null;index"`
+	DestinationUUID uuid.UUID    `gorm:"type:uuid;not null"`
+	Amount          models.Money `gorm:"type:numeric;not null"`
+	Interval        string       `gorm:"size:50;not null"`
+	StartAt         time.Time    `gorm:"not null"`
+	EndAt           *time.Time
+	MaxRuns         uint
+	Runs            uint
+	NextRunAt       time.Time `gorm:"not null;index"`
+	Status          string    `gorm:"size:50;not null"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type GormStandingOrderRun struct {
+	UUID            uuid.UUID `gorm:"primary_key;type:uuid"`
+	OrderUUID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	TransactionUUID uuid.UUID `gorm:"type:uuid"`
+	Status          string    `gorm:"size:50;not null"`
+	Error           string    `gorm:"size:250"`
+	CreatedAt       time.Time
+}
+
+type GormIdempotencyKey struct {
+	Key          string    `gorm:"primary_key;size:255"`
+	UserUUID     uuid.UUID `gorm:"primary_key;type:uuid"`
+	RequestHash  string    `gorm:"size:64;not null"`
+	ResponseCode int
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+type GormLedgerEntry struct {
+	UUID            uuid.UUID    `gorm:"primary_key;type:uuid"`
+	JournalUUID     uuid.UUID    `gorm:"type:uuid;not null;index"`
+	TransactionUUID uuid.UUID    `gorm:"type:uuid"`
+	AccountUUID     uuid.UUID    `gorm:"type:uuid;not null;index"`
+	Kind            string       `gorm:"size:50;not null"`
+	Direction       string       `gorm:"size:10;not null"`
+	Amount          models.Money `gorm:"type:numeric;not null"`
+	CreatedAt       time.Time
+}
+
 func ConnectDataBase() *gorm.DB {
 
 	var DB *gorm.DB
@@ -88,12 +265,25 @@ func ConnectDataBase() *gorm.DB {
 		log.Println("We are connected to the database ", Dbdriver)
 	}
 
-	DB.AutoMigrate(&GormUser{}, &GormAccount{}, &GormTransaction{})
+	DB.AutoMigrate(&GormUser{}, &GormAccount{}, &GormTransaction{}, &GormTransactionStatusChange{}, &GormStandingOrder{}, &GormStandingOrderRun{}, &GormIdempotencyKey{}, &GormLedgerEntry{}, &GormLimit{}, &GormFeeSchedule{}, &GormInterestAccrual{}, &GormHold{}, &GormAccountMember{}, &GormPocket{}, &GormPaymentRequest{}, &GormSplit{})
 	return DB
 
 }
 
 func ClearData(db *gorm.DB) {
+	db.Where("1 = 1").Delete(&GormIdempotencyKey{})
+	db.Where("1 = 1").Delete(&GormLedgerEntry{})
+	db.Where("1 = 1").Delete(&GormLimit{})
+	db.Where("1 = 1").Delete(&GormFeeSchedule{})
+	db.Where("1 = 1").Delete(&GormInterestAccrual{})
+	db.Where("1 = 1").Delete(&GormHold{})
+	db.Where("1 = 1").Delete(&GormAccountMember{})
+	db.Where("1 = 1").Delete(&GormPocket{})
+	db.Where("1 = 1").Delete(&GormPaymentRequest{})
+	db.Where("1 = 1").Delete(&GormSplit{})
+	db.Where("1 = 1").Delete(&GormStandingOrderRun{})
+	db.Where("1 = 1").Delete(&GormStandingOrder{})
+	db.Where("1 = 1").Delete(&GormTransactionStatusChange{})
 	db.Where("1 = 1").Delete(&GormTransaction{})
 	db.Where("1 = 1").Delete(&GormAccount{})
 	db.Where("1 = 1").Delete(&GormUser{})
@@ -1,10 +1,24 @@
 package repository
 
 import (
+	"errors"
+	"math"
 	"payment/models"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	UNIQUE_VIOLATION      = "23505"
+	SERIALIZATION_FAILURE = "40001"
+	DEADLOCK_DETECTED     = "40P01"
+
+	MAX_TRANSACTION_ATTEMPTS = 3
 )
 
 type Repository interface {
@@ -15,10 +29,14 @@ type Repository interface {
 	CreateTransaction(transaction models.Transaction) error
 	GetAccountsForUser(userUUID uuid.UUID, query models.QueryParams) ([]models.Account, error)
 	GetAccountByUUID(uuid uuid.UUID) (*models.Account, error)
+	GetAccountForUpdate(accountUUID uuid.UUID) (*models.Account, error)
+	GetAccountByIBAN(iban string) (*models.Account, error)
+	GetAccounts() ([]models.Account, error)
+	UpdateAccountIBAN(accountUUID uuid.UUID, iban string) error
 	GetTransactionForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Transaction, error)
 	GetTransactionByUUID(transactionUUID uuid.UUID) (*models.Transaction, error)
-	IncBalance(accountUUID uuid.UUID, amount uint) error
-	DecBalance(accountUUID uuid.UUID, amount uint) error
+	IncBalance(accountUUID uuid.UUID, amount models.Money) error
+	DecBalance(accountUUID uuid.UUID, amount models.Money) error
 	UpdateStatusTransaction(transactionUUID uuid.UUID, status string) error
 	Transaction(callback func(repo Repository) error) error
 	UpdateRole(userUUID uuid.UUID, role string) error
@@ -26,33 +44,180 @@ type Repository interface {
 	UpdateStatusAccount(accountUUID uuid.UUID, status string) error
 	UpdateStatusUser(userUUID uuid.UUID, status string) error
 	GetAccountsByStatus(status string, query models.QueryParams) ([]models.Account, error)
+	CreateIdempotencyKey(key *models.IdempotencyKey) error
+	GetIdempotencyKey(userUUID uuid.UUID, key string) (*models.IdempotencyKey, error)
+	UpdateIdempotencyKey(userUUID uuid.UUID, key string, code int, body []byte) error
+	DeleteIdempotencyKey(userUUID uuid.UUID, key string, createdBefore time.Time) error
+	CreateLedgerEntries(entries []models.LedgerEntry) error
+	GetLedgerEntries(accountUUID uuid.UUID, query models.QueryParams) ([]models.LedgerEntry, error)
+	GetLedgerTotals(accountUUID uuid.UUID) (models.Money, models.Money, error)
+	CountLedgerEntries(kind string) (int64, error)
+	GetRefunds(originalUUID uuid.UUID) ([]models.Transaction, error)
+	GetTransactionsByStatus(status string, createdBefore time.Time) ([]models.Transaction, error)
+	GetTransactionsForStatus(status string, query models.QueryParams) ([]models.Transaction, error)
+	CreateStatusChange(change models.TransactionStatusChange) error
+	GetStatusHistory(transactionUUID uuid.UUID) ([]models.TransactionStatusChange, error)
+	GetDueTransactions(now time.Time) ([]models.Transaction, error)
+	UpdateScheduleTransaction(transactionUUID uuid.UUID, attempts uint, executeAt time.Time) error
+	CreateStandingOrder(order models.StandingOrder) error
+	GetStandingOrderByUUID(orderUUID uuid.UUID) (*models.StandingOrder, error)
+	GetStandingOrderForUpdate(orderUUID uuid.UUID) (*models.StandingOrder, error)
+	GetStandingOrdersForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrder, error)
+	GetDueStandingOrders(now time.Time) ([]models.StandingOrder, error)
+	UpdateStandingOrder(order models.StandingOrder) error
+	CreateStandingOrderRun(run models.StandingOrderRun) error
+	GetStandingOrderRuns(orderUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrderRun, error)
+	CreateLimit(limit models.Limit) error
+	UpdateLimit(limit models.Limit) error
+	GetLimit(userUUID, accountUUID uuid.UUID) (*models.Limit, error)
+	GetLimits(userUUID uuid.UUID) ([]models.Limit, error)
+	DeleteLimit(limitUUID uuid.UUID) error
+	GetSentTransactionsForUser(userUUID uuid.UUID, since time.Time) ([]models.Transaction, error)
+	GetTransactionsForUserInStatus(userUUID uuid.UUID, status string) ([]models.Transaction, error)
+	CreateFeeSchedule(schedule models.FeeSchedule) error
+	UpdateFeeSchedule(schedule models.FeeSchedule) error
+	GetFeeSchedule(accountType, currency string) (*models.FeeSchedule, error)
+	GetFeeSchedules() ([]models.FeeSchedule, error)
+	DeleteFeeSchedule(scheduleUUID uuid.UUID) error
+	GetAccountsByType(accountType string) ([]models.Account, error)
+	UpdateOverdraftLimit(accountUUID uuid.UUID, limit models.Money) error
+	GetInterestAccrual(accountUUID uuid.UUID) (*models.InterestAccrual, error)
+	SaveInterestAccrual(accrual models.InterestAccrual) error
+	IncHeld(accountUUID uuid.UUID, amount models.Money) error
+	DecHeld(accountUUID uuid.UUID, amount models.Money) error
+	CreateHold(hold models.Hold) error
+	GetHoldByUUID(holdUUID uuid.UUID) (*models.Hold, error)
+	GetHoldsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Hold, error)
+	GetExpiredHolds(now time.Time) ([]models.Hold, error)
+	UpdateHold(hold models.Hold) error
+	CreateMember(member models.AccountMember) error
+	GetMember(accountUUID, userUUID uuid.UUID) (*models.AccountMember, error)
+	GetMembers(accountUUID uuid.UUID) ([]models.AccountMember, error)
+	GetMembershipsForUser(userUUID uuid.UUID) ([]models.AccountMember, error)
+	UpdateMember(member models.AccountMember) error
+	DeleteMember(accountUUID, userUUID uuid.UUID) error
+	CountTransactionsFromAccount(accountUUID uuid.UUID, statuses []string) (int64, error)
+	CancelStandingOrdersForAccount(accountUUID uuid.UUID) error
+	IncPocketAmount(accountUUID uuid.UUID, amount models.Money) error
+	DecPocketAmount(accountUUID uuid.UUID, amount models.Money) error
+	CreatePocket(pocket models.Pocket) error
+	GetPocketByUUID(pocketUUID uuid.UUID) (*models.Pocket, error)
+	GetPocketsForAccount(accountUUID uuid.UUID) ([]models.Pocket, error)
+	IncPocketBalance(pocketUUID uuid.UUID, amount models.Money) error
+	DecPocketBalance(pocketUUID uuid.UUID, amount models.Money) error
+	DeletePocket(pocketUUID uuid.UUID) error
+	CreatePaymentRequest(request models.PaymentRequest) error
+	GetPaymentRequestByUUID(requestUUID uuid.UUID) (*models.PaymentRequest, error)
+	GetPaymentRequestForTransaction(transactionUUID uuid.UUID) (*models.PaymentRequest, error)
+	GetPaymentRequestsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error)
+	GetPaymentRequestsForPayer(payerUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error)
+	GetExpiredPaymentRequests(now time.Time) ([]models.PaymentRequest, error)
+	UpdatePaymentRequest(request models.PaymentRequest) error
+	GetPaymentRequestsForSplit(splitUUID uuid.UUID) ([]models.PaymentRequest, error)
+	CreateSplit(split models.Split) error
+	GetSplitByUUID(splitUUID uuid.UUID) (*models.Split, error)
+	GetSplitsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Split, error)
 }
 
 type PostgresRepo struct {
 	DB *gorm.DB
 }
 
+// Transaction runs the callback in a database transaction and runs it again
+// when the database aborts the transaction because of a serialization failure
+// or a deadlock.
 func (p *PostgresRepo) Transaction(callback func(repo Repository) error) error {
-	return p.DB.Transaction(func(tx *gorm.DB) error {
-		repo := PostgresRepo{
-			DB: tx,
+	var err error
+	for attempt := 0; attempt < MAX_TRANSACTION_ATTEMPTS; attempt++ {
+		err = p.DB.Transaction(func(tx *gorm.DB) error {
+			repo := PostgresRepo{
+				DB: tx,
+			}
+			return callback(&repo)
+		})
+		if !retryable(err) {
+			return err
 		}
-		return callback(&repo)
-	})
+	}
+	return err
+}
+
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == SERIALIZATION_FAILURE || pgErr.Code == DEADLOCK_DETECTED)
+}
+
+// IncBalance adds the amount to the balance unless the result overflows models.Money.
+func (p *PostgresRepo) IncBalance(accountUUID uuid.UUID, amount models.Money) error {
+	limit, err := models.NewMoney(math.MaxInt64, amount.Exponent).Sub(amount)
+	if err != nil {
+		return models.ErrMoneyOverflow
+	}
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ? AND Balance <= ?", accountUUID, limit).Update("Balance", gorm.Expr("Balance + ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
+}
+
+// DecBalance subtracts the amount from the balance unless the result overflows models.Money.
+func (p *PostgresRepo) DecBalance(accountUUID uuid.UUID, amount models.Money) error {
+	limit, err := models.NewMoney(math.MinInt64, amount.Exponent).Add(amount)
+	if err != nil {
+		return models.ErrMoneyOverflow
+	}
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ? AND Balance >= ?", accountUUID, limit).Update("Balance", gorm.Expr("Balance - ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
+}
+
+// IncHeld reserves the amount of the account balance.
+func (p *PostgresRepo) IncHeld(accountUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Held_Amount", gorm.Expr("Held_Amount + ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
+}
+
+// IncPocketAmount sets the amount of the account balance aside in the pockets.
+func (p *PostgresRepo) IncPocketAmount(accountUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Pocket_Amount", gorm.Expr("Pocket_Amount + ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
 }
 
-func (p *PostgresRepo) IncBalance(accountUUID uuid.UUID, amount uint) error {
-	return p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Balance", gorm.Expr("Balance + ?", amount)).Error
+// DecPocketAmount returns the amount of the pockets to the main pocket.
+func (p *PostgresRepo) DecPocketAmount(accountUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ? AND Pocket_Amount >= ?", accountUUID, amount).Update("Pocket_Amount", gorm.Expr("Pocket_Amount - ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
 }
 
-func (p *PostgresRepo) DecBalance(accountUUID uuid.UUID, amount uint) error {
-	return p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Balance", gorm.Expr("Balance - ?", amount)).Error
+// DecHeld releases the reserved amount of the account balance.
+func (p *PostgresRepo) DecHeld(accountUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ? AND Held_Amount >= ?", accountUUID, amount).Update("Held_Amount", gorm.Expr("Held_Amount - ?", amount))
+	return p.checkBalanceUpdate(accountUUID, result)
+}
+
+func (p *PostgresRepo) checkBalanceUpdate(accountUUID uuid.UUID, result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 0 {
+		return nil
+	}
+	var count int64
+	err := p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrorUnknownAccount
+	}
+	return models.ErrMoneyOverflow
 }
 
 func (p *PostgresRepo) UpdateStatusTransaction(transactionUUID uuid.UUID, status string) error {
 	return p.DB.Model(&GormTransaction{}).Where("UUID = ?", transactionUUID).Update("Status", status).Error
 }
 
+func (p *PostgresRepo) UpdateOverdraftLimit(accountUUID uuid.UUID, limit models.Money) error {
+	result := p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Overdraft_Limit", limit)
+	return p.checkBalanceUpdate(accountUUID, result)
+}
+
 func (p *PostgresRepo) UpdateStatusAccount(accountUUID uuid.UUID, status string) error {
 	return p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("Status", status).Error
 }
@@ -75,24 +240,48 @@ func (p *PostgresRepo) GetTransactionByUUID(transactionUUID uuid.UUID) (*models.
 		return &models.Transaction{}, err
 	}
 	transaction := models.Transaction{
-		UUID:            gormTransaction.UUID,
-		Status:          gormTransaction.Status,
-		SourceUUID:      gormTransaction.SourceUUID,
-		DestinationUUID: gormTransaction.DestinationUUID,
-		Amount:          gormTransaction.Amount,
-		CreatedAt:       gormTransaction.CreatedAt,
-		UpdatedAt:       gormTransaction.UpdatedAt,
+		UUID:                gormTransaction.UUID,
+		Status:              gormTransaction.Status,
+		SourceUUID:          gormTransaction.SourceUUID,
+		DestinationUUID:     gormTransaction.DestinationUUID,
+		SourceIBAN:          gormTransaction.SourceIBAN,
+		DestinationIBAN:     gormTransaction.DestinationIBAN,
+		Amount:              gormTransaction.Amount.ForCurrency(gormTransaction.Currency),
+		Fee:                 gormTransaction.Fee.ForCurrency(gormTransaction.Currency),
+		Currency:            gormTransaction.Currency,
+		DestinationAmount:   gormTransaction.DestinationAmount.ForCurrency(gormTransaction.DestinationCurrency),
+		DestinationCurrency: gormTransaction.DestinationCurrency,
+		Rate:                gormTransaction.Rate,
+		OriginalUUID:        gormTransaction.OriginalUUID,
+		PocketUUID:          gormTransaction.PocketUUID,
+		UserUUID:            gormTransaction.UserUUID,
+		ExecuteAt:           gormTransaction.ExecuteAt,
+		Attempts:            gormTransaction.Attempts,
+		CreatedAt:           gormTransaction.CreatedAt,
+		UpdatedAt:           gormTransaction.UpdatedAt,
 	}
 	return &transaction, nil
 }
 
 func (p *PostgresRepo) CreateTransaction(transaction models.Transaction) error {
 	gormTransaction := GormTransaction{
-		UUID:            transaction.UUID,
-		Status:          transaction.Status,
-		SourceUUID:      transaction.SourceUUID,
-		DestinationUUID: transaction.DestinationUUID,
-		Amount:          transaction.Amount,
+		UUID:                transaction.UUID,
+		Status:              transaction.Status,
+		SourceUUID:          transaction.SourceUUID,
+		DestinationUUID:     transaction.DestinationUUID,
+		SourceIBAN:          transaction.SourceIBAN,
+		DestinationIBAN:     transaction.DestinationIBAN,
+		Amount:              transaction.Amount,
+		Fee:                 transaction.Fee,
+		Currency:            transaction.Currency,
+		DestinationAmount:   transaction.DestinationAmount,
+		DestinationCurrency: transaction.DestinationCurrency,
+		Rate:                transaction.Rate,
+		OriginalUUID:        transaction.OriginalUUID,
+		PocketUUID:          transaction.PocketUUID,
+		UserUUID:            transaction.UserUUID,
+		ExecuteAt:           transaction.ExecuteAt,
+		Attempts:            transaction.Attempts,
 	}
 	err := p.DB.Create(&gormTransaction).Error
 	if err != nil {
@@ -111,15 +300,91 @@ func (p *PostgresRepo) GetTransactionForAccount(accountUUID uuid.UUID, query mod
 	return modelTransaction, nil
 }
 
+func (p *PostgresRepo) GetRefunds(originalUUID uuid.UUID) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	result := p.DB.Model(GormTransaction{}).Where("Original_UUID = ?", originalUUID).Order("created_at").Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+func (p *PostgresRepo) GetTransactionsByStatus(status string, createdBefore time.Time) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	result := p.DB.Model(GormTransaction{}).Where("Status = ? AND Created_At < ?", status, createdBefore).Order("created_at").Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+func (p *PostgresRepo) GetDueTransactions(now time.Time) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	result := p.DB.Model(GormTransaction{}).Where("Status = ? AND Execute_At <= ?", "scheduled", now).Order("execute_at").Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+func (p *PostgresRepo) GetTransactionsForStatus(status string, query models.QueryParams) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	result := p.DB.Model(GormTransaction{}).Where("Status = ?", status).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+func (p *PostgresRepo) UpdateScheduleTransaction(transactionUUID uuid.UUID, attempts uint, executeAt time.Time) error {
+	return p.DB.Model(&GormTransaction{}).Where("UUID = ?", transactionUUID).Updates(map[string]interface{}{"Attempts": attempts, "Execute_At": executeAt}).Error
+}
+
+func (p *PostgresRepo) CreateStatusChange(change models.TransactionStatusChange) error {
+	gormChange := GormTransactionStatusChange{
+		UUID:            change.UUID,
+		TransactionUUID: change.TransactionUUID,
+		From:            change.From,
+		To:              change.To,
+		Reason:          change.Reason,
+	}
+	return p.DB.Create(&gormChange).Error
+}
+
+func (p *PostgresRepo) GetStatusHistory(transactionUUID uuid.UUID) ([]models.TransactionStatusChange, error) {
+	var gormChanges []GormTransactionStatusChange
+	result := p.DB.Model(GormTransactionStatusChange{}).Where("Transaction_UUID = ?", transactionUUID).Order("created_at").Find(&gormChanges)
+	if result.Error != nil {
+		return []models.TransactionStatusChange{}, result.Error
+	}
+	changes := make([]models.TransactionStatusChange, len(gormChanges))
+	for i, change := range gormChanges {
+		changes[i] = models.TransactionStatusChange{
+			UUID:            change.UUID,
+			TransactionUUID: change.TransactionUUID,
+			From:            change.From,
+			To:              change.To,
+			Reason:          change.Reason,
+			CreatedAt:       change.CreatedAt,
+		}
+	}
+	return changes, nil
+}
+
 func (p *PostgresRepo) fromGormToModelAccount(accounts []GormAccount) []models.Account {
 	modelAccounts := make([]models.Account, len(accounts))
 	for i, acc := range accounts {
 		modelAccounts[i] = models.Account{
-			UUID:     acc.UUID,
-			IBAN:     acc.IBAN,
-			Balance:  acc.Balance,
-			UserUUID: acc.UserUUID,
-			Status:   acc.Status,
+			UUID:           acc.UUID,
+			IBAN:           acc.IBAN,
+			Balance:        acc.Balance.ForCurrency(acc.Currency),
+			HeldAmount:     acc.HeldAmount.ForCurrency(acc.Currency),
+			PocketAmount:   acc.PocketAmount.ForCurrency(acc.Currency),
+			OverdraftLimit: acc.OverdraftLimit.ForCurrency(acc.Currency),
+			Currency:       acc.Currency,
+			Type:           acc.Type,
+			UserUUID:       acc.UserUUID,
+			Status:         acc.Status,
 		}
 	}
 
@@ -130,13 +395,25 @@ func (p *PostgresRepo) fromGormToModelTransaction(transactions []GormTransaction
 	modelTransaction := make([]models.Transaction, len(transactions))
 	for i, tr := range transactions {
 		modelTransaction[i] = models.Transaction{
-			UUID:            tr.UUID,
-			Status:          tr.Status,
-			SourceUUID:      tr.SourceUUID,
-			DestinationUUID: tr.DestinationUUID,
-			Amount:          tr.Amount,
-			CreatedAt:       tr.CreatedAt,
-			UpdatedAt:       tr.UpdatedAt,
+			UUID:                tr.UUID,
+			Status:              tr.Status,
+			SourceUUID:          tr.SourceUUID,
+			DestinationUUID:     tr.DestinationUUID,
+			SourceIBAN:          tr.SourceIBAN,
+			DestinationIBAN:     tr.DestinationIBAN,
+			Amount:              tr.Amount.ForCurrency(tr.Currency),
+			Fee:                 tr.Fee.ForCurrency(tr.Currency),
+			Currency:            tr.Currency,
+			DestinationAmount:   tr.DestinationAmount.ForCurrency(tr.DestinationCurrency),
+			DestinationCurrency: tr.DestinationCurrency,
+			Rate:                tr.Rate,
+			OriginalUUID:        tr.OriginalUUID,
+			PocketUUID:          tr.PocketUUID,
+			UserUUID:            tr.UserUUID,
+			ExecuteAt:           tr.ExecuteAt,
+			Attempts:            tr.Attempts,
+			CreatedAt:           tr.CreatedAt,
+			UpdatedAt:           tr.UpdatedAt,
 		}
 	}
 	return modelTransaction
@@ -144,7 +421,8 @@ func (p *PostgresRepo) fromGormToModelTransaction(transactions []GormTransaction
 
 func (p *PostgresRepo) GetAccountsForUser(userUUID uuid.UUID, query models.QueryParams) ([]models.Account, error) {
 	var gormAccounts []GormAccount
-	result := p.DB.Model(GormAccount{}).Where("User_UUID = ?", userUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormAccounts)
+	members := p.DB.Model(GormAccountMember{}).Select("Account_UUID").Where("User_UUID = ? AND Status = ?", userUUID, "active")
+	result := p.DB.Model(GormAccount{}).Where("User_UUID = ? OR UUID IN (?)", userUUID, members).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormAccounts)
 	if err := result.Error; err != nil {
 		return []models.Account{}, err
 	}
@@ -169,16 +447,66 @@ func (p *PostgresRepo) CreateAccount(account *models.Account) error {
 		UUID:     account.UUID,
 		IBAN:     account.IBAN,
 		Balance:  account.Balance,
+		Currency: account.Currency,
+		Type:     account.Type,
 		UserUUID: account.UserUUID,
 		Status:   account.Status,
 	}
 	err := p.DB.Create(&gormAcc).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION && strings.Contains(pgErr.ConstraintName, "iban") {
+		return ErrorDuplicateIBAN
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (p *PostgresRepo) GetAccountByIBAN(iban string) (*models.Account, error) {
+	gormAccount := GormAccount{}
+	err := p.DB.Model(GormAccount{}).Where("IBAN = ?", iban).Take(&gormAccount).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Account{}, ErrorUnknownAccount
+	}
+	if err != nil {
+		return &models.Account{}, err
+	}
+	return &p.fromGormToModelAccount([]GormAccount{gormAccount})[0], nil
+}
+
+func (p *PostgresRepo) GetAccounts() ([]models.Account, error) {
+	var gormAccounts []GormAccount
+	result := p.DB.Model(GormAccount{}).Find(&gormAccounts)
+	if result.Error != nil {
+		return []models.Account{}, result.Error
+	}
+	return p.fromGormToModelAccount(gormAccounts), nil
+}
+
+func (p *PostgresRepo) UpdateAccountIBAN(accountUUID uuid.UUID, iban string) error {
+	err := p.DB.Model(&GormAccount{}).Where("UUID = ?", accountUUID).Update("IBAN", iban).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION && strings.Contains(pgErr.ConstraintName, "iban") {
+		return ErrorDuplicateIBAN
+	}
+	return err
+}
+
+// GetAccountForUpdate reads the account with SELECT ... FOR UPDATE, so the row
+// stays locked until the end of the transaction.
+func (p *PostgresRepo) GetAccountForUpdate(accountUUID uuid.UUID) (*models.Account, error) {
+	gormAccount := GormAccount{}
+	err := p.DB.Model(GormAccount{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("UUID = ?", accountUUID).Take(&gormAccount).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Account{}, ErrorUnknownAccount
+	}
+	if err != nil {
+		return &models.Account{}, err
+	}
+	return &p.fromGormToModelAccount([]GormAccount{gormAccount})[0], nil
+}
+
 func (p *PostgresRepo) GetAccountByUUID(uuid uuid.UUID) (*models.Account, error) {
 	gormAccount := GormAccount{}
 	err := p.DB.Model(GormAccount{}).Where("UUID = ?", uuid).Take(&gormAccount).Error
@@ -186,11 +514,16 @@ func (p *PostgresRepo) GetAccountByUUID(uuid uuid.UUID) (*models.Account, error)
 		return &models.Account{}, nil
 	}
 	account := models.Account{
-		UUID:     gormAccount.UUID,
-		IBAN:     gormAccount.IBAN,
-		Balance:  gormAccount.Balance,
-		UserUUID: gormAccount.UserUUID,
-		Status:   gormAccount.Status,
+		UUID:           gormAccount.UUID,
+		IBAN:           gormAccount.IBAN,
+		Balance:        gormAccount.Balance.ForCurrency(gormAccount.Currency),
+		HeldAmount:     gormAccount.HeldAmount.ForCurrency(gormAccount.Currency),
+		PocketAmount:   gormAccount.PocketAmount.ForCurrency(gormAccount.Currency),
+		OverdraftLimit: gormAccount.OverdraftLimit.ForCurrency(gormAccount.Currency),
+		Currency:       gormAccount.Currency,
+		Type:           gormAccount.Type,
+		UserUUID:       gormAccount.UserUUID,
+		Status:         gormAccount.Status,
 	}
 	return &account, nil
 }
@@ -252,8 +585,880 @@ func (p *PostgresRepo) CreateUser(user *models.User) error {
 	return nil
 }
 
+func (p *PostgresRepo) CreateIdempotencyKey(key *models.IdempotencyKey) error {
+	gormKey := GormIdempotencyKey{
+		Key:         key.Key,
+		UserUUID:    key.UserUUID,
+		RequestHash: key.RequestHash,
+	}
+	return p.DB.Create(&gormKey).Error
+}
+
+func (p *PostgresRepo) GetIdempotencyKey(userUUID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	var gormKey GormIdempotencyKey
+	err := p.DB.Model(&GormIdempotencyKey{}).Where("User_UUID = ? AND Key = ?", userUUID, key).Take(&gormKey).Error
+	if err != nil {
+		return &models.IdempotencyKey{}, err
+	}
+	idempotencyKey := models.IdempotencyKey{
+		Key:          gormKey.Key,
+		UserUUID:     gormKey.UserUUID,
+		RequestHash:  gormKey.RequestHash,
+		ResponseCode: gormKey.ResponseCode,
+		ResponseBody: gormKey.ResponseBody,
+		CreatedAt:    gormKey.CreatedAt,
+	}
+	return &idempotencyKey, nil
+}
+
+func (p *PostgresRepo) UpdateIdempotencyKey(userUUID uuid.UUID, key string, code int, body []byte) error {
+	return p.DB.Model(&GormIdempotencyKey{}).Where("User_UUID = ? AND Key = ?", userUUID, key).Updates(map[string]interface{}{"Response_Code": code, "Response_Body": body}).Error
+}
+
+// DeleteIdempotencyKey deletes the key without a response which was created
+// not later than createdBefore; stored responses are kept.
+func (p *PostgresRepo) DeleteIdempotencyKey(userUUID uuid.UUID, key string, createdBefore time.Time) error {
+	return p.DB.Where("User_UUID = ? AND Key = ? AND (Response_Code = 0 OR Response_Code IS NULL) AND Created_At <= ?", userUUID, key, createdBefore).Delete(&GormIdempotencyKey{}).Error
+}
+
+func (p *PostgresRepo) CreateLedgerEntries(entries []models.LedgerEntry) error {
+	gormEntries := make([]GormLedgerEntry, len(entries))
+	for i, entry := range entries {
+		gormEntries[i] = GormLedgerEntry{
+			UUID:            entry.UUID,
+			JournalUUID:     entry.JournalUUID,
+			TransactionUUID: entry.TransactionUUID,
+			AccountUUID:     entry.AccountUUID,
+			Kind:            entry.Kind,
+			Direction:       entry.Direction,
+			Amount:          entry.Amount,
+		}
+	}
+	return p.DB.Create(&gormEntries).Error
+}
+
+func (p *PostgresRepo) GetLedgerEntries(accountUUID uuid.UUID, query models.QueryParams) ([]models.LedgerEntry, error) {
+	var gormEntries []GormLedgerEntry
+	result := p.DB.Model(GormLedgerEntry{}).Where("Account_UUID = ?", accountUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormEntries)
+	if result.Error != nil {
+		return []models.LedgerEntry{}, result.Error
+	}
+	entries := make([]models.LedgerEntry, len(gormEntries))
+	for i, entry := range gormEntries {
+		entries[i] = models.LedgerEntry{
+			UUID:            entry.UUID,
+			JournalUUID:     entry.JournalUUID,
+			TransactionUUID: entry.TransactionUUID,
+			AccountUUID:     entry.AccountUUID,
+			Kind:            entry.Kind,
+			Direction:       entry.Direction,
+			Amount:          entry.Amount,
+			CreatedAt:       entry.CreatedAt,
+		}
+	}
+	return entries, nil
+}
+
+func (p *PostgresRepo) GetLedgerTotals(accountUUID uuid.UUID) (models.Money, models.Money, error) {
+	var totals struct {
+		Credit models.Money
+		Debit  models.Money
+	}
+	err := p.DB.Model(GormLedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN Direction = 'credit' THEN Amount ELSE 0 END), 0) AS credit, COALESCE(SUM(CASE WHEN Direction = 'debit' THEN Amount ELSE 0 END), 0) AS debit").
+		Where("Account_UUID = ?", accountUUID).Scan(&totals).Error
+	if err != nil {
+		return models.Money{}, models.Money{}, err
+	}
+	return totals.Credit, totals.Debit, nil
+}
+
+func (p *PostgresRepo) CountLedgerEntries(kind string) (int64, error) {
+	var count int64
+	err := p.DB.Model(GormLedgerEntry{}).Where("Kind = ?", kind).Count(&count).Error
+	return count, err
+}
+
+func fromModelToGormStandingOrder(order models.StandingOrder) GormStandingOrder {
+	return GormStandingOrder{
+		UUID:            order.UUID,
+		UserUUID:        order.UserUUID,
+		SourceUUID:      order.SourceUUID,
+		DestinationUUID: order.DestinationUUID,
+		Amount:          order.Amount,
+		Interval:        order.Interval,
+		StartAt:         order.StartAt,
+		EndAt:           order.EndAt,
+		MaxRuns:         order.MaxRuns,
+		Runs:            order.Runs,
+		NextRunAt:       order.NextRunAt,
+		Status:          order.Status,
+		CreatedAt:       order.CreatedAt,
+	}
+}
+
+func (p *PostgresRepo) fromGormToModelStandingOrder(orders []GormStandingOrder) []models.StandingOrder {
+	modelOrders := make([]models.StandingOrder, len(orders))
+	for i, order := range orders {
+		modelOrders[i] = models.StandingOrder{
+			UUID:            order.UUID,
+			UserUUID:        order.UserUUID,
+			SourceUUID:      order.SourceUUID,
+			DestinationUUID: order.DestinationUUID,
+			Amount:          order.Amount,
+			Interval:        order.Interval,
+			StartAt:         order.StartAt,
+			EndAt:           order.EndAt,
+			MaxRuns:         order.MaxRuns,
+			Runs:            order.Runs,
+			NextRunAt:       order.NextRunAt,
+			Status:          order.Status,
+			CreatedAt:       order.CreatedAt,
+			UpdatedAt:       order.UpdatedAt,
+		}
+	}
+	return modelOrders
+}
+
+func (p *PostgresRepo) CreateStandingOrder(order models.StandingOrder) error {
+	gormOrder := fromModelToGormStandingOrder(order)
+	return p.DB.Create(&gormOrder).Error
+}
+
+func (p *PostgresRepo) GetStandingOrderByUUID(orderUUID uuid.UUID) (*models.StandingOrder, error) {
+	var gormOrder GormStandingOrder
+	err := p.DB.Model(GormStandingOrder{}).Where("UUID = ?", orderUUID).Take(&gormOrder).Error
+	if err != nil {
+		return &models.StandingOrder{}, err
+	}
+	return &p.fromGormToModelStandingOrder([]GormStandingOrder{gormOrder})[0], nil
+}
+
+// GetStandingOrderForUpdate reads the order with SELECT ... FOR UPDATE, so
+// only one worker runs it until the end of the transaction.
+func (p *PostgresRepo) GetStandingOrderForUpdate(orderUUID uuid.UUID) (*models.StandingOrder, error) {
+	var gormOrder GormStandingOrder
+	err := p.DB.Model(GormStandingOrder{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("UUID = ?", orderUUID).Take(&gormOrder).Error
+	if err != nil {
+		return &models.StandingOrder{}, err
+	}
+	return &p.fromGormToModelStandingOrder([]GormStandingOrder{gormOrder})[0], nil
+}
+
+func (p *PostgresRepo) GetStandingOrdersForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrder, error) {
+	var gormOrders []GormStandingOrder
+	result := p.DB.Model(GormStandingOrder{}).Where("Source_UUID = ?", accountUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormOrders)
+	if result.Error != nil {
+		return []models.StandingOrder{}, result.Error
+	}
+	return p.fromGormToModelStandingOrder(gormOrders), nil
+}
+
+func (p *PostgresRepo) GetDueStandingOrders(now time.Time) ([]models.StandingOrder, error) {
+	var gormOrders []GormStandingOrder
+	result := p.DB.Model(GormStandingOrder{}).Where("Status = ? AND Next_Run_At <= ?", "active", now).Order("next_run_at").Find(&gormOrders)
+	if result.Error != nil {
+		return []models.StandingOrder{}, result.Error
+	}
+	return p.fromGormToModelStandingOrder(gormOrders), nil
+}
+
+func (p *PostgresRepo) UpdateStandingOrder(order models.StandingOrder) error {
+	gormOrder := fromModelToGormStandingOrder(order)
+	return p.DB.Model(&GormStandingOrder{}).Where("UUID = ?", order.UUID).Select("*").Omit("CreatedAt").Updates(&gormOrder).Error
+}
+
+func (p *PostgresRepo) CreateStandingOrderRun(run models.StandingOrderRun) error {
+	gormRun := GormStandingOrderRun{
+		UUID:            run.UUID,
+		OrderUUID:       run.OrderUUID,
+		TransactionUUID: run.TransactionUUID,
+		Status:          run.Status,
+		Error:           run.Error,
+	}
+	return p.DB.Create(&gormRun).Error
+}
+
+func (p *PostgresRepo) GetStandingOrderRuns(orderUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrderRun, error) {
+	var gormRuns []GormStandingOrderRun
+	result := p.DB.Model(GormStandingOrderRun{}).Where("Order_UUID = ?", orderUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormRuns)
+	if result.Error != nil {
+		return []models.StandingOrderRun{}, result.Error
+	}
+	runs := make([]models.StandingOrderRun, len(gormRuns))
+	for i, run := range gormRuns {
+		runs[i] = models.StandingOrderRun{
+			UUID:            run.UUID,
+			OrderUUID:       run.OrderUUID,
+			TransactionUUID: run.TransactionUUID,
+			Status:          run.Status,
+			Error:           run.Error,
+			CreatedAt:       run.CreatedAt,
+		}
+	}
+	return runs, nil
+}
+
 func NewGormUserRepo(DB *gorm.DB) Repository {
 	return &PostgresRepo{
 		DB: DB,
 	}
 }
+
+func fromModelToGormLimit(limit models.Limit) GormLimit {
+	return GormLimit{
+		UUID:        limit.UUID,
+		UserUUID:    limit.UserUUID,
+		AccountUUID: limit.AccountUUID,
+		Currency:    limit.Currency,
+		MaxTransfer: limit.MaxTransfer,
+		Daily:       limit.Daily,
+		Monthly:     limit.Monthly,
+	}
+}
+
+func (p *PostgresRepo) fromGormToModelLimit(limits []GormLimit) []models.Limit {
+	modelLimits := make([]models.Limit, len(limits))
+	for i, limit := range limits {
+		modelLimits[i] = models.Limit{
+			UUID:        limit.UUID,
+			UserUUID:    limit.UserUUID,
+			AccountUUID: limit.AccountUUID,
+			Currency:    limit.Currency,
+			MaxTransfer: limit.MaxTransfer.ForCurrency(limit.Currency),
+			Daily:       limit.Daily.ForCurrency(limit.Currency),
+			Monthly:     limit.Monthly.ForCurrency(limit.Currency),
+			CreatedAt:   limit.CreatedAt,
+			UpdatedAt:   limit.UpdatedAt,
+		}
+	}
+	return modelLimits
+}
+
+func (p *PostgresRepo) CreateLimit(limit models.Limit) error {
+	gormLimit := fromModelToGormLimit(limit)
+	return p.DB.Create(&gormLimit).Error
+}
+
+func (p *PostgresRepo) UpdateLimit(limit models.Limit) error {
+	gormLimit := fromModelToGormLimit(limit)
+	return p.DB.Model(&GormLimit{}).Where("UUID = ?", limit.UUID).Select("Currency", "MaxTransfer", "Daily", "Monthly").Updates(&gormLimit).Error
+}
+
+func (p *PostgresRepo) GetLimit(userUUID, accountUUID uuid.UUID) (*models.Limit, error) {
+	var gormLimit GormLimit
+	err := p.DB.Model(GormLimit{}).Where("User_UUID = ? AND Account_UUID = ?", userUUID, accountUUID).Take(&gormLimit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Limit{}, ErrorUnknownLimit
+	}
+	if err != nil {
+		return &models.Limit{}, err
+	}
+	return &p.fromGormToModelLimit([]GormLimit{gormLimit})[0], nil
+}
+
+func (p *PostgresRepo) GetLimits(userUUID uuid.UUID) ([]models.Limit, error) {
+	var gormLimits []GormLimit
+	result := p.DB.Model(GormLimit{}).Where("User_UUID = ?", userUUID).Order("created_at").Find(&gormLimits)
+	if result.Error != nil {
+		return []models.Limit{}, result.Error
+	}
+	return p.fromGormToModelLimit(gormLimits), nil
+}
+
+func (p *PostgresRepo) DeleteLimit(limitUUID uuid.UUID) error {
+	result := p.DB.Where("UUID = ?", limitUUID).Delete(&GormLimit{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorUnknownLimit
+	}
+	return nil
+}
+
+// GetSentTransactionsForUser returns the transactions from the accounts of the
+// user which got the sent status since the given time.
+func (p *PostgresRepo) GetSentTransactionsForUser(userUUID uuid.UUID, since time.Time) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	accounts := p.DB.Model(GormAccount{}).Select("UUID").Where("User_UUID = ?", userUUID)
+	sent := p.DB.Model(GormTransactionStatusChange{}).Select("Transaction_UUID").Where(map[string]interface{}{"to": "sent"}).Where("Created_At >= ?", since)
+	result := p.DB.Model(GormTransaction{}).Where("Source_UUID IN (?) AND UUID IN (?)", accounts, sent).Order("created_at").Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+// GetTransactionsForUserInStatus returns the transactions from the accounts
+// of the user which are in the status.
+func (p *PostgresRepo) GetTransactionsForUserInStatus(userUUID uuid.UUID, status string) ([]models.Transaction, error) {
+	var gormTransaction []GormTransaction
+	accounts := p.DB.Model(GormAccount{}).Select("UUID").Where("User_UUID = ?", userUUID)
+	result := p.DB.Model(GormTransaction{}).Where("Source_UUID IN (?) AND Status = ?", accounts, status).Order("created_at").Find(&gormTransaction)
+	if result.Error != nil {
+		return []models.Transaction{}, result.Error
+	}
+	return p.fromGormToModelTransaction(gormTransaction), nil
+}
+
+func fromModelToGormFeeSchedule(schedule models.FeeSchedule) GormFeeSchedule {
+	return GormFeeSchedule{
+		UUID:        schedule.UUID,
+		AccountType: schedule.AccountType,
+		Currency:    schedule.Currency,
+		Kind:        schedule.Kind,
+		Flat:        schedule.Flat,
+		BasisPoints: schedule.BasisPoints,
+		Min:         schedule.Min,
+		Max:         schedule.Max,
+		Tiers:       schedule.Tiers,
+	}
+}
+
+func (p *PostgresRepo) fromGormToModelFeeSchedule(schedules []GormFeeSchedule) []models.FeeSchedule {
+	modelSchedules := make([]models.FeeSchedule, len(schedules))
+	for i, schedule := range schedules {
+		modelSchedules[i] = models.FeeSchedule{
+			UUID:        schedule.UUID,
+			AccountType: schedule.AccountType,
+			Currency:    schedule.Currency,
+			Kind:        schedule.Kind,
+			Flat:        schedule.Flat.ForCurrency(schedule.Currency),
+			BasisPoints: schedule.BasisPoints,
+			Min:         schedule.Min.ForCurrency(schedule.Currency),
+			Max:         schedule.Max.ForCurrency(schedule.Currency),
+			Tiers:       schedule.Tiers,
+			CreatedAt:   schedule.CreatedAt,
+			UpdatedAt:   schedule.UpdatedAt,
+		}
+	}
+	return modelSchedules
+}
+
+func (p *PostgresRepo) CreateFeeSchedule(schedule models.FeeSchedule) error {
+	gormSchedule := fromModelToGormFeeSchedule(schedule)
+	return p.DB.Create(&gormSchedule).Error
+}
+
+func (p *PostgresRepo) UpdateFeeSchedule(schedule models.FeeSchedule) error {
+	gormSchedule := fromModelToGormFeeSchedule(schedule)
+	return p.DB.Model(&GormFeeSchedule{}).Where("UUID = ?", schedule.UUID).Select("Kind", "Flat", "BasisPoints", "Min", "Max", "Tiers").Updates(&gormSchedule).Error
+}
+
+func (p *PostgresRepo) GetFeeSchedule(accountType, currency string) (*models.FeeSchedule, error) {
+	var gormSchedule GormFeeSchedule
+	err := p.DB.Model(GormFeeSchedule{}).Where("Account_Type = ? AND Currency = ?", accountType, currency).Take(&gormSchedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.FeeSchedule{}, ErrorUnknownFeeSchedule
+	}
+	if err != nil {
+		return &models.FeeSchedule{}, err
+	}
+	return &p.fromGormToModelFeeSchedule([]GormFeeSchedule{gormSchedule})[0], nil
+}
+
+func (p *PostgresRepo) GetFeeSchedules() ([]models.FeeSchedule, error) {
+	var gormSchedules []GormFeeSchedule
+	result := p.DB.Model(GormFeeSchedule{}).Order("currency, account_type").Find(&gormSchedules)
+	if result.Error != nil {
+		return []models.FeeSchedule{}, result.Error
+	}
+	return p.fromGormToModelFeeSchedule(gormSchedules), nil
+}
+
+func (p *PostgresRepo) DeleteFeeSchedule(scheduleUUID uuid.UUID) error {
+	result := p.DB.Where("UUID = ?", scheduleUUID).Delete(&GormFeeSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorUnknownFeeSchedule
+	}
+	return nil
+}
+
+func (p *PostgresRepo) GetAccountsByType(accountType string) ([]models.Account, error) {
+	var gormAccounts []GormAccount
+	result := p.DB.Model(GormAccount{}).Where("Type = ?", accountType).Find(&gormAccounts)
+	if result.Error != nil {
+		return []models.Account{}, result.Error
+	}
+	return p.fromGormToModelAccount(gormAccounts), nil
+}
+
+func (p *PostgresRepo) GetInterestAccrual(accountUUID uuid.UUID) (*models.InterestAccrual, error) {
+	var gormAccrual GormInterestAccrual
+	err := p.DB.Model(GormInterestAccrual{}).Where("Account_UUID = ?", accountUUID).Take(&gormAccrual).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.InterestAccrual{}, ErrorUnknownInterestAccrual
+	}
+	if err != nil {
+		return &models.InterestAccrual{}, err
+	}
+	return &models.InterestAccrual{
+		AccountUUID:   gormAccrual.AccountUUID,
+		Accrued:       gormAccrual.Accrued,
+		Overdraft:     gormAccrual.Overdraft,
+		OverdrawnDays: gormAccrual.OverdrawnDays,
+		AccruedAt:     gormAccrual.AccruedAt,
+		PaidAt:        gormAccrual.PaidAt,
+		UpdatedAt:     gormAccrual.UpdatedAt,
+	}, nil
+}
+
+// SaveInterestAccrual creates the accrual of the account or replaces the stored one.
+func (p *PostgresRepo) SaveInterestAccrual(accrual models.InterestAccrual) error {
+	gormAccrual := GormInterestAccrual{
+		AccountUUID:   accrual.AccountUUID,
+		Accrued:       accrual.Accrued,
+		Overdraft:     accrual.Overdraft,
+		OverdrawnDays: accrual.OverdrawnDays,
+		AccruedAt:     accrual.AccruedAt,
+		PaidAt:        accrual.PaidAt,
+	}
+	return p.DB.Save(&gormAccrual).Error
+}
+
+func fromModelToGormHold(hold models.Hold) GormHold {
+	return GormHold{
+		UUID:            hold.UUID,
+		AccountUUID:     hold.AccountUUID,
+		DestinationUUID: hold.DestinationUUID,
+		Amount:          hold.Amount,
+		Captured:        hold.Captured,
+		Currency:        hold.Currency,
+		Description:     hold.Description,
+		Status:          hold.Status,
+		ExpiresAt:       hold.ExpiresAt,
+	}
+}
+
+func (p *PostgresRepo) fromGormToModelHold(holds []GormHold) []models.Hold {
+	modelHolds := make([]models.Hold, len(holds))
+	for i, hold := range holds {
+		modelHolds[i] = models.Hold{
+			UUID:            hold.UUID,
+			AccountUUID:     hold.AccountUUID,
+			DestinationUUID: hold.DestinationUUID,
+			Amount:          hold.Amount.ForCurrency(hold.Currency),
+			Captured:        hold.Captured.ForCurrency(hold.Currency),
+			Currency:        hold.Currency,
+			Description:     hold.Description,
+			Status:          hold.Status,
+			ExpiresAt:       hold.ExpiresAt,
+			CreatedAt:       hold.CreatedAt,
+			UpdatedAt:       hold.UpdatedAt,
+		}
+	}
+	return modelHolds
+}
+
+func (p *PostgresRepo) CreateHold(hold models.Hold) error {
+	gormHold := fromModelToGormHold(hold)
+	return p.DB.Create(&gormHold).Error
+}
+
+func (p *PostgresRepo) GetHoldByUUID(holdUUID uuid.UUID) (*models.Hold, error) {
+	var gormHold GormHold
+	err := p.DB.Model(GormHold{}).Where("UUID = ?", holdUUID).Take(&gormHold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Hold{}, ErrorUnknownHold
+	}
+	if err != nil {
+		return &models.Hold{}, err
+	}
+	return &p.fromGormToModelHold([]GormHold{gormHold})[0], nil
+}
+
+func (p *PostgresRepo) GetHoldsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Hold, error) {
+	var gormHolds []GormHold
+	result := p.DB.Model(GormHold{}).Where("Account_UUID = ?", accountUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormHolds)
+	if result.Error != nil {
+		return []models.Hold{}, result.Error
+	}
+	return p.fromGormToModelHold(gormHolds), nil
+}
+
+// GetExpiredHolds returns the authorized holds which expired by now.
+func (p *PostgresRepo) GetExpiredHolds(now time.Time) ([]models.Hold, error) {
+	var gormHolds []GormHold
+	result := p.DB.Model(GormHold{}).Where("Status = ? AND Expires_At <= ?", "authorized", now).Find(&gormHolds)
+	if result.Error != nil {
+		return []models.Hold{}, result.Error
+	}
+	return p.fromGormToModelHold(gormHolds), nil
+}
+
+func (p *PostgresRepo) UpdateHold(hold models.Hold) error {
+	gormHold := fromModelToGormHold(hold)
+	return p.DB.Model(&GormHold{}).Where("UUID = ?", hold.UUID).Select("Captured", "Status").Updates(&gormHold).Error
+}
+
+func (p *PostgresRepo) fromGormToModelMember(members []GormAccountMember) []models.AccountMember {
+	modelMembers := make([]models.AccountMember, len(members))
+	for i, member := range members {
+		modelMembers[i] = models.AccountMember{
+			AccountUUID: member.AccountUUID,
+			UserUUID:    member.UserUUID,
+			Permission:  member.Permission,
+			Status:      member.Status,
+			InvitedBy:   member.InvitedBy,
+			CreatedAt:   member.CreatedAt,
+			UpdatedAt:   member.UpdatedAt,
+		}
+	}
+	return modelMembers
+}
+
+func (p *PostgresRepo) CreateMember(member models.AccountMember) error {
+	gormMember := GormAccountMember{
+		AccountUUID: member.AccountUUID,
+		UserUUID:    member.UserUUID,
+		Permission:  member.Permission,
+		Status:      member.Status,
+		InvitedBy:   member.InvitedBy,
+	}
+	err := p.DB.Create(&gormMember).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION {
+		return ErrorCreated
+	}
+	return err
+}
+
+func (p *PostgresRepo) GetMember(accountUUID, userUUID uuid.UUID) (*models.AccountMember, error) {
+	var gormMember GormAccountMember
+	err := p.DB.Model(GormAccountMember{}).Where("Account_UUID = ? AND User_UUID = ?", accountUUID, userUUID).Take(&gormMember).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.AccountMember{}, ErrorUnknownMember
+	}
+	if err != nil {
+		return &models.AccountMember{}, err
+	}
+	return &p.fromGormToModelMember([]GormAccountMember{gormMember})[0], nil
+}
+
+func (p *PostgresRepo) GetMembers(accountUUID uuid.UUID) ([]models.AccountMember, error) {
+	var gormMembers []GormAccountMember
+	result := p.DB.Model(GormAccountMember{}).Where("Account_UUID = ?", accountUUID).Order("Created_At").Find(&gormMembers)
+	if result.Error != nil {
+		return []models.AccountMember{}, result.Error
+	}
+	return p.fromGormToModelMember(gormMembers), nil
+}
+
+func (p *PostgresRepo) GetMembershipsForUser(userUUID uuid.UUID) ([]models.AccountMember, error) {
+	var gormMembers []GormAccountMember
+	result := p.DB.Model(GormAccountMember{}).Where("User_UUID = ?", userUUID).Order("Created_At").Find(&gormMembers)
+	if result.Error != nil {
+		return []models.AccountMember{}, result.Error
+	}
+	return p.fromGormToModelMember(gormMembers), nil
+}
+
+func (p *PostgresRepo) UpdateMember(member models.AccountMember) error {
+	gormMember := GormAccountMember{Permission: member.Permission, Status: member.Status}
+	result := p.DB.Model(&GormAccountMember{}).Where("Account_UUID = ? AND User_UUID = ?", member.AccountUUID, member.UserUUID).Select("Permission", "Status").Updates(&gormMember)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorUnknownMember
+	}
+	return nil
+}
+
+func (p *PostgresRepo) DeleteMember(accountUUID, userUUID uuid.UUID) error {
+	result := p.DB.Where("Account_UUID = ? AND User_UUID = ?", accountUUID, userUUID).Delete(&GormAccountMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorUnknownMember
+	}
+	return nil
+}
+
+// CountTransactionsFromAccount counts the transactions from the account in the
+// statuses.
+func (p *PostgresRepo) CountTransactionsFromAccount(accountUUID uuid.UUID, statuses []string) (int64, error) {
+	var count int64
+	err := p.DB.Model(GormTransaction{}).Where("Source_UUID = ? AND Status IN ?", accountUUID, statuses).Count(&count).Error
+	return count, err
+}
+
+// CancelStandingOrdersForAccount cancels the active standing orders from or to the account.
+func (p *PostgresRepo) CancelStandingOrdersForAccount(accountUUID uuid.UUID) error {
+	return p.DB.Model(&GormStandingOrder{}).Where("(Source_UUID = ? OR Destination_UUID = ?) AND Status = ?", accountUUID, accountUUID, "active").Update("Status", "cancelled").Error
+}
+
+func (p *PostgresRepo) fromGormToModelPocket(pockets []GormPocket) []models.Pocket {
+	modelPockets := make([]models.Pocket, len(pockets))
+	for i, pocket := range pockets {
+		modelPockets[i] = models.Pocket{
+			UUID:        pocket.UUID,
+			AccountUUID: pocket.AccountUUID,
+			Name:        pocket.Name,
+			Balance:     pocket.Balance.ForCurrency(pocket.Currency),
+			Currency:    pocket.Currency,
+			CreatedAt:   pocket.CreatedAt,
+			UpdatedAt:   pocket.UpdatedAt,
+		}
+	}
+	return modelPockets
+}
+
+func (p *PostgresRepo) CreatePocket(pocket models.Pocket) error {
+	gormPocket := GormPocket{
+		UUID:        pocket.UUID,
+		AccountUUID: pocket.AccountUUID,
+		Name:        pocket.Name,
+		Balance:     pocket.Balance,
+		Currency:    pocket.Currency,
+	}
+	return p.DB.Create(&gormPocket).Error
+}
+
+func (p *PostgresRepo) GetPocketByUUID(pocketUUID uuid.UUID) (*models.Pocket, error) {
+	var gormPocket GormPocket
+	err := p.DB.Model(GormPocket{}).Where("UUID = ?", pocketUUID).Take(&gormPocket).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Pocket{}, ErrorUnknownPocket
+	}
+	if err != nil {
+		return &models.Pocket{}, err
+	}
+	return &p.fromGormToModelPocket([]GormPocket{gormPocket})[0], nil
+}
+
+func (p *PostgresRepo) GetPocketsForAccount(accountUUID uuid.UUID) ([]models.Pocket, error) {
+	var gormPockets []GormPocket
+	result := p.DB.Model(GormPocket{}).Where("Account_UUID = ?", accountUUID).Order("Created_At").Find(&gormPockets)
+	if result.Error != nil {
+		return []models.Pocket{}, result.Error
+	}
+	return p.fromGormToModelPocket(gormPockets), nil
+}
+
+func (p *PostgresRepo) IncPocketBalance(pocketUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormPocket{}).Where("UUID = ?", pocketUUID).Update("Balance", gorm.Expr("Balance + ?", amount))
+	return p.checkPocketUpdate(pocketUUID, result)
+}
+
+func (p *PostgresRepo) DecPocketBalance(pocketUUID uuid.UUID, amount models.Money) error {
+	result := p.DB.Model(&GormPocket{}).Where("UUID = ? AND Balance >= ?", pocketUUID, amount).Update("Balance", gorm.Expr("Balance - ?", amount))
+	return p.checkPocketUpdate(pocketUUID, result)
+}
+
+func (p *PostgresRepo) checkPocketUpdate(pocketUUID uuid.UUID, result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 0 {
+		return nil
+	}
+	var count int64
+	err := p.DB.Model(&GormPocket{}).Where("UUID = ?", pocketUUID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrorUnknownPocket
+	}
+	return models.ErrMoneyOverflow
+}
+
+func (p *PostgresRepo) DeletePocket(pocketUUID uuid.UUID) error {
+	result := p.DB.Where("UUID = ?", pocketUUID).Delete(&GormPocket{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrorUnknownPocket
+	}
+	return nil
+}
+
+func fromModelToGormPaymentRequest(request models.PaymentRequest) GormPaymentRequest {
+	return GormPaymentRequest{
+		UUID:             request.UUID,
+		RequesterUUID:    request.RequesterUUID,
+		AccountUUID:      request.AccountUUID,
+		PayerUUID:        request.PayerUUID,
+		PayerAccountUUID: request.PayerAccountUUID,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		Description:      request.Description,
+		Status:           request.Status,
+		Reason:           request.Reason,
+		TransactionUUID:  request.TransactionUUID,
+		SplitUUID:        request.SplitUUID,
+		Reminders:        request.Reminders,
+		RemindedAt:       request.RemindedAt,
+		ExpiresAt:        request.ExpiresAt,
+	}
+}
+
+func (p *PostgresRepo) fromGormToModelPaymentRequest(requests []GormPaymentRequest) []models.PaymentRequest {
+	modelRequests := make([]models.PaymentRequest, len(requests))
+	for i, request := range requests {
+		modelRequests[i] = models.PaymentRequest{
+			UUID:             request.UUID,
+			RequesterUUID:    request.RequesterUUID,
+			AccountUUID:      request.AccountUUID,
+			PayerUUID:        request.PayerUUID,
+			PayerAccountUUID: request.PayerAccountUUID,
+			Amount:           request.Amount.ForCurrency(request.Currency),
+			Currency:         request.Currency,
+			Description:      request.Description,
+			Status:           request.Status,
+			Reason:           request.Reason,
+			TransactionUUID:  request.TransactionUUID,
+			SplitUUID:        request.SplitUUID,
+			Reminders:        request.Reminders,
+			RemindedAt:       request.RemindedAt,
+			ExpiresAt:        request.ExpiresAt,
+			CreatedAt:        request.CreatedAt,
+			UpdatedAt:        request.UpdatedAt,
+		}
+	}
+	return modelRequests
+}
+
+func (p *PostgresRepo) CreatePaymentRequest(request models.PaymentRequest) error {
+	gormRequest := fromModelToGormPaymentRequest(request)
+	return p.DB.Create(&gormRequest).Error
+}
+
+func (p *PostgresRepo) GetPaymentRequestByUUID(requestUUID uuid.UUID) (*models.PaymentRequest, error) {
+	var gormRequest GormPaymentRequest
+	err := p.DB.Model(GormPaymentRequest{}).Where("UUID = ?", requestUUID).Take(&gormRequest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.PaymentRequest{}, ErrorUnknownPaymentRequest
+	}
+	if err != nil {
+		return &models.PaymentRequest{}, err
+	}
+	return &p.fromGormToModelPaymentRequest([]GormPaymentRequest{gormRequest})[0], nil
+}
+
+// GetPaymentRequestForTransaction returns the request paid by the transaction.
+func (p *PostgresRepo) GetPaymentRequestForTransaction(transactionUUID uuid.UUID) (*models.PaymentRequest, error) {
+	var gormRequest GormPaymentRequest
+	err := p.DB.Model(GormPaymentRequest{}).Where("Transaction_UUID = ?", transactionUUID).Take(&gormRequest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.PaymentRequest{}, ErrorUnknownPaymentRequest
+	}
+	if err != nil {
+		return &models.PaymentRequest{}, err
+	}
+	return &p.fromGormToModelPaymentRequest([]GormPaymentRequest{gormRequest})[0], nil
+}
+
+// GetPaymentRequestsForAccount returns the requests made to be paid to the account.
+func (p *PostgresRepo) GetPaymentRequestsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	var gormRequests []GormPaymentRequest
+	result := p.DB.Model(GormPaymentRequest{}).Where("Account_UUID = ?", accountUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormRequests)
+	if result.Error != nil {
+		return []models.PaymentRequest{}, result.Error
+	}
+	return p.fromGormToModelPaymentRequest(gormRequests), nil
+}
+
+// GetPaymentRequestsForPayer returns the requests the user is asked to pay.
+func (p *PostgresRepo) GetPaymentRequestsForPayer(payerUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	var gormRequests []GormPaymentRequest
+	result := p.DB.Model(GormPaymentRequest{}).Where("Payer_UUID = ?", payerUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormRequests)
+	if result.Error != nil {
+		return []models.PaymentRequest{}, result.Error
+	}
+	return p.fromGormToModelPaymentRequest(gormRequests), nil
+}
+
+// GetExpiredPaymentRequests returns the open requests which expired by now.
+func (p *PostgresRepo) GetExpiredPaymentRequests(now time.Time) ([]models.PaymentRequest, error) {
+	var gormRequests []GormPaymentRequest
+	result := p.DB.Model(GormPaymentRequest{}).Where("Status = ? AND Expires_At <= ?", "open", now).Find(&gormRequests)
+	if result.Error != nil {
+		return []models.PaymentRequest{}, result.Error
+	}
+	return p.fromGormToModelPaymentRequest(gormRequests), nil
+}
+
+func (p *PostgresRepo) UpdatePaymentRequest(request models.PaymentRequest) error {
+	gormRequest := fromModelToGormPaymentRequest(request)
+	return p.DB.Model(&GormPaymentRequest{}).Where("UUID = ?", request.UUID).Select("PayerAccountUUID", "Status", "Reason", "TransactionUUID", "Reminders", "RemindedAt").Updates(&gormRequest).Error
+}
+
+// GetPaymentRequestsForSplit returns the shares of the split.
+func (p *PostgresRepo) GetPaymentRequestsForSplit(splitUUID uuid.UUID) ([]models.PaymentRequest, error) {
+	var gormRequests []GormPaymentRequest
+	result := p.DB.Model(GormPaymentRequest{}).Where("Split_UUID = ?", splitUUID).Order("Created_At").Find(&gormRequests)
+	if result.Error != nil {
+		return []models.PaymentRequest{}, result.Error
+	}
+	return p.fromGormToModelPaymentRequest(gormRequests), nil
+}
+
+func (p *PostgresRepo) fromGormToModelSplit(splits []GormSplit) []models.Split {
+	modelSplits := make([]models.Split, len(splits))
+	for i, split := range splits {
+		modelSplits[i] = models.Split{
+			UUID:            split.UUID,
+			UserUUID:        split.UserUUID,
+			AccountUUID:     split.AccountUUID,
+			TransactionUUID: split.TransactionUUID,
+			Mode:            split.Mode,
+			Amount:          split.Amount.ForCurrency(split.Currency),
+			OwnShare:        split.OwnShare.ForCurrency(split.Currency),
+			Currency:        split.Currency,
+			Description:     split.Description,
+			CreatedAt:       split.CreatedAt,
+			UpdatedAt:       split.UpdatedAt,
+		}
+	}
+	return modelSplits
+}
+
+// CreateSplit returns ErrorCreated when the transaction is already split.
+func (p *PostgresRepo) CreateSplit(split models.Split) error {
+	gormSplit := GormSplit{
+		UUID:            split.UUID,
+		UserUUID:        split.UserUUID,
+		AccountUUID:     split.AccountUUID,
+		TransactionUUID: split.TransactionUUID,
+		Mode:            split.Mode,
+		Amount:          split.Amount,
+		OwnShare:        split.OwnShare,
+		Currency:        split.Currency,
+		Description:     split.Description,
+	}
+	err := p.DB.Create(&gormSplit).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION {
+		return ErrorCreated
+	}
+	return err
+}
+
+func (p *PostgresRepo) GetSplitByUUID(splitUUID uuid.UUID) (*models.Split, error) {
+	var gormSplit GormSplit
+	err := p.DB.Model(GormSplit{}).Where("UUID = ?", splitUUID).Take(&gormSplit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Split{}, ErrorUnknownSplit
+	}
+	if err != nil {
+		return &models.Split{}, err
+	}
+	return &p.fromGormToModelSplit([]GormSplit{gormSplit})[0], nil
+}
+
+func (p *PostgresRepo) GetSplitsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Split, error) {
+	var gormSplits []GormSplit
+	result := p.DB.Model(GormSplit{}).Where("Account_UUID = ?", accountUUID).Order(query.Sort).Limit(int(query.Limit)).Offset(int(query.Offset)).Find(&gormSplits)
+	if result.Error != nil {
+		return []models.Split{}, result.Error
+	}
+	return p.fromGormToModelSplit(gormSplits), nil
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"errors"
 	"payment/models"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -11,14 +13,52 @@ var ErrorCreated = errors.New("user has already created")
 var ErrorUnknownUser = errors.New("user does not exist")
 var ErrorUnknownAccount = errors.New("account does not exist")
 var ErrorUnknownTransaction = errors.New("transaction does not exist")
+var ErrorUnknownIdempotencyKey = errors.New("idempotency key does not exist")
+var ErrorUnknownStandingOrder = errors.New("standing order does not exist")
+var ErrorUnknownLimit = errors.New("limit does not exist")
+var ErrorDuplicateIBAN = errors.New("account with the iban already exists")
+var ErrorUnknownFeeSchedule = errors.New("fee schedule does not exist")
+var ErrorUnknownInterestAccrual = errors.New("interest accrual does not exist")
+var ErrorUnknownHold = errors.New("hold does not exist")
+var ErrorUnknownMember = errors.New("account member does not exist")
+var ErrorUnknownPocket = errors.New("pocket does not exist")
+var ErrorUnknownPaymentRequest = errors.New("payment request does not exist")
+var ErrorUnknownSplit = errors.New("split does not exist")
+
+type idempotencyKeyID struct {
+	userUUID uuid.UUID
+	key      string
+}
+
+type memberID struct {
+	accountUUID uuid.UUID
+	userUUID    uuid.UUID
+}
 
 type TestRepo struct {
+	// mu serializes the transactions like the row locks of PostgresRepo do.
+	mu           *sync.Mutex
 	Users        map[uuid.UUID]*models.User
 	Accounts     map[uuid.UUID]*models.Account
 	Transactions map[uuid.UUID]*models.Transaction
+	Keys         map[idempotencyKeyID]*models.IdempotencyKey
+	Ledger       []models.LedgerEntry
+	History      []models.TransactionStatusChange
+	Orders       map[uuid.UUID]*models.StandingOrder
+	OrderRuns    []models.StandingOrderRun
+	Limits       map[uuid.UUID]*models.Limit
+	Fees         map[uuid.UUID]*models.FeeSchedule
+	Accruals     map[uuid.UUID]*models.InterestAccrual
+	Holds        map[uuid.UUID]*models.Hold
+	Members      map[memberID]*models.AccountMember
+	Pockets      map[uuid.UUID]*models.Pocket
+	Requests     map[uuid.UUID]*models.PaymentRequest
+	Splits       map[uuid.UUID]*models.Split
 }
 
 func (t *TestRepo) Transaction(callback func(repo Repository) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return callback(t)
 }
 
@@ -28,6 +68,16 @@ func (t *TestRepo) UpdateStatusTransaction(transactionUUID uuid.UUID, status str
 		return ErrorUnknownTransaction
 	}
 	transaction.Status = status
+	transaction.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) UpdateOverdraftLimit(accountUUID uuid.UUID, limit models.Money) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	account.OverdraftLimit = limit
 	return nil
 }
 
@@ -65,21 +115,87 @@ func (t *TestRepo) UpdatePassword(userUUID uuid.UUID, password string) error {
 	user.Password = password
 	return nil
 }
-func (t *TestRepo) DecBalance(accountUUID uuid.UUID, amount uint) error {
+func (t *TestRepo) DecBalance(accountUUID uuid.UUID, amount models.Money) error {
 	account, ok := t.Accounts[accountUUID]
 	if !ok {
 		return ErrorUnknownAccount
 	}
-	account.Balance = account.Balance - amount
+	balance, err := account.Balance.Sub(amount)
+	if err != nil {
+		return err
+	}
+	account.Balance = balance
 	return nil
 }
 
-func (t *TestRepo) IncBalance(accountUUID uuid.UUID, amount uint) error {
+func (t *TestRepo) IncBalance(accountUUID uuid.UUID, amount models.Money) error {
 	account, ok := t.Accounts[accountUUID]
 	if !ok {
 		return ErrorUnknownAccount
 	}
-	account.Balance = account.Balance + amount
+	balance, err := account.Balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	account.Balance = balance
+	return nil
+}
+
+func (t *TestRepo) IncHeld(accountUUID uuid.UUID, amount models.Money) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	held, err := account.HeldAmount.Add(amount)
+	if err != nil {
+		return err
+	}
+	account.HeldAmount = held
+	return nil
+}
+
+func (t *TestRepo) IncPocketAmount(accountUUID uuid.UUID, amount models.Money) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	pocketed, err := account.PocketAmount.Add(amount)
+	if err != nil {
+		return err
+	}
+	account.PocketAmount = pocketed
+	return nil
+}
+
+func (t *TestRepo) DecPocketAmount(accountUUID uuid.UUID, amount models.Money) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	pocketed, err := account.PocketAmount.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if pocketed.IsNegative() {
+		return models.ErrMoneyOverflow
+	}
+	account.PocketAmount = pocketed
+	return nil
+}
+
+func (t *TestRepo) DecHeld(accountUUID uuid.UUID, amount models.Money) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	held, err := account.HeldAmount.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if held.IsNegative() {
+		return models.ErrMoneyOverflow
+	}
+	account.HeldAmount = held
 	return nil
 }
 
@@ -91,6 +207,19 @@ func (t *TestRepo) GetAccountByUUID(uuid uuid.UUID) (*models.Account, error) {
 	return account, nil
 }
 
+func (t *TestRepo) GetAccountForUpdate(accountUUID uuid.UUID) (*models.Account, error) {
+	return t.GetAccountByUUID(accountUUID)
+}
+
+func (t *TestRepo) GetAccountByIBAN(iban string) (*models.Account, error) {
+	for _, account := range t.Accounts {
+		if account.IBAN == iban {
+			return account, nil
+		}
+	}
+	return &models.Account{}, ErrorUnknownAccount
+}
+
 func (t *TestRepo) GetTransactionForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Transaction, error) {
 	transactions := make([]models.Transaction, 0)
 	for _, tr := range t.Transactions {
@@ -100,6 +229,56 @@ func (t *TestRepo) GetTransactionForAccount(accountUUID uuid.UUID, query models.
 	}
 	return transactions, nil
 }
+func (t *TestRepo) GetRefunds(originalUUID uuid.UUID) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, tr := range t.Transactions {
+		if tr.OriginalUUID == originalUUID {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) GetTransactionsByStatus(status string, createdBefore time.Time) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, tr := range t.Transactions {
+		if tr.Status == status && tr.CreatedAt.Before(createdBefore) {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) GetTransactionsForStatus(status string, query models.QueryParams) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, tr := range t.Transactions {
+		if tr.Status == status {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) GetDueTransactions(now time.Time) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, tr := range t.Transactions {
+		if tr.Status == "scheduled" && tr.ExecuteAt != nil && !tr.ExecuteAt.After(now) {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) UpdateScheduleTransaction(transactionUUID uuid.UUID, attempts uint, executeAt time.Time) error {
+	transaction, ok := t.Transactions[transactionUUID]
+	if !ok {
+		return ErrorUnknownTransaction
+	}
+	transaction.Attempts = attempts
+	transaction.ExecuteAt = &executeAt
+	return nil
+}
+
 func (t *TestRepo) GetTransactionByUUID(transactionUUID uuid.UUID) (*models.Transaction, error) {
 	transaction, ok := t.Transactions[transactionUUID]
 	if !ok {
@@ -110,6 +289,10 @@ func (t *TestRepo) GetTransactionByUUID(transactionUUID uuid.UUID) (*models.Tran
 func (t *TestRepo) CreateTransaction(transaction models.Transaction) error {
 	_, ok := t.Transactions[transaction.UUID]
 	if !ok {
+		if transaction.CreatedAt.IsZero() {
+			transaction.CreatedAt = time.Now()
+			transaction.UpdatedAt = transaction.CreatedAt
+		}
 		t.Transactions[transaction.UUID] = &transaction
 		return nil
 	}
@@ -120,17 +303,39 @@ func NewTestRepo() TestRepo {
 	users := make(map[uuid.UUID]*models.User)
 	accounts := make(map[uuid.UUID]*models.Account)
 	transaction := make(map[uuid.UUID]*models.Transaction)
+	keys := make(map[idempotencyKeyID]*models.IdempotencyKey)
+	orders := make(map[uuid.UUID]*models.StandingOrder)
+	limits := make(map[uuid.UUID]*models.Limit)
+	fees := make(map[uuid.UUID]*models.FeeSchedule)
+	accruals := make(map[uuid.UUID]*models.InterestAccrual)
+	holds := make(map[uuid.UUID]*models.Hold)
+	members := make(map[memberID]*models.AccountMember)
+	pockets := make(map[uuid.UUID]*models.Pocket)
+	requests := make(map[uuid.UUID]*models.PaymentRequest)
+	splits := make(map[uuid.UUID]*models.Split)
 	return TestRepo{
+		mu:           &sync.Mutex{},
 		Users:        users,
 		Accounts:     accounts,
 		Transactions: transaction,
+		Keys:         keys,
+		Orders:       orders,
+		Limits:       limits,
+		Fees:         fees,
+		Accruals:     accruals,
+		Holds:        holds,
+		Members:      members,
+		Pockets:      pockets,
+		Requests:     requests,
+		Splits:       splits,
 	}
 }
 
 func (t *TestRepo) GetAccountsForUser(userUUID uuid.UUID, paganition models.QueryParams) ([]models.Account, error) {
 	accounts := make([]models.Account, 0)
 	for _, account := range t.Accounts {
-		if account.UserUUID == userUUID {
+		member, ok := t.Members[memberID{account.UUID, userUUID}]
+		if account.UserUUID == userUUID || ok && member.Status == "active" {
 			accounts = append(accounts, *account)
 		}
 	}
@@ -164,7 +369,30 @@ func (t *TestRepo) GetUserByEmail(email string) (*models.User, error) {
 	return &models.User{}, ErrorUnknownUser
 }
 
+func (t *TestRepo) GetAccounts() ([]models.Account, error) {
+	accounts := make([]models.Account, 0)
+	for _, account := range t.Accounts {
+		accounts = append(accounts, *account)
+	}
+	return accounts, nil
+}
+
+func (t *TestRepo) UpdateAccountIBAN(accountUUID uuid.UUID, iban string) error {
+	account, ok := t.Accounts[accountUUID]
+	if !ok {
+		return ErrorUnknownAccount
+	}
+	if _, err := t.GetAccountByIBAN(iban); err == nil {
+		return ErrorDuplicateIBAN
+	}
+	account.IBAN = iban
+	return nil
+}
+
 func (t *TestRepo) CreateAccount(account *models.Account) error {
+	if _, err := t.GetAccountByIBAN(account.IBAN); err == nil {
+		return ErrorDuplicateIBAN
+	}
 	_, ok := t.Accounts[account.UUID]
 	if !ok {
 		t.Accounts[account.UUID] = account
@@ -199,3 +427,643 @@ func (t *TestRepo) CheckIfExistUser(user *models.User) error {
 	}
 	return nil
 }
+
+func (t *TestRepo) CreateIdempotencyKey(key *models.IdempotencyKey) error {
+	id := idempotencyKeyID{userUUID: key.UserUUID, key: key.Key}
+	if _, ok := t.Keys[id]; ok {
+		return ErrorCreated
+	}
+	stored := *key
+	stored.CreatedAt = time.Now()
+	t.Keys[id] = &stored
+	return nil
+}
+
+func (t *TestRepo) GetIdempotencyKey(userUUID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	stored, ok := t.Keys[idempotencyKeyID{userUUID: userUUID, key: key}]
+	if !ok {
+		return &models.IdempotencyKey{}, ErrorUnknownIdempotencyKey
+	}
+	return stored, nil
+}
+
+func (t *TestRepo) UpdateIdempotencyKey(userUUID uuid.UUID, key string, code int, body []byte) error {
+	stored, ok := t.Keys[idempotencyKeyID{userUUID: userUUID, key: key}]
+	if !ok {
+		return ErrorUnknownIdempotencyKey
+	}
+	stored.ResponseCode = code
+	stored.ResponseBody = body
+	return nil
+}
+
+func (t *TestRepo) DeleteIdempotencyKey(userUUID uuid.UUID, key string, createdBefore time.Time) error {
+	id := idempotencyKeyID{userUUID: userUUID, key: key}
+	stored, ok := t.Keys[id]
+	if ok && stored.ResponseCode == 0 && !stored.CreatedAt.After(createdBefore) {
+		delete(t.Keys, id)
+	}
+	return nil
+}
+
+func (t *TestRepo) CreateLedgerEntries(entries []models.LedgerEntry) error {
+	for _, entry := range entries {
+		entry.CreatedAt = time.Now()
+		t.Ledger = append(t.Ledger, entry)
+	}
+	return nil
+}
+
+func (t *TestRepo) GetLedgerEntries(accountUUID uuid.UUID, query models.QueryParams) ([]models.LedgerEntry, error) {
+	entries := make([]models.LedgerEntry, 0)
+	for _, entry := range t.Ledger {
+		if entry.AccountUUID == accountUUID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (t *TestRepo) GetLedgerTotals(accountUUID uuid.UUID) (models.Money, models.Money, error) {
+	var credit, debit models.Money
+	var err error
+	for _, entry := range t.Ledger {
+		if entry.AccountUUID != accountUUID {
+			continue
+		}
+		if entry.Direction == "credit" {
+			credit, err = credit.Add(entry.Amount)
+		} else {
+			debit, err = debit.Add(entry.Amount)
+		}
+		if err != nil {
+			return models.Money{}, models.Money{}, err
+		}
+	}
+	return credit, debit, nil
+}
+
+func (t *TestRepo) CountLedgerEntries(kind string) (int64, error) {
+	var count int64
+	for _, entry := range t.Ledger {
+		if entry.Kind == kind {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (t *TestRepo) CreateStatusChange(change models.TransactionStatusChange) error {
+	change.CreatedAt = time.Now()
+	t.History = append(t.History, change)
+	return nil
+}
+
+func (t *TestRepo) GetStatusHistory(transactionUUID uuid.UUID) ([]models.TransactionStatusChange, error) {
+	changes := make([]models.TransactionStatusChange, 0)
+	for _, change := range t.History {
+		if change.TransactionUUID == transactionUUID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (t *TestRepo) CreateStandingOrder(order models.StandingOrder) error {
+	if _, ok := t.Orders[order.UUID]; ok {
+		return ErrorCreated
+	}
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+	t.Orders[order.UUID] = &order
+	return nil
+}
+
+func (t *TestRepo) GetStandingOrderByUUID(orderUUID uuid.UUID) (*models.StandingOrder, error) {
+	order, ok := t.Orders[orderUUID]
+	if !ok {
+		return &models.StandingOrder{}, ErrorUnknownStandingOrder
+	}
+	return order, nil
+}
+
+func (t *TestRepo) GetStandingOrderForUpdate(orderUUID uuid.UUID) (*models.StandingOrder, error) {
+	order, err := t.GetStandingOrderByUUID(orderUUID)
+	if err != nil {
+		return order, err
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (t *TestRepo) GetStandingOrdersForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrder, error) {
+	orders := make([]models.StandingOrder, 0)
+	for _, order := range t.Orders {
+		if order.SourceUUID == accountUUID {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func (t *TestRepo) GetDueStandingOrders(now time.Time) ([]models.StandingOrder, error) {
+	orders := make([]models.StandingOrder, 0)
+	for _, order := range t.Orders {
+		if order.Status == "active" && !order.NextRunAt.After(now) {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func (t *TestRepo) UpdateStandingOrder(order models.StandingOrder) error {
+	stored, ok := t.Orders[order.UUID]
+	if !ok {
+		return ErrorUnknownStandingOrder
+	}
+	order.CreatedAt = stored.CreatedAt
+	order.UpdatedAt = time.Now()
+	*stored = order
+	return nil
+}
+
+func (t *TestRepo) CreateStandingOrderRun(run models.StandingOrderRun) error {
+	run.CreatedAt = time.Now()
+	t.OrderRuns = append(t.OrderRuns, run)
+	return nil
+}
+
+func (t *TestRepo) GetStandingOrderRuns(orderUUID uuid.UUID, query models.QueryParams) ([]models.StandingOrderRun, error) {
+	runs := make([]models.StandingOrderRun, 0)
+	for _, run := range t.OrderRuns {
+		if run.OrderUUID == orderUUID {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func (t *TestRepo) CreateLimit(limit models.Limit) error {
+	if _, err := t.GetLimit(limit.UserUUID, limit.AccountUUID); err == nil {
+		return ErrorCreated
+	}
+	limit.CreatedAt = time.Now()
+	limit.UpdatedAt = limit.CreatedAt
+	t.Limits[limit.UUID] = &limit
+	return nil
+}
+
+func (t *TestRepo) UpdateLimit(limit models.Limit) error {
+	stored, ok := t.Limits[limit.UUID]
+	if !ok {
+		return ErrorUnknownLimit
+	}
+	stored.Currency = limit.Currency
+	stored.MaxTransfer = limit.MaxTransfer
+	stored.Daily = limit.Daily
+	stored.Monthly = limit.Monthly
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) GetLimit(userUUID, accountUUID uuid.UUID) (*models.Limit, error) {
+	for _, limit := range t.Limits {
+		if limit.UserUUID == userUUID && limit.AccountUUID == accountUUID {
+			return limit, nil
+		}
+	}
+	return &models.Limit{}, ErrorUnknownLimit
+}
+
+func (t *TestRepo) GetLimits(userUUID uuid.UUID) ([]models.Limit, error) {
+	limits := make([]models.Limit, 0)
+	for _, limit := range t.Limits {
+		if limit.UserUUID == userUUID {
+			limits = append(limits, *limit)
+		}
+	}
+	return limits, nil
+}
+
+func (t *TestRepo) DeleteLimit(limitUUID uuid.UUID) error {
+	if _, ok := t.Limits[limitUUID]; !ok {
+		return ErrorUnknownLimit
+	}
+	delete(t.Limits, limitUUID)
+	return nil
+}
+
+func (t *TestRepo) GetSentTransactionsForUser(userUUID uuid.UUID, since time.Time) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, change := range t.History {
+		if change.To != "sent" || change.CreatedAt.Before(since) {
+			continue
+		}
+		tr, ok := t.Transactions[change.TransactionUUID]
+		if !ok {
+			continue
+		}
+		if source, ok := t.Accounts[tr.SourceUUID]; ok && source.UserUUID == userUUID {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) GetTransactionsForUserInStatus(userUUID uuid.UUID, status string) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	for _, tr := range t.Transactions {
+		if tr.Status != status {
+			continue
+		}
+		if source, ok := t.Accounts[tr.SourceUUID]; ok && source.UserUUID == userUUID {
+			transactions = append(transactions, *tr)
+		}
+	}
+	return transactions, nil
+}
+
+func (t *TestRepo) CreateFeeSchedule(schedule models.FeeSchedule) error {
+	if _, err := t.GetFeeSchedule(schedule.AccountType, schedule.Currency); err == nil {
+		return ErrorCreated
+	}
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	t.Fees[schedule.UUID] = &schedule
+	return nil
+}
+
+func (t *TestRepo) UpdateFeeSchedule(schedule models.FeeSchedule) error {
+	stored, ok := t.Fees[schedule.UUID]
+	if !ok {
+		return ErrorUnknownFeeSchedule
+	}
+	schedule.AccountType = stored.AccountType
+	schedule.Currency = stored.Currency
+	schedule.CreatedAt = stored.CreatedAt
+	schedule.UpdatedAt = time.Now()
+	*stored = schedule
+	return nil
+}
+
+func (t *TestRepo) GetFeeSchedule(accountType, currency string) (*models.FeeSchedule, error) {
+	for _, schedule := range t.Fees {
+		if schedule.AccountType == accountType && schedule.Currency == currency {
+			return schedule, nil
+		}
+	}
+	return &models.FeeSchedule{}, ErrorUnknownFeeSchedule
+}
+
+func (t *TestRepo) GetFeeSchedules() ([]models.FeeSchedule, error) {
+	schedules := make([]models.FeeSchedule, 0)
+	for _, schedule := range t.Fees {
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, nil
+}
+
+func (t *TestRepo) DeleteFeeSchedule(scheduleUUID uuid.UUID) error {
+	if _, ok := t.Fees[scheduleUUID]; !ok {
+		return ErrorUnknownFeeSchedule
+	}
+	delete(t.Fees, scheduleUUID)
+	return nil
+}
+
+func (t *TestRepo) GetAccountsByType(accountType string) ([]models.Account, error) {
+	accounts := make([]models.Account, 0)
+	for _, account := range t.Accounts {
+		if account.Type == accountType {
+			accounts = append(accounts, *account)
+		}
+	}
+	return accounts, nil
+}
+
+func (t *TestRepo) GetInterestAccrual(accountUUID uuid.UUID) (*models.InterestAccrual, error) {
+	accrual, ok := t.Accruals[accountUUID]
+	if !ok {
+		return &models.InterestAccrual{}, ErrorUnknownInterestAccrual
+	}
+	return accrual, nil
+}
+
+func (t *TestRepo) SaveInterestAccrual(accrual models.InterestAccrual) error {
+	accrual.UpdatedAt = time.Now()
+	t.Accruals[accrual.AccountUUID] = &accrual
+	return nil
+}
+
+func (t *TestRepo) CreateHold(hold models.Hold) error {
+	if _, ok := t.Holds[hold.UUID]; ok {
+		return ErrorCreated
+	}
+	hold.CreatedAt = time.Now()
+	hold.UpdatedAt = hold.CreatedAt
+	t.Holds[hold.UUID] = &hold
+	return nil
+}
+
+func (t *TestRepo) GetHoldByUUID(holdUUID uuid.UUID) (*models.Hold, error) {
+	hold, ok := t.Holds[holdUUID]
+	if !ok {
+		return &models.Hold{}, ErrorUnknownHold
+	}
+	return hold, nil
+}
+
+func (t *TestRepo) GetHoldsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Hold, error) {
+	holds := make([]models.Hold, 0)
+	for _, hold := range t.Holds {
+		if hold.AccountUUID == accountUUID {
+			holds = append(holds, *hold)
+		}
+	}
+	return holds, nil
+}
+
+func (t *TestRepo) GetExpiredHolds(now time.Time) ([]models.Hold, error) {
+	holds := make([]models.Hold, 0)
+	for _, hold := range t.Holds {
+		if hold.Status == "authorized" && !hold.ExpiresAt.After(now) {
+			holds = append(holds, *hold)
+		}
+	}
+	return holds, nil
+}
+
+func (t *TestRepo) UpdateHold(hold models.Hold) error {
+	stored, ok := t.Holds[hold.UUID]
+	if !ok {
+		return ErrorUnknownHold
+	}
+	stored.Captured = hold.Captured
+	stored.Status = hold.Status
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) CreateMember(member models.AccountMember) error {
+	id := memberID{member.AccountUUID, member.UserUUID}
+	if _, ok := t.Members[id]; ok {
+		return ErrorCreated
+	}
+	member.CreatedAt = time.Now()
+	member.UpdatedAt = member.CreatedAt
+	t.Members[id] = &member
+	return nil
+}
+
+func (t *TestRepo) GetMember(accountUUID, userUUID uuid.UUID) (*models.AccountMember, error) {
+	member, ok := t.Members[memberID{accountUUID, userUUID}]
+	if !ok {
+		return &models.AccountMember{}, ErrorUnknownMember
+	}
+	return member, nil
+}
+
+func (t *TestRepo) GetMembers(accountUUID uuid.UUID) ([]models.AccountMember, error) {
+	members := make([]models.AccountMember, 0)
+	for _, member := range t.Members {
+		if member.AccountUUID == accountUUID {
+			members = append(members, *member)
+		}
+	}
+	return members, nil
+}
+
+func (t *TestRepo) GetMembershipsForUser(userUUID uuid.UUID) ([]models.AccountMember, error) {
+	members := make([]models.AccountMember, 0)
+	for _, member := range t.Members {
+		if member.UserUUID == userUUID {
+			members = append(members, *member)
+		}
+	}
+	return members, nil
+}
+
+func (t *TestRepo) UpdateMember(member models.AccountMember) error {
+	stored, ok := t.Members[memberID{member.AccountUUID, member.UserUUID}]
+	if !ok {
+		return ErrorUnknownMember
+	}
+	stored.Permission = member.Permission
+	stored.Status = member.Status
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) DeleteMember(accountUUID, userUUID uuid.UUID) error {
+	id := memberID{accountUUID, userUUID}
+	if _, ok := t.Members[id]; !ok {
+		return ErrorUnknownMember
+	}
+	delete(t.Members, id)
+	return nil
+}
+
+func (t *TestRepo) CountTransactionsFromAccount(accountUUID uuid.UUID, statuses []string) (int64, error) {
+	var count int64
+	for _, transaction := range t.Transactions {
+		if transaction.SourceUUID != accountUUID {
+			continue
+		}
+		for _, status := range statuses {
+			if transaction.Status == status {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+func (t *TestRepo) CancelStandingOrdersForAccount(accountUUID uuid.UUID) error {
+	for _, order := range t.Orders {
+		if (order.SourceUUID == accountUUID || order.DestinationUUID == accountUUID) && order.Status == "active" {
+			order.Status = "cancelled"
+			order.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (t *TestRepo) CreatePocket(pocket models.Pocket) error {
+	if _, ok := t.Pockets[pocket.UUID]; ok {
+		return ErrorCreated
+	}
+	pocket.CreatedAt = time.Now()
+	pocket.UpdatedAt = pocket.CreatedAt
+	t.Pockets[pocket.UUID] = &pocket
+	return nil
+}
+
+func (t *TestRepo) GetPocketByUUID(pocketUUID uuid.UUID) (*models.Pocket, error) {
+	pocket, ok := t.Pockets[pocketUUID]
+	if !ok {
+		return &models.Pocket{}, ErrorUnknownPocket
+	}
+	return pocket, nil
+}
+
+func (t *TestRepo) GetPocketsForAccount(accountUUID uuid.UUID) ([]models.Pocket, error) {
+	pockets := make([]models.Pocket, 0)
+	for _, pocket := range t.Pockets {
+		if pocket.AccountUUID == accountUUID {
+			pockets = append(pockets, *pocket)
+		}
+	}
+	return pockets, nil
+}
+
+func (t *TestRepo) IncPocketBalance(pocketUUID uuid.UUID, amount models.Money) error {
+	pocket, ok := t.Pockets[pocketUUID]
+	if !ok {
+		return ErrorUnknownPocket
+	}
+	balance, err := pocket.Balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	pocket.Balance = balance
+	pocket.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) DecPocketBalance(pocketUUID uuid.UUID, amount models.Money) error {
+	pocket, ok := t.Pockets[pocketUUID]
+	if !ok {
+		return ErrorUnknownPocket
+	}
+	balance, err := pocket.Balance.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if balance.IsNegative() {
+		return models.ErrMoneyOverflow
+	}
+	pocket.Balance = balance
+	pocket.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) DeletePocket(pocketUUID uuid.UUID) error {
+	if _, ok := t.Pockets[pocketUUID]; !ok {
+		return ErrorUnknownPocket
+	}
+	delete(t.Pockets, pocketUUID)
+	return nil
+}
+
+func (t *TestRepo) CreatePaymentRequest(request models.PaymentRequest) error {
+	if _, ok := t.Requests[request.UUID]; ok {
+		return ErrorCreated
+	}
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = request.CreatedAt
+	t.Requests[request.UUID] = &request
+	return nil
+}
+
+func (t *TestRepo) GetPaymentRequestByUUID(requestUUID uuid.UUID) (*models.PaymentRequest, error) {
+	request, ok := t.Requests[requestUUID]
+	if !ok {
+		return &models.PaymentRequest{}, ErrorUnknownPaymentRequest
+	}
+	return request, nil
+}
+
+func (t *TestRepo) GetPaymentRequestForTransaction(transactionUUID uuid.UUID) (*models.PaymentRequest, error) {
+	for _, request := range t.Requests {
+		if request.TransactionUUID == transactionUUID {
+			return request, nil
+		}
+	}
+	return &models.PaymentRequest{}, ErrorUnknownPaymentRequest
+}
+
+func (t *TestRepo) GetPaymentRequestsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	requests := make([]models.PaymentRequest, 0)
+	for _, request := range t.Requests {
+		if request.AccountUUID == accountUUID {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+func (t *TestRepo) GetPaymentRequestsForPayer(payerUUID uuid.UUID, query models.QueryParams) ([]models.PaymentRequest, error) {
+	requests := make([]models.PaymentRequest, 0)
+	for _, request := range t.Requests {
+		if request.PayerUUID == payerUUID {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+func (t *TestRepo) GetExpiredPaymentRequests(now time.Time) ([]models.PaymentRequest, error) {
+	requests := make([]models.PaymentRequest, 0)
+	for _, request := range t.Requests {
+		if request.Status == "open" && !request.ExpiresAt.After(now) {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+func (t *TestRepo) UpdatePaymentRequest(request models.PaymentRequest) error {
+	stored, ok := t.Requests[request.UUID]
+	if !ok {
+		return ErrorUnknownPaymentRequest
+	}
+	stored.PayerAccountUUID = request.PayerAccountUUID
+	stored.Status = request.Status
+	stored.Reason = request.Reason
+	stored.TransactionUUID = request.TransactionUUID
+	stored.Reminders = request.Reminders
+	stored.RemindedAt = request.RemindedAt
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *TestRepo) GetPaymentRequestsForSplit(splitUUID uuid.UUID) ([]models.PaymentRequest, error) {
+	requests := make([]models.PaymentRequest, 0)
+	for _, request := range t.Requests {
+		if request.SplitUUID == splitUUID {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+func (t *TestRepo) CreateSplit(split models.Split) error {
+	for _, stored := range t.Splits {
+		if stored.UUID == split.UUID || stored.TransactionUUID == split.TransactionUUID {
+			return ErrorCreated
+		}
+	}
+	split.CreatedAt = time.Now()
+	split.UpdatedAt = split.CreatedAt
+	t.Splits[split.UUID] = &split
+	return nil
+}
+
+func (t *TestRepo) GetSplitByUUID(splitUUID uuid.UUID) (*models.Split, error) {
+	split, ok := t.Splits[splitUUID]
+	if !ok {
+		return &models.Split{}, ErrorUnknownSplit
+	}
+	return split, nil
+}
+
+func (t *TestRepo) GetSplitsForAccount(accountUUID uuid.UUID, query models.QueryParams) ([]models.Split, error) {
+	splits := make([]models.Split, 0)
+	for _, split := range t.Splits {
+		if split.AccountUUID == accountUUID {
+			splits = append(splits, *split)
+		}
+	}
+	return splits, nil
+}